@@ -1,28 +1,68 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/Ekvo/go-map-rwmu-mux/internal/app"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
 )
 
+// файл настроек, перечитывается по SIGHUP
+const envPath = "./init/.env"
+
 func main() {
-	cfg, err := config.NewConfig("./init/.env")
+	cfg, err := config.NewConfig(envPath)
 	if err != nil {
-		log.Fatalf("main: config error - {%v};", err)
+		slog.Error("main: config", "error", err)
+		os.Exit(1)
 	}
 
-	qb := app.NewQuotationBook(cfg)
+	// уровень меняется по SIGHUP
+	level := new(slog.LevelVar)
+	level.Set(cfg.LogLevel)
+
+	logger, err := logging.New(os.Stderr, level, cfg.LogFormat)
+	if err != nil {
+		slog.Error("main: logger", "error", err)
+		os.Exit(1)
+	}
+	// для пакетов без своего лога ('pkg/utils')
+	slog.SetDefault(logger)
 
-	qb.Run()
+	qb, err := app.NewQuotationBook(cfg, logger, level)
+	if err != nil {
+		logger.Error("main: app", "error", err)
+		os.Exit(1)
+	}
 
+	// сигнал во время загрузки хранилища обработается после 'Run'
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL, syscall.SIGHUP)
+
+	if err := qb.Run(); err != nil {
+		logger.Error("main: run", "error", err)
+		os.Exit(1)
+	}
+
+	// SIGHUP -> перечитываем настройки, остальные сигналы -> остановка
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+
+		next, err := config.NewConfig(envPath)
+		if err != nil {
+			logger.Error("main: reload config", "error", err)
+			continue
+		}
+		if err := qb.Reload(next); err != nil {
+			logger.Error("main: reload", "error", err)
+		}
+	}
 
 	qb.Stop()
 }
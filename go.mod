@@ -1,3 +1,5 @@
 module github.com/Ekvo/go-map-rwmu-mux
 
 go 1.24.1
+
+require golang.org/x/text v0.24.0
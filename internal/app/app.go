@@ -4,11 +4,15 @@ package app
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"slices"
 
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/metrics"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/server"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/transport"
@@ -16,49 +20,260 @@ import (
 
 // ключевые узлы приложения
 type QuotationBook struct {
-	repository db.Provider
+	// действующие настройки, после 'Reload' - с новыми значениями перечитываемых полей
+	cfg        *config.Config
+	repository db.Store
 	service    service.ServiceQuote
 	transport  transport.Transport
+	metrics    *metrics.Registry
+
+	// перечитываются в 'Reload', nil -> не заданы
+	keys  *transport.APIKeys
+	cert  *server.Certificate
+	level *slog.LevelVar
+
+	log *slog.Logger
 }
 
 // конструктор для QuotationBook
-func NewQuotationBook(cfg *config.Config) *QuotationBook {
-	qb := &QuotationBook{}
+// хранилище открывается в 'Run', после запуска сервера -> пробы отвечают во время загрузки
+// 'logger' передается всем слоям, его уровень 'level' меняется в 'Reload' (nil -> не меняется), метрики хранилища и транспорта - в одном 'metrics.Registry'
+// ключи API из 'cfg.APIKeysPath', ключи JWT из 'cfg.JWTHMACKeyPath' и 'cfg.JWTRSAKeyPath',
+// сертификаты TLS из 'cfg.TLSCertPath', 'cfg.TLSKeyPath' и 'cfg.TLSClientCAPath',
+// ошибка в файле ключей или сертификатов -> ошибка
+func NewQuotationBook(cfg *config.Config, logger *slog.Logger, level *slog.LevelVar) (*QuotationBook, error) {
+	qb := &QuotationBook{cfg: cfg, metrics: metrics.NewRegistry(), level: level, log: logger}
+
+	opts := []transport.Option{transport.WithLogger(logger), transport.WithMetrics(qb.metrics)}
+
+	if cfg.APIKeysPath != "" {
+		keys, err := transport.LoadAPIKeys(cfg.APIKeysPath)
+		if err != nil {
+			return nil, err
+		}
+		qb.keys = keys
+		opts = append(opts, transport.WithAPIKeys(keys))
+	}
+
+	if cfg.JWTHMACKeyPath != "" || cfg.JWTRSAKeyPath != "" {
+		verifier, err := newJWTVerifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, transport.WithBearerTokens(verifier))
+	}
+
+	if cfg.APIKeysPath == "" && cfg.JWTHMACKeyPath == "" && cfg.JWTRSAKeyPath == "" {
+		qb.log.Warn("app: no API keys and JWT keys, API is open without authentication")
+	}
+
+	if cfg.TLSCertPath != "" {
+		cert, err := server.LoadCertificate(cfg.TLSCertPath, cfg.TLSKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig, err := server.NewTLSConfig(cfg, cert)
+		if err != nil {
+			return nil, err
+		}
+		qb.cert = cert
+		opts = append(opts, transport.WithTLSConfig(tlsConfig))
+	}
 
-	qb.repository = db.NewProvider()
-	qb.service = service.NewService(qb.repository)
-	qb.transport = transport.NewTransport(cfg)
+	qb.transport = transport.NewTransport(cfg, opts...)
 
-	log.Print("app: NewQuotationBook is created")
+	qb.log.Info("app: NewQuotationBook is created")
 
-	return qb
+	return qb, nil
 }
 
-// вызываем 'transport.Routes' для создания маршрутов, и запускаем сервер в горутине
-func (qb *QuotationBook) Run() {
-	log.Print("app: Run Quotation Book")
+// проверка JWT с ключами из файлов 'cfg'
+func newJWTVerifier(cfg *config.Config) (*auth.JWTVerifier, error) {
+	opts := []auth.JWTOption{auth.WithAudience(cfg.JWTAudience), auth.WithClockSkew(cfg.JWTClockSkew)}
+
+	if cfg.JWTHMACKeyPath != "" {
+		key, err := auth.LoadHMACKey(cfg.JWTHMACKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, auth.WithHMACKey(key))
+	}
+
+	if cfg.JWTRSAKeyPath != "" {
+		key, err := auth.LoadRSAPublicKey(cfg.JWTRSAKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, auth.WithRSAKey(key))
+	}
+
+	return auth.NewJWTVerifier(opts...)
+}
+
+// запускаем серверы в горутинах, затем восстанавливаем хранилище из снимка и журнала
+// ('cfg.SnapshotPath' и 'cfg.WALPath'), добавляем маршруты API и отмечаем готовность
+func (qb *QuotationBook) Run() error {
+	qb.log.Info("app: Run Quotation Book")
+
+	qb.serve("public", qb.transport.Srv)
+	if qb.transport.Admin != nil {
+		qb.serve("admin", *qb.transport.Admin)
+	}
+
+	repository, err := db.NewProvider(
+		db.WithSnapshot(qb.cfg.SnapshotPath, qb.cfg.SnapshotInterval),
+		db.WithWAL(qb.cfg.WALPath, qb.cfg.WALMaxSize),
+		db.WithLogger(qb.log),
+		db.WithObserver(metrics.NewStoreMetrics(qb.metrics)),
+	)
+	if err != nil {
+		return err
+	}
+	metrics.NewStoreStats(qb.metrics,
+		func() int { return repository.Stats().Quotes },
+		func() int { return repository.Stats().Authors })
+
+	qb.repository = repository
+	qb.service = service.NewService(qb.repository, qb.log)
 
 	qb.transport.Routes(qb.service)
+	qb.transport.Ready(qb.repository.Stats)
+
+	qb.log.Info("app: Quotation Book is ready")
 
+	return nil
+}
+
+// 'ListenAndServe' в горутине
+func (qb *QuotationBook) serve(name string, srv server.Srv) {
 	go func() {
-		log.Print("app: listen and serve - start")
-		if err := qb.transport.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("go app: Run error - {%v};", err)
+		qb.log.Info("app: listen and serve - start", "server", name, "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			qb.log.Error("app: Run", "server", name, "error", err)
+			os.Exit(1)
 		}
-		log.Print("app: listen and serve - end")
+		qb.log.Info("app: listen and serve - end", "server", name)
 	}()
 }
 
-// запуск 'Shutdown' при помощи 'context'
+// применяем перечитанные настройки 'next' без перезапуска серверов и без потери хранилища:
+// уровень лога, бюджеты клиентов, ключи API (файл перечитывается) и сертификат TLS
+// ошибка в файле ключей или сертификата -> остаются прежние, ошибка возвращается
+// остальные изменившиеся настройки вступят в силу после перезапуска - они пишутся в лог
+func (qb *QuotationBook) Reload(next *config.Config) error {
+	applied := *qb.cfg
+	var errs []error
+
+	if qb.level != nil {
+		qb.level.Set(next.LogLevel)
+		applied.LogLevel = next.LogLevel
+	}
+
+	qb.transport.SetRateLimits(next)
+	applied.RateLimitRead, applied.RateLimitReadBurst = next.RateLimitRead, next.RateLimitReadBurst
+	applied.RateLimitWrite, applied.RateLimitWriteBurst = next.RateLimitWrite, next.RateLimitWriteBurst
+	applied.RateLimitAuth, applied.RateLimitAuthBurst = next.RateLimitAuth, next.RateLimitAuthBurst
+
+	if qb.keys != nil && next.APIKeysPath == qb.cfg.APIKeysPath {
+		if err := qb.keys.Reload(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// путь к сертификату можно сменить, включить или выключить TLS - только перезапуском
+	if qb.cert != nil && next.TLSCertPath != "" {
+		if err := qb.cert.Reload(next.TLSCertPath, next.TLSKeyPath); err != nil {
+			errs = append(errs, err)
+		} else {
+			applied.TLSCertPath, applied.TLSKeyPath = next.TLSCertPath, next.TLSKeyPath
+		}
+	}
+
+	restart := restartRequired(&applied, next)
+	if len(restart) > 0 {
+		qb.log.Warn("app: Reload settings require restart", "settings", restart)
+	}
+
+	qb.cfg = &applied
+
+	qb.log.Info("app: Reload is done", "log_level", applied.LogLevel)
+
+	return errors.Join(errs...)
+}
+
+// ENV настроек, которые в 'next' отличаются от действующих 'cfg'
+func restartRequired(cfg, next *config.Config) []string {
+	settings := []struct {
+		name    string
+		changed bool
+	}{
+		{"SERVER_HOST", cfg.ServerHost != next.ServerHost},
+		{"SERVER_PORT", cfg.ServerPort != next.ServerPort},
+		{"ADMIN_PORT", cfg.AdminPort != next.AdminPort},
+		{"READ_HEADER_TIMEOUT", cfg.ReadHeaderTimeout != next.ReadHeaderTimeout},
+		{"READ_TIMEOUT", cfg.ReadTimeout != next.ReadTimeout},
+		{"WRITE_TIMEOUT", cfg.WriteTimeout != next.WriteTimeout},
+		{"IDLE_TIMEOUT", cfg.IdleTimeout != next.IdleTimeout},
+		{"MAX_HEADER_BYTES", cfg.MaxHeaderBytes != next.MaxHeaderBytes},
+		{"TLS_CERT_PATH", cfg.TLSCertPath != next.TLSCertPath},
+		{"TLS_KEY_PATH", cfg.TLSKeyPath != next.TLSKeyPath},
+		{"TLS_MIN_VERSION", cfg.TLSMinVersion != next.TLSMinVersion},
+		{"TLS_CLIENT_CA_PATH", cfg.TLSClientCAPath != next.TLSClientCAPath},
+		{"SNAPSHOT_PATH", cfg.SnapshotPath != next.SnapshotPath},
+		{"SNAPSHOT_INTERVAL", cfg.SnapshotInterval != next.SnapshotInterval},
+		{"WAL_PATH", cfg.WALPath != next.WALPath},
+		{"WAL_MAX_SIZE", cfg.WALMaxSize != next.WALMaxSize},
+		{"IDEMPOTENCY_TTL", cfg.IdempotencyTTL != next.IdempotencyTTL},
+		{"IDEMPOTENCY_MAX_KEYS", cfg.IdempotencyMaxKeys != next.IdempotencyMaxKeys},
+		{"MAX_BODY_SIZE", cfg.MaxBodySize != next.MaxBodySize},
+		{"MAX_IMPORT_SIZE", cfg.MaxImportSize != next.MaxImportSize},
+		{"API_KEYS_PATH", cfg.APIKeysPath != next.APIKeysPath},
+		{"JWT_HMAC_KEY_PATH", cfg.JWTHMACKeyPath != next.JWTHMACKeyPath},
+		{"JWT_RSA_PUBLIC_KEY_PATH", cfg.JWTRSAKeyPath != next.JWTRSAKeyPath},
+		{"JWT_AUDIENCE", cfg.JWTAudience != next.JWTAudience},
+		{"JWT_CLOCK_SKEW", cfg.JWTClockSkew != next.JWTClockSkew},
+		{"TRUSTED_PROXIES", !slices.Equal(cfg.TrustedProxies, next.TrustedProxies)},
+		{"LOG_LEVEL", cfg.LogLevel != next.LogLevel},
+		{"LOG_FORMAT", cfg.LogFormat != next.LogFormat},
+	}
+
+	var restart []string
+	for _, setting := range settings {
+		if setting.changed {
+			restart = append(restart, setting.name)
+		}
+	}
+	return restart
+}
+
+// снимаем готовность и запускаем 'Shutdown' при помощи 'context'
+// после остановки сервера сохраняем снимок хранилища, admin сервер останавливаем последним
 func (qb *QuotationBook) Stop() {
-	log.Print("app: Stop Quotation Book")
+	qb.log.Info("app: Stop Quotation Book")
+
+	qb.transport.NotReady()
 
 	ctx, cancel := context.WithTimeout(context.Background(), server.TimeoutShut)
 	defer cancel()
 
 	if err := qb.transport.Shutdown(ctx); err != nil {
-		log.Fatalf("app: Stop Shutdown error - {%v};", err)
+		qb.log.Error("app: Stop Shutdown", "error", err)
+		os.Exit(1)
+	}
+
+	if qb.repository != nil {
+		if err := qb.repository.Close(); err != nil {
+			qb.log.Error("app: Stop repository Close", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if qb.transport.Admin != nil {
+		if err := qb.transport.Admin.Shutdown(ctx); err != nil {
+			qb.log.Error("app: Stop admin Shutdown", "error", err)
+			os.Exit(1)
+		}
 	}
 
-	log.Print("app: shutdown complete")
+	qb.log.Info("app: shutdown complete")
 }
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"log/slog"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
+)
+
+func Test_restartRequired(t *testing.T) {
+	cfg := config.Config{
+		ServerHost:     "127.0.0.1",
+		ServerPort:     "8080",
+		ReadTimeout:    time.Minute,
+		RateLimitRead:  50,
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		LogLevel:       slog.LevelInfo,
+		LogFormat:      "text",
+	}
+
+	testData := []struct {
+		title            string
+		change           func(next *config.Config)
+		expectedSettings []string
+	}{
+		{
+			title:  `nothing changed`,
+			change: func(next *config.Config) {},
+		},
+		{
+			title: `reloadable only`,
+			change: func(next *config.Config) {
+				next.RateLimitRead = 10
+				next.RateLimitWriteBurst = 5
+			},
+		},
+		{
+			title: `listener and log format`,
+			change: func(next *config.Config) {
+				next.ServerPort = "8443"
+				next.ReadTimeout = 30 * time.Second
+				next.LogFormat = "json"
+			},
+			expectedSettings: []string{"SERVER_PORT", "READ_TIMEOUT", "LOG_FORMAT"},
+		},
+		{
+			title: `trusted proxies`,
+			change: func(next *config.Config) {
+				next.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+			},
+			expectedSettings: []string{"TRUSTED_PROXIES"},
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			next := cfg
+			test.change(&next)
+
+			if got := restartRequired(&cfg, &next); !reflect.DeepEqual(got, test.expectedSettings) {
+				t.Errorf("settings {got}:{want} {%v}:{%v};", got, test.expectedSettings)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// клиент запроса после аутентификации, передается слоям через контекст
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// клиент запроса
+// 'Subject' - имя ключа API или 'sub' токена, 'Claims' - данные токена, для ключа API - nil
+type Principal struct {
+	Subject string
+	Scopes  []string
+	Claims  *Claims
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// проверенные данные JWT
+// 'Scopes' - из 'scope' через пробел, нулевое время -> поля нет в токене
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	Scopes    []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+}
+
+type principalKey struct{}
+
+// 'ctx' с клиентом 'p'
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// клиент из 'ctx', аутентификации не было -> nil
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// 'Subject' клиента из 'ctx', аутентификации не было -> пустая строка
+func Subject(ctx context.Context) string {
+	if p := FromContext(ctx); p != nil {
+		return p.Subject
+	}
+	return ""
+}
@@ -0,0 +1,322 @@
+// проверка JWT (RFC 7519) с подписью HS256 или RS256
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// алгоритмы подписи
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// наименьшая длина ключа HS256 (RFC 7518, 3.2)
+const MinHMACKeySize = sha256.Size
+
+// допуск расхождения часов, если не задан 'WithClockSkew'
+const DefaultClockSkew = 30 * time.Second
+
+var (
+	// токен не разбирается, подпись или алгоритм неверные
+	ErrTokenInvalid = errors.New("invalid token")
+
+	// 'exp' прошел
+	ErrTokenExpired = errors.New("token expired")
+
+	// 'nbf' еще не наступил
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+
+	// в 'aud' нет аудитории сервиса
+	ErrTokenAudience = errors.New("token audience mismatch")
+
+	// ключ из файла не подходит
+	ErrKeyInvalid = errors.New("invalid key")
+)
+
+// проверка подписи и сроков JWT
+// алгоритм берется из заголовка токена, но только тот, для которого задан ключ
+type JWTVerifier struct {
+	hmacKey []byte
+	rsaKey  *rsa.PublicKey
+
+	// 'aud' токена должен содержать 'audience', пустая -> 'aud' не проверяется
+	audience string
+	skew     time.Duration
+
+	// источник времени, для тестов
+	now func() time.Time
+}
+
+// настройка JWTVerifier
+type JWTOption func(*JWTVerifier)
+
+// ключ для HS256
+func WithHMACKey(key []byte) JWTOption {
+	return func(v *JWTVerifier) {
+		v.hmacKey = key
+	}
+}
+
+// открытый ключ для RS256
+func WithRSAKey(key *rsa.PublicKey) JWTOption {
+	return func(v *JWTVerifier) {
+		v.rsaKey = key
+	}
+}
+
+// аудитория сервиса для проверки 'aud'
+func WithAudience(audience string) JWTOption {
+	return func(v *JWTVerifier) {
+		v.audience = audience
+	}
+}
+
+// допуск расхождения часов для 'exp' и 'nbf'
+func WithClockSkew(skew time.Duration) JWTOption {
+	return func(v *JWTVerifier) {
+		v.skew = skew
+	}
+}
+
+// источник времени вместо 'time.Now'
+func WithClock(now func() time.Time) JWTOption {
+	return func(v *JWTVerifier) {
+		v.now = now
+	}
+}
+
+// конструктор JWTVerifier, без ключей -> 'ErrKeyInvalid'
+func NewJWTVerifier(opts ...JWTOption) (*JWTVerifier, error) {
+	v := &JWTVerifier{skew: DefaultClockSkew, now: time.Now}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	if v.hmacKey == nil && v.rsaKey == nil {
+		return nil, fmt.Errorf("%w: no keys", ErrKeyInvalid)
+	}
+	if v.hmacKey != nil && len(v.hmacKey) < MinHMACKeySize {
+		return nil, fmt.Errorf("%w: hmac key shorter than %d bytes", ErrKeyInvalid, MinHMACKeySize)
+	}
+
+	return v, nil
+}
+
+// ключ HS256 из файла, перевод строки в конце отбрасывается
+func LoadHMACKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+// открытый ключ RS256 из PEM: "PUBLIC KEY" (PKIX) или "RSA PUBLIC KEY" (PKCS #1)
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no pem block", ErrKeyInvalid)
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeyInvalid, err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: not rsa key", ErrKeyInvalid)
+		}
+		return rsaKey, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeyInvalid, err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected pem block %q", ErrKeyInvalid, block.Type)
+	}
+}
+
+// заголовок JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// поля JWT, 'aud' - строка или массив строк
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	Scope     string          `json:"scope"`
+	ExpiresAt *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+	IssuedAt  *json.Number    `json:"iat"`
+}
+
+// проверка подписи, 'exp' (обязателен), 'nbf', 'aud' и 'sub' (обязателен)
+// ошибка подписи или формата -> 'ErrTokenInvalid', сроки -> 'ErrTokenExpired', 'ErrTokenNotYetValid'
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 parts", ErrTokenInvalid)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header - %w", ErrTokenInvalid, err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature - %w", ErrTokenInvalid, err)
+	}
+
+	if err := v.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var raw jwtClaims
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: claims - %w", ErrTokenInvalid, err)
+	}
+
+	return v.validate(raw)
+}
+
+// подпись алгоритмом 'alg', для алгоритма нет ключа -> 'ErrTokenInvalid'
+func (v *JWTVerifier) verifySignature(alg, signed string, signature []byte) error {
+	switch {
+	case alg == AlgHS256 && v.hmacKey != nil:
+		mac := hmac.New(sha256.New, v.hmacKey)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return fmt.Errorf("%w: signature mismatch", ErrTokenInvalid)
+		}
+		return nil
+	case alg == AlgRS256 && v.rsaKey != nil:
+		sum := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(v.rsaKey, crypto.SHA256, sum[:], signature); err != nil {
+			return fmt.Errorf("%w: signature mismatch", ErrTokenInvalid)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unexpected alg %q", ErrTokenInvalid, alg)
+	}
+}
+
+// сроки с допуском 'skew', аудитория и 'sub'
+func (v *JWTVerifier) validate(raw jwtClaims) (*Claims, error) {
+	claims := &Claims{Subject: raw.Subject, Issuer: raw.Issuer}
+	if raw.Scope != "" {
+		claims.Scopes = strings.Fields(raw.Scope)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: sub is required", ErrTokenInvalid)
+	}
+
+	var err error
+	if raw.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: exp is required", ErrTokenInvalid)
+	}
+	if claims.ExpiresAt, err = numericDate(*raw.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("%w: exp - %w", ErrTokenInvalid, err)
+	}
+	if raw.NotBefore != nil {
+		if claims.NotBefore, err = numericDate(*raw.NotBefore); err != nil {
+			return nil, fmt.Errorf("%w: nbf - %w", ErrTokenInvalid, err)
+		}
+	}
+	if raw.IssuedAt != nil {
+		if claims.IssuedAt, err = numericDate(*raw.IssuedAt); err != nil {
+			return nil, fmt.Errorf("%w: iat - %w", ErrTokenInvalid, err)
+		}
+	}
+	if claims.Audience, err = audience(raw.Audience); err != nil {
+		return nil, fmt.Errorf("%w: aud - %w", ErrTokenInvalid, err)
+	}
+
+	now := v.now()
+	if !now.Before(claims.ExpiresAt.Add(v.skew)) {
+		return nil, ErrTokenExpired
+	}
+	if !claims.NotBefore.IsZero() && now.Add(v.skew).Before(claims.NotBefore) {
+		return nil, ErrTokenNotYetValid
+	}
+	if v.audience != "" && !slices.Contains(claims.Audience, v.audience) {
+		return nil, ErrTokenAudience
+	}
+
+	return claims, nil
+}
+
+// часть токена: base64url без выравнивания, затем JSON
+func decodeSegment(segment string, obj any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(obj)
+}
+
+// секунды от начала эпохи, допускается дробная часть
+func numericDate(n json.Number) (time.Time, error) {
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, errors.New("not a number")
+	}
+
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+}
+
+// 'aud' - строка или массив строк, нет поля -> nil
+func audience(data json.RawMessage) ([]string, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		return []string{one}, nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return nil, err
+	}
+	return many, nil
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ключ HS256 для тестов, 32 байта
+var testHMACKey = []byte("0123456789abcdef0123456789abcdef")
+
+// JWT с заголовком 'header' и полями 'claims', подпись 'alg' ключом 'key'
+func signToken(t *testing.T, header, claims map[string]any, key any) string {
+	t.Helper()
+
+	segment := func(obj map[string]any) string {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			t.Fatalf("json.Marshal error - {%v};", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(header) + "." + segment(claims)
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatalf("rsa.SignPKCS1v15 error - {%v};", err)
+		}
+		signature = sig
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey error - {%v};", err)
+	}
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	verifier, err := NewJWTVerifier(
+		WithHMACKey(testHMACKey),
+		WithRSAKey(&rsaKey.PublicKey),
+		WithAudience("quotebook"),
+		WithClockSkew(30*time.Second),
+		WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("NewJWTVerifier error - {%v};", err)
+	}
+
+	// только RS256 - токен HS256 с открытым ключом вместо секрета не проходит
+	rsaOnly, err := NewJWTVerifier(WithRSAKey(&rsaKey.PublicKey), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("NewJWTVerifier error - {%v};", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("x509.MarshalPKIXPublicKey error - {%v};", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	rs256 := map[string]any{"alg": "RS256", "typ": "JWT"}
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{"sub": "gateway-user", "aud": "quotebook", "exp": now.Add(time.Minute).Unix()}
+		for k, v := range extra {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	testData := []struct {
+		title          string
+		verifier       *JWTVerifier
+		token          string
+		expectedClaims *Claims
+		expectedErr    error
+	}{
+		{
+			title:    `hs256`,
+			verifier: verifier,
+			token: signToken(t, hs256, claims(map[string]any{
+				"scope": "quotes:read quotes:write", "iss": "gateway", "iat": now.Unix(),
+			}), testHMACKey),
+			expectedClaims: &Claims{
+				Subject:   "gateway-user",
+				Issuer:    "gateway",
+				Audience:  []string{"quotebook"},
+				Scopes:    []string{"quotes:read", "quotes:write"},
+				ExpiresAt: now.Add(time.Minute),
+				IssuedAt:  now,
+			},
+		},
+		{
+			title:    `rs256 with audience list`,
+			verifier: verifier,
+			token:    signToken(t, rs256, claims(map[string]any{"aud": []string{"other", "quotebook"}}), rsaKey),
+			expectedClaims: &Claims{
+				Subject:   "gateway-user",
+				Audience:  []string{"other", "quotebook"},
+				ExpiresAt: now.Add(time.Minute),
+			},
+		},
+		{
+			title:       `expired`,
+			verifier:    verifier,
+			token:       signToken(t, hs256, claims(map[string]any{"exp": now.Add(-time.Minute).Unix()}), testHMACKey),
+			expectedErr: ErrTokenExpired,
+		},
+		{
+			title:    `expired within skew`,
+			verifier: verifier,
+			token:    signToken(t, hs256, claims(map[string]any{"exp": now.Add(-10 * time.Second).Unix()}), testHMACKey),
+			expectedClaims: &Claims{
+				Subject:   "gateway-user",
+				Audience:  []string{"quotebook"},
+				ExpiresAt: now.Add(-10 * time.Second),
+			},
+		},
+		{
+			title:       `not yet valid`,
+			verifier:    verifier,
+			token:       signToken(t, hs256, claims(map[string]any{"nbf": now.Add(time.Minute).Unix()}), testHMACKey),
+			expectedErr: ErrTokenNotYetValid,
+		},
+		{
+			title:       `other audience`,
+			verifier:    verifier,
+			token:       signToken(t, hs256, claims(map[string]any{"aud": "billing"}), testHMACKey),
+			expectedErr: ErrTokenAudience,
+		},
+		{
+			title:       `no audience`,
+			verifier:    verifier,
+			token:       signToken(t, hs256, claims(map[string]any{"aud": nil}), testHMACKey),
+			expectedErr: ErrTokenAudience,
+		},
+		{
+			title:       `no exp`,
+			verifier:    verifier,
+			token:       signToken(t, hs256, claims(map[string]any{"exp": nil}), testHMACKey),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			title:       `no sub`,
+			verifier:    verifier,
+			token:       signToken(t, hs256, claims(map[string]any{"sub": nil}), testHMACKey),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			title:       `wrong hmac key`,
+			verifier:    verifier,
+			token:       signToken(t, hs256, claims(nil), []byte("fedcba9876543210fedcba9876543210")),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			title:       `alg none`,
+			verifier:    verifier,
+			token:       signToken(t, map[string]any{"alg": "none"}, claims(nil), nil),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			title:       `hs256 signed with public key`,
+			verifier:    rsaOnly,
+			token:       signToken(t, hs256, claims(nil), publicPEM),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			title:       `malformed`,
+			verifier:    verifier,
+			token:       `not.a-token`,
+			expectedErr: ErrTokenInvalid,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			got, err := test.verifier.Verify(test.token)
+
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("error {got}:{want} {%v}:{%v};", err, test.expectedErr)
+			}
+			if !reflect.DeepEqual(got, test.expectedClaims) {
+				t.Errorf("claims {got}:{want} {%+v}:{%+v};", got, test.expectedClaims)
+			}
+		})
+	}
+}
+
+func Test_LoadKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey error - {%v};", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("x509.MarshalPKIXPublicKey error - {%v};", err)
+	}
+
+	files := map[string][]byte{
+		"pkix.pem":   pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+		"pkcs1.pem":  pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)}),
+		"secret.pem": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		"hmac":       append(testHMACKey, '\n'),
+		"short":      []byte("secret\n"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatalf("os.WriteFile error - {%v};", err)
+		}
+	}
+
+	for _, name := range []string{"pkix.pem", "pkcs1.pem"} {
+		key, err := LoadRSAPublicKey(filepath.Join(dir, name))
+		if err != nil || !key.Equal(&rsaKey.PublicKey) {
+			t.Errorf("LoadRSAPublicKey %s {got}:{want} {%v}:{<nil>};", name, err)
+		}
+	}
+	if _, err := LoadRSAPublicKey(filepath.Join(dir, "secret.pem")); !errors.Is(err, ErrKeyInvalid) {
+		t.Errorf("LoadRSAPublicKey private key error {got}:{want} {%v}:{%v};", err, ErrKeyInvalid)
+	}
+
+	key, err := LoadHMACKey(filepath.Join(dir, "hmac"))
+	if err != nil || string(key) != string(testHMACKey) {
+		t.Errorf("LoadHMACKey {got}:{want} {%s %v}:{%s <nil>};", key, err, testHMACKey)
+	}
+
+	short, err := LoadHMACKey(filepath.Join(dir, "short"))
+	if err != nil {
+		t.Fatalf("LoadHMACKey error - {%v};", err)
+	}
+	if _, err := NewJWTVerifier(WithHMACKey(short)); !errors.Is(err, ErrKeyInvalid) {
+		t.Errorf("NewJWTVerifier short key error {got}:{want} {%v}:{%v};", err, ErrKeyInvalid)
+	}
+	if _, err := NewJWTVerifier(); !errors.Is(err, ErrKeyInvalid) {
+		t.Errorf("NewJWTVerifier no keys error {got}:{want} {%v}:{%v};", err, ErrKeyInvalid)
+	}
+}
@@ -3,21 +3,145 @@ package config
 
 import (
 	"bufio"
+	"crypto/tls"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
 )
 
 var ErrConfigDataInvalid = errors.New("invalid file data")
 
 // необходимые данные для запуска
 type Config struct {
+	// значения из файла, перекрывают ENV
+	file map[string]string
+
 	ServerHost string
 	ServerPort string
+
+	// порт для проб и метрик, пустой -> они на 'ServerPort'
+	AdminPort string
+
+	// время на чтение заголовков, всего запроса, запись ответа и ожидание следующего запроса
+	// на keep-alive соединении, 0 -> без ограничения
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// наибольший размер заголовков запроса в байтах
+	MaxHeaderBytes int
+
+	// сертификат и ключ сервера (PEM), оба пустые -> сервер без TLS
+	TLSCertPath string
+	TLSKeyPath  string
+	// наименьшая версия TLS: 'tls.VersionTLS12' или 'tls.VersionTLS13'
+	TLSMinVersion uint16
+	// сертификаты CA клиентов (PEM), пустой -> сертификат клиента не запрашивается
+	TLSClientCAPath string
+
+	// файл для снимка хранилища, пустой -> данные только в памяти
+	SnapshotPath string
+	// как часто сохраняем снимок
+	SnapshotInterval time.Duration
+
+	// журнал изменений, пустой -> журнал не ведется
+	WALPath string
+	// размер журнала в байтах, после которого он сворачивается в снимок
+	WALMaxSize int64
+
+	// сколько хранится ответ по 'Idempotency-Key'
+	IdempotencyTTL time.Duration
+	// наибольшее число ключей идемпотентности в памяти
+	IdempotencyMaxKeys int
+
+	// наибольший размер тела запроса в байтах
+	MaxBodySize int64
+	// наибольший размер тела импорта в байтах
+	MaxImportSize int64
+
+	// файл ключей API (SHA-256 ключей и права), пустой -> API без аутентификации
+	APIKeysPath string
+
+	// ключ HS256 и открытый ключ RS256 (PEM) для JWT, оба пустые -> JWT не принимаются
+	JWTHMACKeyPath string
+	JWTRSAKeyPath  string
+	// аудитория сервиса в 'aud' токена, пустая -> 'aud' не проверяется
+	JWTAudience string
+	// допуск расхождения часов для 'exp' и 'nbf'
+	JWTClockSkew time.Duration
+
+	// бюджет клиента на чтение: запросов в секунду и подряд, 0 -> без ограничения
+	RateLimitRead      float64
+	RateLimitReadBurst int
+	// бюджет клиента на изменение и удаление
+	RateLimitWrite      float64
+	RateLimitWriteBurst int
+	// бюджет неудачных аутентификаций на адрес клиента
+	RateLimitAuth      float64
+	RateLimitAuthBurst int
+	// прокси, которым верим "X-Forwarded-For": адреса или подсети CIDR
+	TrustedProxies []netip.Prefix
+
+	// наименьший уровень записей лога
+	LogLevel slog.Level
+	// формат лога: "text" или "json"
+	LogFormat string
 }
 
+const (
+	// используются если таймауты сервера не заданы
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = time.Minute
+	defaultWriteTimeout      = time.Minute
+	defaultIdleTimeout       = 2 * time.Minute
+
+	// используется если 'MAX_HEADER_BYTES' не задан, как в 'net/http'
+	defaultMaxHeaderBytes = 1 << 20
+
+	// используется если 'TLS_MIN_VERSION' не задан
+	defaultTLSMinVersion = tls.VersionTLS12
+
+	// используется если 'SNAPSHOT_INTERVAL' не задан
+	defaultSnapshotInterval = time.Minute
+
+	// используется если 'WAL_MAX_SIZE' не задан
+	defaultWALMaxSize = 16 << 20
+
+	// используется если 'IDEMPOTENCY_TTL' не задан
+	defaultIdempotencyTTL = 24 * time.Hour
+
+	// используется если 'IDEMPOTENCY_MAX_KEYS' не задан
+	defaultIdempotencyMaxKeys = 100000
+
+	// используется если 'MAX_BODY_SIZE' не задан
+	defaultMaxBodySize = 1 << 20
+
+	// используется если 'MAX_IMPORT_SIZE' не задан
+	defaultMaxImportSize = 32 << 20
+
+	// используется если 'JWT_CLOCK_SKEW' не задан
+	defaultJWTClockSkew = 30 * time.Second
+
+	// используются если 'RATE_LIMIT_*' не заданы
+	defaultRateLimitRead       = 50
+	defaultRateLimitReadBurst  = 100
+	defaultRateLimitWrite      = 10
+	defaultRateLimitWriteBurst = 20
+	defaultRateLimitAuth       = 1
+	defaultRateLimitAuthBurst  = 10
+
+	// используется если 'LOG_FORMAT' не задан
+	defaultLogFormat = logging.FormatText
+)
+
 // парсим файл, заполняем поля и проверяем на корректность
 func NewConfig(patToFile string) (*Config, error) {
 	cfg := &Config{}
@@ -26,7 +150,9 @@ func NewConfig(patToFile string) (*Config, error) {
 		return nil, err
 	}
 
-	cfg.unmarshal()
+	if err := cfg.unmarshal(); err != nil {
+		return nil, err
+	}
 
 	if !cfg.valid() {
 		return nil, ErrConfigDataInvalid
@@ -36,10 +162,11 @@ func NewConfig(patToFile string) (*Config, error) {
 }
 
 // .env не найден —> идем читать из ENV
-// .env найден —> создаем ENV
+// .env найден —> значения из него в 'file', ENV не меняется
+// поэтому ключ, удаленный из файла, при перечитывании снова берется из ENV или по умолчанию
 func (cfg *Config) parse(patToFile string) error {
 	if _, err := os.Stat(patToFile); os.IsNotExist(err) {
-		log.Print("config: file not found, used ENV")
+		slog.Info("config: file not found, used ENV")
 		return nil
 	}
 
@@ -49,6 +176,8 @@ func (cfg *Config) parse(patToFile string) error {
 	}
 	defer file.Close()
 
+	cfg.file = make(map[string]string)
+
 	scan := bufio.NewScanner(file)
 
 	for scan.Scan() {
@@ -64,21 +193,133 @@ func (cfg *Config) parse(patToFile string) error {
 
 		key, val := parts[0], parts[1]
 
-		if err := os.Setenv(key, val); err != nil {
-			return err
-		}
+		cfg.file[key] = val
 
-		log.Printf("config: setenv - {key}:{value} {%s}:{%s};", key, val)
+		slog.Debug("config: file value", "key", key)
 	}
 
-	log.Print("config: end parse file")
+	if err := scan.Err(); err != nil {
+		return err
+	}
+
+	slog.Info("config: end parse file", "path", patToFile)
 
 	return nil
 }
 
-func (cfg *Config) unmarshal() {
-	cfg.ServerHost = os.Getenv("SERVER_HOST")
-	cfg.ServerPort = os.Getenv("SERVER_PORT")
+// значение из файла, в файле нет -> из ENV
+func (cfg *Config) getenv(key string) string {
+	if val, ex := cfg.file[key]; ex {
+		return val
+	}
+	return os.Getenv(key)
+}
+
+// разбор значения 'key' в 'dst', пустое -> 'def'
+// ошибка разбора -> 'ErrConfigDataInvalid' с именем ключа
+func (cfg *Config) durationEnv(dst *time.Duration, key string, def time.Duration) error {
+	return parseEnv(cfg, dst, key, def, time.ParseDuration)
+}
+
+func (cfg *Config) intEnv(dst *int, key string, def int) error {
+	return parseEnv(cfg, dst, key, def, strconv.Atoi)
+}
+
+func (cfg *Config) int64Env(dst *int64, key string, def int64) error {
+	return parseEnv(cfg, dst, key, def, func(val string) (int64, error) { return strconv.ParseInt(val, 10, 64) })
+}
+
+func (cfg *Config) floatEnv(dst *float64, key string, def float64) error {
+	return parseEnv(cfg, dst, key, def, func(val string) (float64, error) { return strconv.ParseFloat(val, 64) })
+}
+
+// общая часть '*Env'
+func parseEnv[T any](cfg *Config, dst *T, key string, def T, parse func(string) (T, error)) error {
+	*dst = def
+
+	val := cfg.getenv(key)
+	if val == "" {
+		return nil
+	}
+
+	v, err := parse(val)
+	if err != nil {
+		return fmt.Errorf("%w: %s=%q", ErrConfigDataInvalid, key, val)
+	}
+	*dst = v
+
+	return nil
+}
+
+// заполняем поля, все ошибки разбора чисел и длительностей возвращаются вместе
+func (cfg *Config) unmarshal() error {
+	cfg.ServerHost = cfg.getenv("SERVER_HOST")
+	cfg.ServerPort = cfg.getenv("SERVER_PORT")
+	cfg.AdminPort = cfg.getenv("ADMIN_PORT")
+	cfg.TLSCertPath = cfg.getenv("TLS_CERT_PATH")
+	cfg.TLSKeyPath = cfg.getenv("TLS_KEY_PATH")
+	cfg.TLSClientCAPath = cfg.getenv("TLS_CLIENT_CA_PATH")
+	cfg.SnapshotPath = cfg.getenv("SNAPSHOT_PATH")
+	cfg.WALPath = cfg.getenv("WAL_PATH")
+	cfg.APIKeysPath = cfg.getenv("API_KEYS_PATH")
+	cfg.JWTHMACKeyPath = cfg.getenv("JWT_HMAC_KEY_PATH")
+	cfg.JWTRSAKeyPath = cfg.getenv("JWT_RSA_PUBLIC_KEY_PATH")
+	cfg.JWTAudience = cfg.getenv("JWT_AUDIENCE")
+
+	if err := errors.Join(
+		cfg.durationEnv(&cfg.ReadHeaderTimeout, "READ_HEADER_TIMEOUT", defaultReadHeaderTimeout),
+		cfg.durationEnv(&cfg.ReadTimeout, "READ_TIMEOUT", defaultReadTimeout),
+		cfg.durationEnv(&cfg.WriteTimeout, "WRITE_TIMEOUT", defaultWriteTimeout),
+		cfg.durationEnv(&cfg.IdleTimeout, "IDLE_TIMEOUT", defaultIdleTimeout),
+		cfg.intEnv(&cfg.MaxHeaderBytes, "MAX_HEADER_BYTES", defaultMaxHeaderBytes),
+		cfg.durationEnv(&cfg.SnapshotInterval, "SNAPSHOT_INTERVAL", defaultSnapshotInterval),
+		cfg.int64Env(&cfg.WALMaxSize, "WAL_MAX_SIZE", defaultWALMaxSize),
+		cfg.durationEnv(&cfg.IdempotencyTTL, "IDEMPOTENCY_TTL", defaultIdempotencyTTL),
+		cfg.intEnv(&cfg.IdempotencyMaxKeys, "IDEMPOTENCY_MAX_KEYS", defaultIdempotencyMaxKeys),
+		cfg.int64Env(&cfg.MaxBodySize, "MAX_BODY_SIZE", defaultMaxBodySize),
+		cfg.int64Env(&cfg.MaxImportSize, "MAX_IMPORT_SIZE", defaultMaxImportSize),
+		cfg.durationEnv(&cfg.JWTClockSkew, "JWT_CLOCK_SKEW", defaultJWTClockSkew),
+		cfg.floatEnv(&cfg.RateLimitRead, "RATE_LIMIT_READ", defaultRateLimitRead),
+		cfg.intEnv(&cfg.RateLimitReadBurst, "RATE_LIMIT_READ_BURST", defaultRateLimitReadBurst),
+		cfg.floatEnv(&cfg.RateLimitWrite, "RATE_LIMIT_WRITE", defaultRateLimitWrite),
+		cfg.intEnv(&cfg.RateLimitWriteBurst, "RATE_LIMIT_WRITE_BURST", defaultRateLimitWriteBurst),
+		cfg.floatEnv(&cfg.RateLimitAuth, "RATE_LIMIT_AUTH", defaultRateLimitAuth),
+		cfg.intEnv(&cfg.RateLimitAuthBurst, "RATE_LIMIT_AUTH_BURST", defaultRateLimitAuthBurst),
+	); err != nil {
+		return err
+	}
+
+	cfg.TLSMinVersion = defaultTLSMinVersion
+	if version := cfg.getenv("TLS_MIN_VERSION"); version != "" {
+		switch version {
+		case "1.2":
+			cfg.TLSMinVersion = tls.VersionTLS12
+		case "1.3":
+			cfg.TLSMinVersion = tls.VersionTLS13
+		default:
+			return fmt.Errorf("%w: TLS_MIN_VERSION=%q", ErrConfigDataInvalid, version)
+		}
+	}
+
+	proxies, err := parseTrustedProxies(cfg.getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return fmt.Errorf("%w: TRUSTED_PROXIES: %v", ErrConfigDataInvalid, err)
+	}
+	cfg.TrustedProxies = proxies
+
+	// уровень по умолчанию - нулевой 'slog.LevelInfo'
+	if level := cfg.getenv("LOG_LEVEL"); level != "" {
+		if err := cfg.LogLevel.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("%w: LOG_LEVEL=%q", ErrConfigDataInvalid, level)
+		}
+	}
+
+	cfg.LogFormat = defaultLogFormat
+	if format := cfg.getenv("LOG_FORMAT"); format != "" {
+		cfg.LogFormat = strings.ToLower(format)
+	}
+
+	return nil
 }
 
 // проверяем корректность данных
@@ -92,5 +333,97 @@ func (cfg *Config) valid() bool {
 		return false
 	}
 
+	if cfg.AdminPort != "" {
+		admin, err := strconv.ParseUint(cfg.AdminPort, 10, 16)
+		if err != nil || admin == 0 || admin == port {
+			return false
+		}
+	}
+
+	if cfg.ReadHeaderTimeout < 0 || cfg.ReadTimeout < 0 || cfg.WriteTimeout < 0 || cfg.IdleTimeout < 0 {
+		return false
+	}
+
+	if cfg.MaxHeaderBytes <= 0 {
+		return false
+	}
+
+	// сертификат без ключа и наоборот, CA клиентов без TLS
+	if (cfg.TLSCertPath == "") != (cfg.TLSKeyPath == "") {
+		return false
+	}
+	if cfg.TLSClientCAPath != "" && cfg.TLSCertPath == "" {
+		return false
+	}
+
+	if cfg.SnapshotPath != "" && cfg.SnapshotInterval <= 0 {
+		return false
+	}
+
+	// журнал сворачивается в снимок -> без снимка не работает
+	if cfg.WALPath != "" && (cfg.SnapshotPath == "" || cfg.WALMaxSize <= 0) {
+		return false
+	}
+
+	if cfg.IdempotencyTTL <= 0 || cfg.IdempotencyMaxKeys <= 0 {
+		return false
+	}
+
+	if cfg.MaxBodySize <= 0 || cfg.MaxImportSize <= 0 {
+		return false
+	}
+
+	if cfg.JWTClockSkew < 0 {
+		return false
+	}
+
+	if !validRateLimit(cfg.RateLimitRead, cfg.RateLimitReadBurst) ||
+		!validRateLimit(cfg.RateLimitWrite, cfg.RateLimitWriteBurst) ||
+		!validRateLimit(cfg.RateLimitAuth, cfg.RateLimitAuthBurst) {
+		return false
+	}
+
+	if !logging.ValidFormat(cfg.LogFormat) {
+		return false
+	}
+
 	return true
 }
+
+// 'rate' == 0 -> без ограничения, иначе нужен 'burst' хотя бы на один запрос
+func validRateLimit(rate float64, burst int) bool {
+	if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return false
+	}
+	return rate == 0 || burst >= 1
+}
+
+// список прокси через запятую: адреса или подсети CIDR
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
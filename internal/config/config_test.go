@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_NewConfig_Reload(t *testing.T) {
+	// значение ENV без строки в файле
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("RATE_LIMIT_READ", "")
+
+	path := filepath.Join(t.TempDir(), ".env")
+
+	// файл перезаписывается по порядку, каждый раз настройки читаются заново
+	testData := []struct {
+		title             string
+		data              string
+		expectedLevel     slog.Level
+		expectedRateLimit float64
+	}{
+		{
+			title:             `file overrides env`,
+			data:              "SERVER_HOST=127.0.0.1\r\nSERVER_PORT=8080\r\nLOG_LEVEL=debug\r\nRATE_LIMIT_READ=5\r\n",
+			expectedLevel:     slog.LevelDebug,
+			expectedRateLimit: 5,
+		},
+		{
+			title:             `removed keys fall back to env and defaults`,
+			data:              "SERVER_HOST=127.0.0.1\r\nSERVER_PORT=8080\r\n",
+			expectedLevel:     slog.LevelWarn,
+			expectedRateLimit: defaultRateLimitRead,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(test.data), 0o644); err != nil {
+				t.Fatalf("os.WriteFile error - {%v};", err)
+			}
+
+			cfg, err := NewConfig(path)
+			if err != nil {
+				t.Fatalf("NewConfig: error should be nil - {%v};", err)
+			}
+
+			if cfg.LogLevel != test.expectedLevel {
+				t.Errorf("LogLevel {got}:{want} {%v}:{%v};", cfg.LogLevel, test.expectedLevel)
+			}
+
+			if cfg.RateLimitRead != test.expectedRateLimit {
+				t.Errorf("RateLimitRead {got}:{want} {%v}:{%v};", cfg.RateLimitRead, test.expectedRateLimit)
+			}
+		})
+	}
+
+	// файл не меняет окружение процесса
+	if level := os.Getenv("LOG_LEVEL"); level != "warn" {
+		t.Errorf("LOG_LEVEL env {got}:{want} {%s}:{warn};", level)
+	}
+}
+
+func Test_NewConfig_InvalidNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	data := "SERVER_HOST=127.0.0.1\r\nSERVER_PORT=8080\r\nREAD_TIMEOUT=soon\r\nRATE_LIMIT_WRITE_BURST=many\r\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("os.WriteFile error - {%v};", err)
+	}
+
+	_, err := NewConfig(path)
+	if !errors.Is(err, ErrConfigDataInvalid) {
+		t.Fatalf("NewConfig: errors not equal {got}:{want} {%v}:{%v};", err, ErrConfigDataInvalid)
+	}
+
+	// ошибка называет каждый неверный ключ
+	for _, key := range []string{"READ_TIMEOUT", "RATE_LIMIT_WRITE_BURST"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error has no key %s - {%v};", key, err)
+		}
+	}
+}
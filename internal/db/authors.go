@@ -0,0 +1,139 @@
+// авторы цитат: список с количеством цитат, цитаты автора
+package db
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// параметры страницы для 'AuthorPage'
+// 'After' - курсор, ключ последнего автора предыдущей страницы ("" -> с начала)
+// 'Prefix' - начало имени автора, сравнение по 'utils.NormalizeKey'
+// 'Limit' <= 0 -> все авторы после курсора
+type AuthorQuery struct {
+	Limit  int
+	After  string
+	Prefix string
+}
+
+// автор и количество его цитат
+// 'Key' - ключ 'utils.NormalizeKey', 'Name' - написание из первой цитаты автора
+type AuthorCount struct {
+	Key   string
+	Name  string
+	Count int
+}
+
+// страница авторов по алфавиту ключей
+// 'NextCursor' - 'After' для следующей страницы, "" -> страница последняя
+// 'Total' - количество всех авторов, подходящих под 'Prefix'
+type AuthorPage struct {
+	Authors    []AuthorCount
+	NextCursor string
+	Total      int
+}
+
+// добавляем 'id' в список автора, новый автор попадает в 'authors', вызывается под Lock()
+// 'author' - ключ 'utils.NormalizeKey'
+func (p *provider) linkAuthor(author string, id uint) {
+	quotesID, ex := p.listOfQuoteIDByAuthor[author]
+	if !ex {
+		i := sort.SearchStrings(p.authors, author)
+		p.authors = append(p.authors, "")
+		copy(p.authors[i+1:], p.authors[i:])
+		p.authors[i] = author
+	}
+	p.listOfQuoteIDByAuthor[author] = utils.InsertByValue(quotesID, id)
+}
+
+// удаляем ключ автора без цитат из 'authors', вызывается под Lock()
+func (p *provider) dropAuthor(author string) {
+	i := sort.SearchStrings(p.authors, author)
+	if i < len(p.authors) && p.authors[i] == author {
+		p.authors = append(p.authors[:i], p.authors[i+1:]...)
+	}
+}
+
+// страница авторов из упорядоченного 'authors'
+// начало страницы и границу префикса ищем бинарным поиском, цитаты не перебираем
+func (p *provider) AuthorPage(_ context.Context, query AuthorQuery) (*AuthorPage, error) {
+	defer p.observe("AuthorPage", time.Now())
+
+	prefix := utils.NormalizeKey(query.Prefix)
+
+	p.rwMu.RLock()
+	defer p.rwMu.RUnlock()
+
+	if len(p.authors) == 0 {
+		return nil, ErrDBEmpty
+	}
+
+	// авторы с префиксом идут подряд: [from, to)
+	from := sort.SearchStrings(p.authors, prefix)
+	to := from + sort.Search(len(p.authors)-from, func(i int) bool {
+		return !strings.HasPrefix(p.authors[from+i], prefix)
+	})
+
+	start := from
+	if query.After != "" {
+		start = max(from, sort.Search(len(p.authors), func(i int) bool { return p.authors[i] > query.After }))
+	}
+	start = min(start, to)
+
+	limit := query.Limit
+	if limit <= 0 || limit > to-start {
+		limit = to - start
+	}
+
+	page := &AuthorPage{
+		Authors: make([]AuthorCount, 0, limit),
+		Total:   to - from,
+	}
+
+	for _, author := range p.authors[start : start+limit] {
+		quotesID := p.listOfQuoteIDByAuthor[author]
+		page.Authors = append(page.Authors, AuthorCount{
+			Key:   author,
+			Name:  p.quoteByID[quotesID[0]].Author,
+			Count: len(quotesID),
+		})
+	}
+
+	if start+limit < to {
+		page.NextCursor = p.authors[start+limit-1]
+	}
+
+	return page, nil
+}
+
+// случайная цитата автора
+// 'author' сравнивается по 'utils.NormalizeKey'
+func (p *provider) RandomQuoteByAuthor(ctx context.Context, author string) (*model.Quote, error) {
+	defer p.observe("RandomQuoteByAuthor", time.Now())
+
+	author = utils.NormalizeKey(author)
+
+	p.rwMu.RLock()
+	defer p.rwMu.RUnlock()
+
+	quotesID, ex := p.listOfQuoteIDByAuthor[author]
+	if !ex {
+		p.log.DebugContext(ctx, "db: RandomQuoteByAuthor author not found", "author", author)
+		return nil, ErrDBNotFound
+	}
+
+	id := quotesID[randIndex(p.src, uint64(len(quotesID)))]
+
+	quote, ex := p.quoteByID[id]
+	if !ex {
+		p.log.ErrorContext(ctx, "db: RandomQuoteByAuthor - internal - not exist quoteID", "id", id)
+		return nil, ErrDBInternal
+	}
+
+	return &quote, nil
+}
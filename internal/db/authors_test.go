@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// цитаты разных авторов, ключи авторов по алфавиту: ada, alan, albert, grace
+var authorsData = []model.Quote{
+	{Author: `Alan Kay`, Body: `The best way to predict the future is to invent it`},
+	{Author: `Grace Hopper`, Body: `It's easier to ask forgiveness than it is to get permission`},
+	{Author: `alan  kay`, Body: `Simple things should be simple, complex things should be possible`},
+	{Author: `Albert Einstein`, Body: `Imagination is more important than knowledge`},
+	{Author: `Ada Lovelace`, Body: `That brain of mine is something more than merely mortal`},
+}
+
+func TestProvider_AuthorPage(t *testing.T) {
+	ctx := context.TODO()
+	pr := seedProvider(t, authorsData, WithRandSeed(1))
+
+	testData := []struct {
+		title      string
+		query      AuthorQuery
+		authors    []AuthorCount
+		nextCursor string
+		total      int
+	}{
+		{
+			title: `all authors`,
+			query: AuthorQuery{},
+			authors: []AuthorCount{
+				{`ada lovelace`, `Ada Lovelace`, 1},
+				{`alan kay`, `Alan Kay`, 2},
+				{`albert einstein`, `Albert Einstein`, 1},
+				{`grace hopper`, `Grace Hopper`, 1},
+			},
+			total: 4,
+		},
+		{
+			title: `first page`,
+			query: AuthorQuery{Limit: 2},
+			authors: []AuthorCount{
+				{`ada lovelace`, `Ada Lovelace`, 1},
+				{`alan kay`, `Alan Kay`, 2},
+			},
+			nextCursor: `alan kay`,
+			total:      4,
+		},
+		{
+			title: `last page`,
+			query: AuthorQuery{Limit: 2, After: `alan kay`},
+			authors: []AuthorCount{
+				{`albert einstein`, `Albert Einstein`, 1},
+				{`grace hopper`, `Grace Hopper`, 1},
+			},
+			total: 4,
+		},
+		{
+			title: `prefix without case`,
+			query: AuthorQuery{Limit: 1, Prefix: `AL`},
+			authors: []AuthorCount{
+				{`alan kay`, `Alan Kay`, 2},
+			},
+			nextCursor: `alan kay`,
+			total:      2,
+		},
+		{
+			title: `prefix after cursor`,
+			query: AuthorQuery{Prefix: `al`, After: `alan kay`},
+			authors: []AuthorCount{
+				{`albert einstein`, `Albert Einstein`, 1},
+			},
+			total: 2,
+		},
+		{
+			title:   `unknown prefix`,
+			query:   AuthorQuery{Prefix: `zz`},
+			authors: []AuthorCount{},
+			total:   0,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			page, err := pr.AuthorPage(ctx, test.query)
+			if err != nil {
+				t.Fatalf("AuthorPage: error should be nil - {%v}", err)
+			}
+
+			if !reflect.DeepEqual(page.Authors, test.authors) {
+				t.Errorf("authors not equal {got}:{want} {%v}:{%v}", page.Authors, test.authors)
+			}
+
+			if page.NextCursor != test.nextCursor || page.Total != test.total {
+				t.Errorf("nextCursor, total not equal {got}:{want} {%s, %d}:{%s, %d}",
+					page.NextCursor, page.Total, test.nextCursor, test.total)
+			}
+		})
+	}
+}
+
+func TestProvider_AuthorsIndex(t *testing.T) {
+	ctx := context.TODO()
+	pr := seedProvider(t, authorsData, WithRandSeed(1))
+
+	// автор без цитат пропадает из списка, новый автор попадает на свое место
+	if err := pr.RemoveQuote(ctx, 5, 0); err != nil {
+		t.Fatalf("RemoveQuote: error should be nil - {%v}", err)
+	}
+
+	author := `Barbara Liskov`
+	if _, err := pr.UpdateQuote(ctx, 2, 0, QuotePatch{Author: &author}); err != nil {
+		t.Fatalf("UpdateQuote: error should be nil - {%v}", err)
+	}
+
+	want := []string{`alan kay`, `albert einstein`, `barbara liskov`}
+	if !reflect.DeepEqual(pr.authors, want) {
+		t.Errorf("authors: got - {%v} not equal want - {%v};", pr.authors, want)
+	}
+
+	if _, err := newTestProvider(t).AuthorPage(ctx, AuthorQuery{}); !errors.Is(err, ErrDBEmpty) {
+		t.Errorf("AuthorPage: error should be {%v}, got - {%v}", ErrDBEmpty, err)
+	}
+}
+
+func TestProvider_RandomQuoteByAuthor(t *testing.T) {
+	ctx := context.TODO()
+	pr := seedProvider(t, authorsData, WithRandSeed(1))
+
+	for range 20 {
+		quote, err := pr.RandomQuoteByAuthor(ctx, `ALAN KAY`)
+		if err != nil {
+			t.Fatalf("RandomQuoteByAuthor: error should be nil - {%v}", err)
+		}
+		if quote.ID != 1 && quote.ID != 3 {
+			t.Errorf("RandomQuoteByAuthor: quote - {%d} not by author", quote.ID)
+		}
+	}
+
+	if _, err := pr.RandomQuoteByAuthor(ctx, `Unknown`); !errors.Is(err, ErrDBNotFound) {
+		t.Errorf("RandomQuoteByAuthor: error should be {%v}, got - {%v}", ErrDBNotFound, err)
+	}
+}
@@ -0,0 +1,155 @@
+// пакетные изменения хранилища под одной блокировкой
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// пакет в режиме "все или ничего" не применен из-за ошибки в одном из элементов
+var ErrDBBatchAborted = errors.New("batch aborted")
+
+// результат для одного элемента пакета
+// 'ID' - ID созданной цитаты, 'Err' - причина отказа (nil -> элемент применен)
+type BatchResult struct {
+	ID  uint
+	Err error
+}
+
+// добавление пакета цитат под одним Lock()
+// дубликаты ищутся по хранилищу и внутри пакета
+// 'atomic' - при любом отказе не добавляется ничего и возвращается 'ErrDBBatchAborted',
+// иначе добавляются все цитаты без отказа
+// пакет пишется в журнал одной записью
+func (p *provider) NewQuotes(ctx context.Context, quotes []model.Quote, atomic bool) ([]BatchResult, error) {
+	defer p.observe("NewQuotes", time.Now())
+
+	p.lock()
+	defer p.rwMu.Unlock()
+
+	results := make([]BatchResult, len(quotes))
+	accepted := make([]model.Quote, 0, len(quotes))
+	seen := make(map[string]struct{}, len(quotes))
+
+	now := p.now().UTC()
+	nextID := p.curID
+	failed := false
+
+	for i, quote := range quotes {
+		// проверка на уникальность без учета регистра и оформления
+		bodyKey := utils.NormalizeKey(quote.Body)
+		_, inStore := p.uniqQuote[bodyKey]
+		_, inBatch := seen[bodyKey]
+		if inStore || inBatch {
+			results[i].Err = ErrDBAlreadyExists
+			failed = true
+			continue
+		}
+		seen[bodyKey] = struct{}{}
+
+		nextID++
+		quote.ID = nextID
+		quote.Tags = normalizeTags(quote.Tags)
+		quote.CreatedAt = now
+		quote.UpdatedAt = now
+
+		results[i].ID = quote.ID
+		accepted = append(accepted, quote)
+	}
+
+	if atomic && failed {
+		for i := range results {
+			results[i].ID = 0
+		}
+		p.log.DebugContext(ctx, "db: NewQuotes atomic batch aborted", "size", len(quotes))
+		return results, ErrDBBatchAborted
+	}
+
+	if len(accepted) == 0 {
+		return results, nil
+	}
+
+	// запись в журнал до изменения данных
+	if err := p.logRecord(walRecord{Op: walOpAddBatch, Quotes: accepted}); err != nil {
+		return nil, err
+	}
+
+	p.curID = nextID
+	for _, quote := range accepted {
+		p.insert(quote)
+	}
+	p.compactIfNeeded()
+
+	p.log.DebugContext(ctx, "db: NewQuotes", "added", len(accepted), "size", len(quotes))
+
+	return results, nil
+}
+
+// удаление пакета цитат под одним Lock()
+// отсутствующий или повторный ID -> 'ErrDBNotFound' для элемента
+// 'atomic' - при любом отказе не удаляется ничего и возвращается 'ErrDBBatchAborted',
+// иначе удаляются все найденные цитаты
+// пакет пишется в журнал одной записью
+func (p *provider) RemoveQuotes(ctx context.Context, ids []uint, atomic bool) ([]BatchResult, error) {
+	defer p.observe("RemoveQuotes", time.Now())
+
+	p.lock()
+	defer p.rwMu.Unlock()
+
+	results := make([]BatchResult, len(ids))
+	accepted := make([]uint, 0, len(ids))
+	seen := make(map[uint]struct{}, len(ids))
+	failed := false
+
+	for i, id := range ids {
+		_, inStore := p.quoteByID[id]
+		_, inBatch := seen[id]
+		if !inStore || inBatch {
+			results[i].Err = ErrDBNotFound
+			failed = true
+			continue
+		}
+		seen[id] = struct{}{}
+
+		results[i].ID = id
+		accepted = append(accepted, id)
+	}
+
+	if atomic && failed {
+		for i := range results {
+			results[i].ID = 0
+		}
+		p.log.DebugContext(ctx, "db: RemoveQuotes atomic batch aborted", "size", len(ids))
+		return results, ErrDBBatchAborted
+	}
+
+	if len(accepted) == 0 {
+		return results, nil
+	}
+
+	for _, id := range accepted {
+		if err := p.checkRemove(id); err != nil {
+			return nil, err
+		}
+	}
+
+	// запись в журнал до изменения данных
+	if err := p.logRecord(walRecord{Op: walOpRemoveBatch, IDs: accepted}); err != nil {
+		return nil, err
+	}
+
+	for _, id := range accepted {
+		if err := p.remove(id); err != nil {
+			return nil, err
+		}
+	}
+	p.compactIfNeeded()
+
+	p.log.DebugContext(ctx, "db: RemoveQuotes", "removed", len(accepted), "size", len(ids))
+
+	return results, nil
+}
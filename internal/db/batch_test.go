@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// пакет: новая цитата, дубликат хранилища, новая цитата, дубликат внутри пакета
+var batchData = []model.Quote{
+	{Author: `Seneca`, Body: `Luck is what happens when preparation meets opportunity`},
+	{Author: `Napoleon Bonaparte`, Body: `MY DICTIONARY does not contain the word 'impossible'`},
+	{Author: `Seneca`, Body: `While we teach, we learn`, Tags: []string{`Wisdom`}},
+	{Author: `Unknown`, Body: `luck is what happens when preparation meets opportunity`},
+}
+
+func TestProvider_NewQuotes(t *testing.T) {
+	ctx := context.TODO()
+
+	testData := []struct {
+		title   string
+		atomic  bool
+		wantErr error
+		results []BatchResult
+		ids     []uint
+	}{
+		{
+			title:  `partial`,
+			atomic: false,
+			results: []BatchResult{
+				{ID: 4},
+				{Err: ErrDBAlreadyExists},
+				{ID: 5},
+				{Err: ErrDBAlreadyExists},
+			},
+			ids: []uint{1, 2, 3, 4, 5},
+		},
+		{
+			title:   `atomic aborted`,
+			atomic:  true,
+			wantErr: ErrDBBatchAborted,
+			results: []BatchResult{
+				{},
+				{Err: ErrDBAlreadyExists},
+				{},
+				{Err: ErrDBAlreadyExists},
+			},
+			ids: []uint{1, 2, 3},
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			pr := seedProvider(t, quotesData)
+
+			results, err := pr.NewQuotes(ctx, batchData, test.atomic)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("NewQuotes: error should be {%v}, got - {%v}", test.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(results, test.results) {
+				t.Errorf("results not equal {got}:{want} {%v}:{%v}", results, test.results)
+			}
+
+			if !reflect.DeepEqual(pr.validQuoteID, test.ids) {
+				t.Errorf("validQuoteID not equal {got}:{want} {%v}:{%v}", pr.validQuoteID, test.ids)
+			}
+
+			if pr.curID != test.ids[len(test.ids)-1] {
+				t.Errorf("curID - {%d} not equal last ID - {%d}", pr.curID, test.ids[len(test.ids)-1])
+			}
+		})
+	}
+}
+
+func TestProvider_NewQuotesAtomic(t *testing.T) {
+	ctx := context.TODO()
+	pr := seedProvider(t, quotesData)
+
+	results, err := pr.NewQuotes(ctx, []model.Quote{batchData[0], batchData[2]}, true)
+	if err != nil {
+		t.Fatalf("NewQuotes: error should be nil - {%v}", err)
+	}
+
+	want := []BatchResult{{ID: 4}, {ID: 5}}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results not equal {got}:{want} {%v}:{%v}", results, want)
+	}
+
+	if ids := pr.listOfQuoteIDByTag[`wisdom`]; !reflect.DeepEqual(ids, []uint{5}) {
+		t.Errorf("listOfQuoteIDByTag: got - {%v} not equal want - {%v};", ids, []uint{5})
+	}
+}
+
+func TestProvider_NewQuotesWALReplay(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	pr := newWALProvider(t, dir, 1<<20)
+	if _, err := pr.NewQuotes(ctx, batchData, false); err != nil {
+		t.Fatalf("NewQuotes: error should be nil - {%v}", err)
+	}
+	crash(t, pr)
+
+	restored := newWALProvider(t, dir, 1<<20)
+	defer restored.Close()
+
+	if !reflect.DeepEqual(restored.quoteByID, pr.quoteByID) || restored.curID != pr.curID {
+		t.Errorf("restored store not equal {got}:{want} {%v, %d}:{%v, %d}",
+			restored.quoteByID, restored.curID, pr.quoteByID, pr.curID)
+	}
+}
+
+func TestProvider_RemoveQuotes(t *testing.T) {
+	ctx := context.TODO()
+
+	testData := []struct {
+		title   string
+		ids     []uint
+		atomic  bool
+		wantErr error
+		results []BatchResult
+		left    []uint
+	}{
+		{
+			title:  `partial`,
+			ids:    []uint{3, 7, 1, 3},
+			atomic: false,
+			results: []BatchResult{
+				{ID: 3},
+				{Err: ErrDBNotFound},
+				{ID: 1},
+				{Err: ErrDBNotFound},
+			},
+			left: []uint{2},
+		},
+		{
+			title:   `atomic aborted`,
+			ids:     []uint{3, 7},
+			atomic:  true,
+			wantErr: ErrDBBatchAborted,
+			results: []BatchResult{
+				{},
+				{Err: ErrDBNotFound},
+			},
+			left: []uint{1, 2, 3},
+		},
+		{
+			title:  `atomic`,
+			ids:    []uint{2, 3},
+			atomic: true,
+			results: []BatchResult{
+				{ID: 2},
+				{ID: 3},
+			},
+			left: []uint{1},
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			pr := seedProvider(t, quotesData)
+
+			results, err := pr.RemoveQuotes(ctx, test.ids, test.atomic)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("RemoveQuotes: error should be {%v}, got - {%v}", test.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(results, test.results) {
+				t.Errorf("results not equal {got}:{want} {%v}:{%v}", results, test.results)
+			}
+
+			if !reflect.DeepEqual(pr.validQuoteID, test.left) {
+				t.Errorf("validQuoteID not equal {got}:{want} {%v}:{%v}", pr.validQuoteID, test.left)
+			}
+		})
+	}
+}
+
+func TestProvider_RemoveQuotesWALReplay(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	pr := newWALProvider(t, dir, 1<<20)
+	for _, quote := range quotesData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+	if _, err := pr.RemoveQuotes(ctx, []uint{1, 3}, true); err != nil {
+		t.Fatalf("RemoveQuotes: error should be nil - {%v}", err)
+	}
+	crash(t, pr)
+
+	restored := newWALProvider(t, dir, 1<<20)
+	defer restored.Close()
+
+	if !reflect.DeepEqual(restored.validQuoteID, []uint{2}) || restored.curID != 3 {
+		t.Errorf("restored store not equal {got}:{want} {%v, %d}:{%v, %d}",
+			restored.validQuoteID, restored.curID, []uint{2}, 3)
+	}
+}
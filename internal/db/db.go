@@ -4,12 +4,13 @@ package db
 import (
 	"context"
 	"errors"
-	"log"
-	"math/rand"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
 )
 
 var (
@@ -21,15 +22,139 @@ var (
 	ErrDBNotFound = errors.New("quote not found")
 
 	ErrDBAlreadyExists = errors.New("quote already exists")
+
+	// ревизия цитаты не совпадает с ожидаемой
+	ErrDBRevisionMismatch = errors.New("quote revision mismatch")
+
+	// версия файла снимка не поддерживается
+	ErrDBSnapshotVersion = errors.New("unsupported snapshot version")
 )
 
 // логика взаимодейсвия с хранилищем
 type Provider interface {
-	NewQuote(ctx context.Context, quote model.Quote) error
+	NewQuote(ctx context.Context, quote model.Quote) (*model.Quote, error)
+	NewQuotes(ctx context.Context, quotes []model.Quote, atomic bool) ([]BatchResult, error)
 	RandomQuote(ctx context.Context) (*model.Quote, error)
+	QuoteByID(ctx context.Context, id uint) (*model.Quote, error)
 	QuoteList(ctx context.Context) ([]model.Quote, error)
-	QuoteListByAuthor(ctx context.Context, author string) ([]model.Quote, error)
-	RemoveQuote(ctx context.Context, id uint) error
+	QuotePage(ctx context.Context, query PageQuery) (*Page, error)
+	QuoteListByAuthor(ctx context.Context, author string) ([]model.Quote, uint64, error)
+	RemoveQuote(ctx context.Context, id uint, revision uint64) error
+	RemoveQuotes(ctx context.Context, ids []uint, atomic bool) ([]BatchResult, error)
+	UpdateQuote(ctx context.Context, id uint, revision uint64, patch QuotePatch) (*model.Quote, error)
+	SearchQuotes(ctx context.Context, query string) ([]SearchHit, error)
+	TagList(ctx context.Context) ([]TagCount, error)
+	AddQuoteTags(ctx context.Context, id uint, tags []string) (*model.Quote, error)
+	RemoveQuoteTags(ctx context.Context, id uint, tags []string) (*model.Quote, error)
+	AuthorPage(ctx context.Context, query AuthorQuery) (*AuthorPage, error)
+	RandomQuoteByAuthor(ctx context.Context, author string) (*model.Quote, error)
+}
+
+// параметры страницы для 'QuotePage'
+// 'AfterID' - курсор, ID последней цитаты предыдущей страницы (0 -> с начала)
+// 'Limit' <= 0 -> все цитаты после курсора
+// 'Filter' - в страницу попадают только подходящие цитаты
+type PageQuery struct {
+	Limit   int
+	AfterID uint
+	Desc    bool
+	Filter  QuoteFilter
+}
+
+// фильтр цитат по метаданным, пустое поле -> без ограничения
+type QuoteFilter struct {
+	// сравнение по 'utils.NormalizeKey'
+	Source string
+	// точное совпадение канонического тега BCP-47
+	Language string
+	// диапазон годов включительно, 0 -> без границы
+	YearFrom int
+	YearTo   int
+	// теги цитаты, отбираются по 'listOfQuoteIDByTag'
+	Tags []string
+	// true -> хотя бы один из 'Tags', иначе - все
+	AnyTag bool
+}
+
+// фильтр по метаданным не задан, теги не учитываются
+func (f QuoteFilter) empty() bool {
+	return f.Source == "" && f.Language == "" && f.YearFrom == 0 && f.YearTo == 0
+}
+
+// подходит ли цитата под фильтр по метаданным, теги не учитываются
+func (f QuoteFilter) match(quote model.Quote) bool {
+	if f.Source != "" && utils.NormalizeKey(quote.Source) != utils.NormalizeKey(f.Source) {
+		return false
+	}
+	if f.Language != "" && quote.Language != f.Language {
+		return false
+	}
+	if (f.YearFrom != 0 || f.YearTo != 0) && quote.Year == 0 {
+		return false
+	}
+	if f.YearFrom != 0 && quote.Year < f.YearFrom {
+		return false
+	}
+	if f.YearTo != 0 && quote.Year > f.YearTo {
+		return false
+	}
+	return true
+}
+
+// страница цитат
+// 'NextCursor' - 'AfterID' для следующей страницы, 0 -> страница последняя
+// 'Total' - количество всех цитат в хранилище, подходящих под фильтр
+// 'Revision' - ревизия хранилища на момент чтения страницы
+type Page struct {
+	Quotes     []model.Quote
+	NextCursor uint
+	Total      int
+	Revision   uint64
+}
+
+// изменения для 'UpdateQuote', nil -> поле остается прежним
+type QuotePatch struct {
+	Author    *string
+	Body      *string
+	Source    *string
+	Year      *int
+	SourceURL *string
+	Language  *string
+	Tags      *[]string
+}
+
+// применяем изменения к копии 'quote'
+func (qp QuotePatch) apply(quote model.Quote) model.Quote {
+	if qp.Author != nil {
+		quote.Author = *qp.Author
+	}
+	if qp.Body != nil {
+		quote.Body = *qp.Body
+	}
+	if qp.Source != nil {
+		quote.Source = *qp.Source
+	}
+	if qp.Year != nil {
+		quote.Year = *qp.Year
+	}
+	if qp.SourceURL != nil {
+		quote.SourceURL = *qp.SourceURL
+	}
+	if qp.Language != nil {
+		quote.Language = *qp.Language
+	}
+	if qp.Tags != nil {
+		quote.Tags = normalizeTags(*qp.Tags)
+	}
+	return quote
+}
+
+// хранилище с управлением жизненным циклом
+// 'Close' - сохраняет последний снимок и останавливает фоновую запись
+type Store interface {
+	Provider
+	Stats() Stats
+	Close() error
 }
 
 // описание базы для цитат
@@ -43,13 +168,22 @@ type provider struct {
 
 	// содержит только уникальные цитаты,
 	// у цитаты может быть только один автор,
-	// защита от дубликатов
+	// защита от дубликатов,
+	// ключ - 'utils.NormalizeKey' текста: дубликаты без учета регистра и оформления
 	uniqQuote map[string]struct{}
 
-	// ключ - автор, значение - индексы цитат,
+	// ключ - 'utils.NormalizeKey' автора, значение - индексы цитат,
 	// для получения цитат по автору
 	listOfQuoteIDByAuthor map[string][]uint
 
+	// ключи 'listOfQuoteIDByAuthor' по алфавиту,
+	// для страниц авторов и поиска по префиксу
+	authors []string
+
+	// ключ - тег, значение - индексы цитат по возрастанию,
+	// для фильтра по тегам и 'TagList'
+	listOfQuoteIDByTag map[string][]uint
+
 	// содержит все индексы текущих цитат,
 	// для рандомного поиска
 	validQuoteID []uint
@@ -57,27 +191,193 @@ type provider struct {
 	// хранит последний созданный индекс, стартовый 0
 	curID uint
 
+	// растет при каждом изменении данных, стартовая 0
+	// цитата хранит ревизию своего последнего изменения
+	revision uint64
+
+	// обратный индекс слов текста цитат, для полнотекстового поиска
+	search *invertedIndex
+
 	// поиск случайного индекса, для 'validQuoteID'
-	src rand.Source
+	// потокобезопасный - вызывается параллельно под RLock()
+	src RandSource
+
+	// путь к снимку, пустой -> работаем только в памяти
+	snapshotPath string
+
+	// период записи снимка
+	snapshotInterval time.Duration
+
+	// время для 'CreatedAt' и 'UpdatedAt'
+	now func() time.Time
+
+	// были изменения после последнего снимка
+	dirty atomic.Bool
+
+	// журнал изменений, nil -> журнал не ведется
+	wal *wal
+
+	// путь к журналу
+	walPath string
+
+	// размер журнала, после которого он сворачивается в снимок
+	walMaxSize int64
+
+	// номер последней примененной записи журнала
+	seq uint64
+
+	// лог хранилища
+	log *slog.Logger
+
+	// время операций и ожидания блокировки, для метрик
+	observer Observer
+
+	// остановка фоновой записи снимка
+	stop chan struct{}
+	done chan struct{}
+}
+
+// настройка 'provider' при создании
+type Option func(*provider)
+
+// WithSnapshot - хранилище читает снимок из 'path' при создании,
+// пишет его каждые 'interval' и при 'Close'
+func WithSnapshot(path string, interval time.Duration) Option {
+	return func(p *provider) {
+		p.snapshotPath = path
+		p.snapshotInterval = interval
+	}
+}
+
+// WithClock - источник времени для 'CreatedAt' и 'UpdatedAt', для тестов
+func WithClock(now func() time.Time) Option {
+	return func(p *provider) {
+		p.now = now
+	}
+}
+
+// WithWAL - каждое изменение пишется в журнал 'path' до подтверждения,
+// при создании журнал применяется поверх снимка,
+// журнал больше 'maxSize' байт сворачивается в снимок
+func WithWAL(path string, maxSize int64) Option {
+	return func(p *provider) {
+		p.walPath = path
+		p.walMaxSize = maxSize
+	}
+}
+
+// WithLogger - лог хранилища вместо 'slog.Default()'
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *provider) {
+		p.log = logger
+	}
 }
 
 // конструктор для 'provider'
-func NewProvider() *provider {
-	return &provider{
+// при наличии снимка восстанавливаем данные и все индексы,
+// затем применяем записи журнала
+func NewProvider(opts ...Option) (*provider, error) {
+	p := &provider{
 		rwMu:                  sync.RWMutex{},
 		quoteByID:             make(map[uint]model.Quote),
 		uniqQuote:             make(map[string]struct{}),
 		listOfQuoteIDByAuthor: make(map[string][]uint),
+		listOfQuoteIDByTag:    make(map[string][]uint),
+		authors:               []string{},
 		validQuoteID:          []uint{},
 		curID:                 0,
-		src:                   rand.NewSource(time.Now().Unix()),
+		search:                newInvertedIndex(),
+		now:                   time.Now,
+		src:                   defaultRandSource(),
+		log:                   slog.Default(),
+		observer:              nopObserver{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.snapshotPath != "" {
+		if err := p.loadSnapshot(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.walPath != "" {
+		if err := p.openWAL(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.snapshotPath != "" && p.snapshotInterval > 0 {
+		p.stop = make(chan struct{})
+		p.done = make(chan struct{})
+		go p.snapshotLoop()
+	}
+
+	return p, nil
+}
+
+// открываем журнал и применяем его записи
+func (p *provider) openWAL() error {
+	w, records, err := openWAL(p.walPath, p.log)
+	if err != nil {
+		return err
+	}
+
+	p.lock()
+	defer p.rwMu.Unlock()
+
+	if err := p.replay(records); err != nil {
+		w.close()
+		return err
 	}
+
+	p.wal = w
+
+	return nil
+}
+
+// останавливаем фоновую запись и сохраняем последний снимок
+// при наличии журнала - сворачиваем его в снимок и закрываем
+func (p *provider) Close() error {
+	if p.stop != nil {
+		close(p.stop)
+		<-p.done
+		p.stop = nil
+	}
+
+	if p.wal == nil {
+		if p.snapshotPath == "" {
+			return nil
+		}
+		return p.saveSnapshot()
+	}
+
+	p.lock()
+	defer p.rwMu.Unlock()
+
+	if p.snapshotPath != "" {
+		if err := p.compact(); err != nil {
+			return err
+		}
+	}
+
+	err := p.wal.close()
+	p.wal = nil
+
+	return err
 }
 
 // перед записью цитаты в базу
 func (p *provider) incrementID() {
 	p.curID++
-	log.Printf("db: incrementID curID - {%d};", p.curID)
+}
+
+// новая ревизия хранилища, вызывается под Lock()
+func (p *provider) nextRevision() uint64 {
+	p.revision++
+	return p.revision
 }
 
 // делаем случайный индекс и возвращаем ID цитаты
@@ -87,8 +387,202 @@ func (p *provider) randomID() (uint, error) {
 		return 0, ErrDBEmpty
 	}
 
-	randID := uint(int(p.src.Int63()) % n)
-	log.Printf("db: randomID created index - {%d} for find ID;", randID)
+	return p.validQuoteID[randIndex(p.src, uint64(n))], nil
+}
+
+// запись цитаты во все индексы, вызывается под Lock()
+// ID цитат добавляются по возрастанию -> 'validQuoteID', списки автора и тегов отсортированы
+// 'quote.Revision' == 0 -> новая ревизия, иначе сохраняем ревизию из снимка
+func (p *provider) insert(quote model.Quote) {
+	author := utils.NormalizeKey(quote.Author)
+
+	if quote.Revision == 0 {
+		quote.Revision = p.nextRevision()
+	} else if quote.Revision > p.revision {
+		p.revision = quote.Revision
+	}
+
+	p.quoteByID[quote.ID] = quote
+	p.uniqQuote[utils.NormalizeKey(quote.Body)] = struct{}{}
+	p.linkAuthor(author, quote.ID)
+	p.validQuoteID = append(p.validQuoteID, quote.ID)
+	for _, tag := range quote.Tags {
+		p.listOfQuoteIDByTag[tag] = append(p.listOfQuoteIDByTag[tag], quote.ID)
+	}
+	p.search.add(quote.ID, quote.Body)
+	p.dirty.Store(true)
+}
+
+// замена цитаты с тем же ID, вызывается под Lock()
+// при смене ключа текста переносим его в 'uniqQuote',
+// при смене ключа автора - ID в 'listOfQuoteIDByAuthor' (с сохранением порядка)
+// изменение только регистра или оформления ключи не меняет
+// все проверки - в 'checkUpdate' до первого изменения, ошибка не оставляет индексы наполовину измененными
+func (p *provider) update(quote model.Quote) error {
+	if err := p.checkUpdate(quote); err != nil {
+		return err
+	}
+	old := p.quoteByID[quote.ID]
+
+	oldBody, newBody := utils.NormalizeKey(old.Body), utils.NormalizeKey(quote.Body)
+	if oldBody != newBody {
+		delete(p.uniqQuote, oldBody)
+		p.uniqQuote[newBody] = struct{}{}
+	}
+
+	if old.Body != quote.Body {
+		p.search.remove(quote.ID, old.Body)
+		p.search.add(quote.ID, quote.Body)
+	}
+
+	oldAuthor, newAuthor := utils.NormalizeKey(old.Author), utils.NormalizeKey(quote.Author)
+	if oldAuthor != newAuthor {
+		if err := p.unlinkAuthor(oldAuthor, quote.ID); err != nil {
+			return err
+		}
+		p.linkAuthor(newAuthor, quote.ID)
+	}
+
+	if err := p.relinkTags(quote.ID, old.Tags, quote.Tags); err != nil {
+		return err
+	}
+
+	quote.Revision = p.nextRevision()
+	p.quoteByID[quote.ID] = quote
+	p.dirty.Store(true)
+
+	return nil
+}
+
+// можно ли заменить цитату на 'quote', вызывается под Lock()
+// цитата есть, текст не занят другой цитатой, ID есть в списках старого автора и снимаемых тегов
+// вызывается и до записи в журнал - запись, которую нельзя применить, в журнал не попадает
+func (p *provider) checkUpdate(quote model.Quote) error {
+	old, ex := p.quoteByID[quote.ID]
+	if !ex {
+		return ErrDBNotFound
+	}
+
+	if newBody := utils.NormalizeKey(quote.Body); newBody != utils.NormalizeKey(old.Body) {
+		if _, ex := p.uniqQuote[newBody]; ex {
+			return ErrDBAlreadyExists
+		}
+	}
+
+	if author := utils.NormalizeKey(old.Author); author != utils.NormalizeKey(quote.Author) {
+		if _, ex := utils.IndexByValue(p.listOfQuoteIDByAuthor[author], quote.ID); !ex {
+			p.log.Error("db: checkUpdate - internal - not exist id in listOfQuoteIDByAuthor", "id", quote.ID, "author", author)
+			return ErrDBInternal
+		}
+	}
+
+	for _, tag := range old.Tags {
+		if containsTag(quote.Tags, tag) {
+			continue
+		}
+		if _, ex := utils.IndexByValue(p.listOfQuoteIDByTag[tag], quote.ID); !ex {
+			p.log.Error("db: checkUpdate - internal - not exist id in listOfQuoteIDByTag", "id", quote.ID, "tag", tag)
+			return ErrDBInternal
+		}
+	}
+
+	return nil
+}
+
+// удаляем 'id' из списка автора, пустой список удаляется, вызывается под Lock()
+// 'author' - ключ 'utils.NormalizeKey'
+func (p *provider) unlinkAuthor(author string, id uint) error {
+	// список ID цитат по автору
+	quotesID, ex := p.listOfQuoteIDByAuthor[author]
+	if !ex {
+		p.log.Error("db: unlinkAuthor - internal - not exist key in listOfQuoteIDByAuthor", "author", author)
+		return ErrDBInternal
+	}
+
+	// индекс для ID цитаты из quotesID
+	indexFromAuhtor, ex := utils.IndexByValue(quotesID, id)
+	if !ex {
+		p.log.Error("db: unlinkAuthor - internal - not exist id in listOfQuoteIDByAuthor", "id", id, "author", author)
+		return ErrDBInternal
+	}
+
+	// удаляем цитату из списка автора
+	quotesID = append(quotesID[:indexFromAuhtor], quotesID[indexFromAuhtor+1:]...)
+	if len(quotesID) == 0 {
+		// нет цитат у автора -> удаляем
+		delete(p.listOfQuoteIDByAuthor, author)
+		p.dropAuthor(author)
+	} else {
+		// сохраняем новый список
+		p.listOfQuoteIDByAuthor[author] = quotesID
+	}
+
+	return nil
+}
+
+// удаление цитаты из всех индексов, вызывается под Lock()
+// все проверки - в 'checkRemove' до первого изменения, ошибка не оставляет индексы наполовину измененными
+func (p *provider) remove(id uint) error {
+	if err := p.checkRemove(id); err != nil {
+		return err
+	}
+	quote := p.quoteByID[id]
+	bodyKey := utils.NormalizeKey(quote.Body)
+	indexFromQuotes, _ := utils.IndexByValue(p.validQuoteID, id)
+
+	if err := p.unlinkAuthor(utils.NormalizeKey(quote.Author), id); err != nil {
+		return err
+	}
+
+	if err := p.relinkTags(id, quote.Tags, nil); err != nil {
+		return err
+	}
+
+	delete(p.quoteByID, id)
+	delete(p.uniqQuote, bodyKey)
+	p.search.remove(id, quote.Body)
+
+	// удаляем из всех текущих индексов цитат
+	p.validQuoteID = append(p.validQuoteID[:indexFromQuotes], p.validQuoteID[indexFromQuotes+1:]...)
+
+	p.nextRevision()
+	p.dirty.Store(true)
+
+	return nil
+}
+
+// можно ли удалить цитату 'id', вызывается под Lock()
+// проверяем наличие данных о цитате в (quoteByID, uniqQuote, listOfQuoteIDByAuthor, listOfQuoteIDByTag, validQuoteID)
+// вызывается и до записи в журнал - запись, которую нельзя применить, в журнал не попадает
+func (p *provider) checkRemove(id uint) error {
+	quote, ex := p.quoteByID[id]
+	if !ex {
+		return ErrDBNotFound
+	}
+
+	bodyKey := utils.NormalizeKey(quote.Body)
+	if _, ex := p.uniqQuote[bodyKey]; !ex {
+		p.log.Error("db: checkRemove - internal - not exist key in uniqQuote", "key", bodyKey)
+		return ErrDBInternal
+	}
+
+	if _, ex := utils.IndexByValue(p.validQuoteID, id); !ex {
+		p.log.Error("db: checkRemove - internal - not exist id in validQuoteID", "id", id)
+		return ErrDBInternal
+	}
+
+	author := utils.NormalizeKey(quote.Author)
+	if _, ex := utils.IndexByValue(p.listOfQuoteIDByAuthor[author], id); !ex {
+		p.log.Error("db: checkRemove - internal - not exist id in listOfQuoteIDByAuthor", "id", id, "author", author)
+		return ErrDBInternal
+	}
+
+	for _, tag := range quote.Tags {
+		if _, ex := utils.IndexByValue(p.listOfQuoteIDByTag[tag], id); !ex {
+			p.log.Error("db: checkRemove - internal - not exist id in listOfQuoteIDByTag", "id", id, "tag", tag)
+			return ErrDBInternal
+		}
+	}
 
-	return p.validQuoteID[randID], nil
+	return nil
 }
@@ -0,0 +1,51 @@
+// наблюдение за операциями хранилища, для метрик
+package db
+
+import "time"
+
+// получает время операций и ожидания блокировки на запись
+// вызывается конкурентно - реализация должна быть потокобезопасной
+type Observer interface {
+	// 'op' - имя метода 'Provider'
+	ObserveOperation(op string, d time.Duration)
+	ObserveLockWait(d time.Duration)
+}
+
+// наблюдатель по умолчанию, ничего не делает
+type nopObserver struct{}
+
+func (nopObserver) ObserveOperation(string, time.Duration) {}
+func (nopObserver) ObserveLockWait(time.Duration)          {}
+
+// WithObserver - наблюдатель за операциями хранилища
+func WithObserver(observer Observer) Option {
+	return func(p *provider) {
+		p.observer = observer
+	}
+}
+
+// размер хранилища
+type Stats struct {
+	Quotes  int
+	Authors int
+}
+
+// количество цитат и авторов
+func (p *provider) Stats() Stats {
+	p.rwMu.RLock()
+	defer p.rwMu.RUnlock()
+
+	return Stats{Quotes: len(p.validQuoteID), Authors: len(p.authors)}
+}
+
+// Lock() с замером ожидания
+func (p *provider) lock() {
+	start := time.Now()
+	p.rwMu.Lock()
+	p.observer.ObserveLockWait(time.Since(start))
+}
+
+// время операции 'op' с 'start', вызывается через defer
+func (p *provider) observe(op string, start time.Time) {
+	p.observer.ObserveOperation(op, time.Since(start))
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// запоминает вызовы 'Observer'
+type recordObserver struct {
+	mu        sync.Mutex
+	ops       []string
+	lockWaits int
+}
+
+func (o *recordObserver) ObserveOperation(op string, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ops = append(o.ops, op)
+}
+
+func (o *recordObserver) ObserveLockWait(time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lockWaits++
+}
+
+func TestProvider_Observer(t *testing.T) {
+	observer := &recordObserver{}
+	pr := newTestProvider(t, WithObserver(observer))
+	ctx := context.Background()
+
+	if stats := pr.Stats(); stats != (Stats{}) {
+		t.Errorf("Stats empty {got}:{want} {%v}:{%v};", stats, Stats{})
+	}
+
+	pr.NewQuote(ctx, model.Quote{Author: "Seneca", Body: "While we teach, we learn"})
+	pr.NewQuote(ctx, model.Quote{Author: "seneca", Body: "Luck is what happens when preparation meets opportunity"})
+	pr.NewQuote(ctx, model.Quote{Author: "Epictetus", Body: "No man is free who is not master of himself"})
+	pr.QuoteByID(ctx, 1)
+	pr.RemoveQuote(ctx, 3, 0)
+
+	if stats := pr.Stats(); stats != (Stats{Quotes: 2, Authors: 1}) {
+		t.Errorf("Stats {got}:{want} {%v}:{%v};", stats, Stats{Quotes: 2, Authors: 1})
+	}
+
+	expectedOps := []string{"NewQuote", "NewQuote", "NewQuote", "QuoteByID", "RemoveQuote"}
+	if !reflect.DeepEqual(observer.ops, expectedOps) {
+		t.Errorf("operations {got}:{want} {%v}:{%v};", observer.ops, expectedOps)
+	}
+
+	// блокировка на запись - только у изменений
+	if observer.lockWaits != 4 {
+		t.Errorf("lock waits {got}:{want} {%d}:{4};", observer.lockWaits)
+	}
+}
@@ -0,0 +1,63 @@
+// источник случайных чисел для 'RandomQuote'
+package db
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// источник случайных чисел для 'provider'
+// 'Uint64' вызывается конкурентно под RLock() - реализация должна быть потокобезопасной
+type RandSource interface {
+	Uint64() uint64
+}
+
+// SplitMix64 с атомарным состоянием:
+// каждый вызов - одно 'atomic.Add', читатели не блокируют друг друга
+type splitMix struct {
+	state atomic.Uint64
+}
+
+// NewRandSource - потокобезопасный источник, одинаковый 'seed' -> одинаковая последовательность
+func NewRandSource(seed uint64) RandSource {
+	s := &splitMix{}
+	s.state.Store(seed)
+	return s
+}
+
+func (s *splitMix) Uint64() uint64 {
+	z := s.state.Add(0x9e3779b97f4a7c15)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// WithRandSource - свой источник для 'RandomQuote'
+func WithRandSource(src RandSource) Option {
+	return func(p *provider) {
+		p.src = src
+	}
+}
+
+// WithRandSeed - детерминированная последовательность 'RandomQuote', для тестов
+func WithRandSeed(seed uint64) Option {
+	return WithRandSource(NewRandSource(seed))
+}
+
+// источник по умолчанию
+func defaultRandSource() RandSource {
+	return NewRandSource(uint64(time.Now().UnixNano()))
+}
+
+// случайное число в [0, n) без смещения остатка от деления (метод Лемира)
+func randIndex(src RandSource, n uint64) uint64 {
+	hi, lo := bits.Mul64(src.Uint64(), n)
+	if lo < n {
+		threshold := -n % n
+		for lo < threshold {
+			hi, lo = bits.Mul64(src.Uint64(), n)
+		}
+	}
+	return hi
+}
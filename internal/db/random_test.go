@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+func TestProvider_RandomQuoteSeed(t *testing.T) {
+	ctx := context.TODO()
+
+	// одинаковый seed -> одинаковая последовательность
+	sequence := func() []uint {
+		pr := newTestProvider(t, WithRandSeed(42))
+
+		for _, quote := range quotesData {
+			if _, err := pr.NewQuote(ctx, quote); err != nil {
+				t.Fatalf("NewQuote: error should be nil - {%v}", err)
+			}
+		}
+
+		ids := make([]uint, 0, 20)
+		for i := 0; i < 20; i++ {
+			quote, err := pr.RandomQuote(ctx)
+			if err != nil {
+				t.Fatalf("RandomQuote: error should be nil - {%v}", err)
+			}
+			ids = append(ids, quote.ID)
+		}
+
+		return ids
+	}
+
+	first, second := sequence(), sequence()
+
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("sequences not equal {first}:{second} {%v}:{%v}", first, second)
+	}
+
+	// все цитаты должны выпадать
+	seen := make(map[uint]struct{})
+	for _, id := range first {
+		seen[id] = struct{}{}
+	}
+	if len(seen) != len(quotesData) {
+		t.Errorf("random ids not cover all quotes {got}:{want} {%d}:{%d}", len(seen), len(quotesData))
+	}
+}
+
+func TestRandIndex(t *testing.T) {
+	src := NewRandSource(7)
+
+	for _, n := range []uint64{1, 2, 3, 10, 1 << 40} {
+		for i := 0; i < 1000; i++ {
+			if got := randIndex(src, n); got >= n {
+				t.Fatalf("randIndex out of range {got}:{n} {%d}:{%d}", got, n)
+			}
+		}
+	}
+}
+
+// запускать с 'go test -race' - параллельные читатели 'RandomQuote' и писатели
+func TestProvider_RandomQuoteStress(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t)
+
+	for _, quote := range quotesData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	const (
+		readers = 16
+		writers = 4
+		rounds  = 200
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, readers+writers)
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				quote, err := pr.RandomQuote(ctx)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if quote.Body == "" {
+					errs <- fmt.Errorf("empty quote by ID - {%d}", quote.ID)
+					return
+				}
+			}
+		}()
+	}
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				quote := model.Quote{Author: `Stress`, Body: fmt.Sprintf("writer %d quote %d", w, i)}
+				if _, err := pr.NewQuote(ctx, quote); err != nil {
+					errs <- err
+					return
+				}
+				// удаляем последнюю свою цитату через раз
+				if i%2 == 1 {
+					page, err := pr.QuotePage(ctx, PageQuery{Limit: 1, Desc: true})
+					if err != nil {
+						errs <- err
+						return
+					}
+					if err := pr.RemoveQuote(ctx, page.Quotes[0].ID, 0); err != nil && !errors.Is(err, ErrDBNotFound) {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("stress error - {%v}", err)
+	}
+}
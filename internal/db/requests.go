@@ -3,40 +3,53 @@ package db
 
 import (
 	"context"
-	"log"
+	"sort"
+	"time"
 
 	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
 	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
 )
 
-// добавление цитаты
-func (p *provider) NewQuote(_ context.Context, quote model.Quote) error {
-	p.rwMu.Lock()
+// добавление цитаты, возвращаем сохраненную цитату с ID, временем создания и ревизией
+func (p *provider) NewQuote(ctx context.Context, quote model.Quote) (*model.Quote, error) {
+	defer p.observe("NewQuote", time.Now())
+
+	p.lock()
 	defer p.rwMu.Unlock()
 
-	// проверка на уникальность
-	if _, ex := p.uniqQuote[quote.Body]; ex {
-		log.Printf("db: NewQuote quote with body  - {%s} is exists;", quote.Body)
-		return ErrDBAlreadyExists
+	// проверка на уникальность без учета регистра и оформления
+	if _, ex := p.uniqQuote[utils.NormalizeKey(quote.Body)]; ex {
+		p.log.DebugContext(ctx, "db: NewQuote quote with body exists", "quote", quote.Body)
+		return nil, ErrDBAlreadyExists
+	}
+
+	// запись в журнал до изменения данных
+	quote.ID = p.curID + 1
+	quote.Tags = normalizeTags(quote.Tags)
+	quote.CreatedAt = p.now().UTC()
+	quote.UpdatedAt = quote.CreatedAt
+	if err := p.logRecord(walRecord{Op: walOpAdd, Quote: &quote}); err != nil {
+		return nil, err
 	}
 
 	// создаем ID для цитаты
 	p.incrementID()
 
 	// запись данных
-	quote.ID = p.curID
-	p.quoteByID[quote.ID] = quote
-	p.uniqQuote[quote.Body] = struct{}{}
-	p.listOfQuoteIDByAuthor[quote.Author] = append(p.listOfQuoteIDByAuthor[quote.Author], quote.ID)
-	p.validQuoteID = append(p.validQuoteID, quote.ID)
+	p.insert(quote)
+	p.compactIfNeeded()
 
-	log.Printf("db: NewQuote with ID - {%d};", quote.ID)
+	p.log.DebugContext(ctx, "db: NewQuote", "id", quote.ID)
 
-	return nil
+	stored := p.quoteByID[quote.ID]
+
+	return &stored, nil
 }
 
 // получение случайной цитаты
-func (p *provider) RandomQuote(_ context.Context) (*model.Quote, error) {
+func (p *provider) RandomQuote(ctx context.Context) (*model.Quote, error) {
+	defer p.observe("RandomQuote", time.Now())
+
 	p.rwMu.RLock()
 	defer p.rwMu.RUnlock()
 
@@ -46,17 +59,34 @@ func (p *provider) RandomQuote(_ context.Context) (*model.Quote, error) {
 	}
 
 	if quote, ex := p.quoteByID[randID]; ex {
-		log.Printf("db: RandomQuote by ID - {%d};", p.curID)
+		p.log.DebugContext(ctx, "db: RandomQuote", "id", randID)
 		return &quote, nil
 	}
 
-	log.Print("db: RandomQuote - internal error;")
+	p.log.ErrorContext(ctx, "db: RandomQuote - internal - not exist quoteID", "id", randID)
 
 	return nil, ErrDBInternal
 }
 
+// получение цитаты по ID
+func (p *provider) QuoteByID(_ context.Context, id uint) (*model.Quote, error) {
+	defer p.observe("QuoteByID", time.Now())
+
+	p.rwMu.RLock()
+	defer p.rwMu.RUnlock()
+
+	quote, ex := p.quoteByID[id]
+	if !ex {
+		return nil, ErrDBNotFound
+	}
+
+	return &quote, nil
+}
+
 // получение всех цитат
 func (p *provider) QuoteList(_ context.Context) ([]model.Quote, error) {
+	defer p.observe("QuoteList", time.Now())
+
 	p.rwMu.RLock()
 	defer p.rwMu.RUnlock()
 
@@ -68,22 +98,118 @@ func (p *provider) QuoteList(_ context.Context) ([]model.Quote, error) {
 
 	arrQuote := make([]model.Quote, 0, n)
 
-	for _, quote := range p.quoteByID {
-		arrQuote = append(arrQuote, quote)
+	// 'validQuoteID' упорядочен -> цитаты по возрастанию ID
+	for _, id := range p.validQuoteID {
+		arrQuote = append(arrQuote, p.quoteByID[id])
 	}
 
 	return arrQuote, nil
 }
 
-// список всех цитат по автору
-func (p *provider) QuoteListByAuthor(_ context.Context, author string) ([]model.Quote, error) {
+// страница цитат по упорядоченному 'validQuoteID'
+// начало страницы ищем бинарным поиском по курсору, копируем только 'query.Limit' цитат
+// с фильтром - проверяем цитаты после курсора, 'Total' считаем по всем подходящим
+// с тегами - вместо 'validQuoteID' берем упорядоченные ID из 'listOfQuoteIDByTag'
+func (p *provider) QuotePage(_ context.Context, query PageQuery) (*Page, error) {
+	defer p.observe("QuotePage", time.Now())
+
+	p.rwMu.RLock()
+	defer p.rwMu.RUnlock()
+
+	if len(p.validQuoteID) == 0 {
+		return nil, ErrDBEmpty
+	}
+
+	ids := p.validQuoteID
+	if tags := normalizeTags(query.Filter.Tags); len(tags) > 0 {
+		ids = p.idsByTags(tags, query.Filter.AnyTag)
+	}
+	n := len(ids)
+
+	// нет цитат с такими тегами
+	if n == 0 {
+		return &Page{Quotes: []model.Quote{}, Revision: p.revision}, nil
+	}
+
+	limit := query.Limit
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+
+	// ID в порядке выдачи, начиная с первого после курсора
+	var ordered func(yield func(uint) bool)
+
+	if !query.Desc {
+		// первый ID больше курсора
+		start := sort.Search(n, func(i int) bool { return ids[i] > query.AfterID })
+		ordered = func(yield func(uint) bool) {
+			for _, id := range ids[start:] {
+				if !yield(id) {
+					return
+				}
+			}
+		}
+	} else {
+		// по убыванию - идем от последнего ID меньше курсора
+		end := n
+		if query.AfterID != 0 {
+			end = sort.Search(n, func(i int) bool { return ids[i] >= query.AfterID })
+		}
+		ordered = func(yield func(uint) bool) {
+			for i := end - 1; i >= 0; i-- {
+				if !yield(ids[i]) {
+					return
+				}
+			}
+		}
+	}
+
+	page := &Page{Quotes: make([]model.Quote, 0, limit), Revision: p.revision}
+	more := false
+
+	for id := range ordered {
+		quote := p.quoteByID[id]
+		if !query.Filter.match(quote) {
+			continue
+		}
+		if len(page.Quotes) == limit {
+			more = true
+			break
+		}
+		page.Quotes = append(page.Quotes, quote)
+	}
+
+	if more {
+		page.NextCursor = page.Quotes[len(page.Quotes)-1].ID
+	}
+
+	page.Total = n
+	if !query.Filter.empty() {
+		page.Total = 0
+		for _, id := range ids {
+			if query.Filter.match(p.quoteByID[id]) {
+				page.Total++
+			}
+		}
+	}
+
+	return page, nil
+}
+
+// список всех цитат по автору и ревизия хранилища на момент чтения
+// 'author' сравнивается по 'utils.NormalizeKey'
+func (p *provider) QuoteListByAuthor(ctx context.Context, author string) ([]model.Quote, uint64, error) {
+	defer p.observe("QuoteListByAuthor", time.Now())
+
+	author = utils.NormalizeKey(author)
+
 	p.rwMu.RLock()
 	defer p.rwMu.RUnlock()
 
 	// данный автор отсутствует
 	if _, ex := p.listOfQuoteIDByAuthor[author]; !ex {
-		log.Printf("db: QuoteListByAuthor autor - {%s} not found;", author)
-		return nil, ErrDBNotFound
+		p.log.DebugContext(ctx, "db: QuoteListByAuthor author not found", "author", author)
+		return nil, 0, ErrDBNotFound
 	}
 
 	arrQuote := make([]model.Quote, 0, len(p.listOfQuoteIDByAuthor[author]))
@@ -91,74 +217,96 @@ func (p *provider) QuoteListByAuthor(_ context.Context, author string) ([]model.
 	for _, quoteID := range p.listOfQuoteIDByAuthor[author] {
 		quote, ex := p.quoteByID[quoteID]
 		if !ex {
-			log.Printf("db: QuoteListByAuthor - internal - not exist quoteID - {%d};", quoteID)
-			return nil, ErrDBInternal
+			p.log.ErrorContext(ctx, "db: QuoteListByAuthor - internal - not exist quoteID", "id", quoteID)
+			return nil, 0, ErrDBInternal
 		}
 		arrQuote = append(arrQuote, quote)
 	}
 
-	return arrQuote, nil
+	return arrQuote, p.revision, nil
 }
 
 // удаление цитаты по ID
-// проверяем наличие данных о цитате в (quoteByID, uniqQuote, istOfQuoteIDByAuthor, validQuoteID)
-// все хорошо -> удаляем
-func (p *provider) RemoveQuote(_ context.Context, id uint) error {
-	p.rwMu.Lock()
+// 'revision' != 0 -> удаляем, только если ревизия цитаты совпадает
+func (p *provider) RemoveQuote(ctx context.Context, id uint, revision uint64) error {
+	defer p.observe("RemoveQuote", time.Now())
+
+	p.lock()
 	defer p.rwMu.Unlock()
 
-	// цитата по ID
 	quote, ex := p.quoteByID[id]
 	if !ex {
 		return ErrDBNotFound
 	}
 
-	// проверка в 'uniqQuote'
-	if _, ex := p.uniqQuote[quote.Body]; !ex {
-		log.Printf("db: RemoveQuote - internal - not exist key - {%s} in uniqQuote;", quote.Body)
-		return ErrDBInternal
+	if revision != 0 && quote.Revision != revision {
+		p.log.DebugContext(ctx, "db: RemoveQuote revision mismatch", "id", id, "revision", quote.Revision, "expected", revision)
+		return ErrDBRevisionMismatch
 	}
 
-	// список ID цитат по автору
-	quotesID, ex := p.listOfQuoteIDByAuthor[quote.Author]
-	if !ex {
-		log.Printf("db: RemoveQuote - internal - not exist key - {%s} in listOfQuoteIDByAuthor;", quote.Author)
-		return ErrDBInternal
+	if err := p.checkRemove(id); err != nil {
+		return err
 	}
 
-	// индекс для ID цитаты из quotesID
-	indexFromAuhtor, ex := utils.IndexByValue(quotesID, id)
-	if !ex {
-		log.Printf(
-			"db: RemoveQuote - internal - not exist id - {%d} in listOfQuoteIDByAuthor by author - {%s};",
-			indexFromAuhtor, quote.Author)
-		return ErrDBInternal
+	// запись в журнал до изменения данных
+	if err := p.logRecord(walRecord{Op: walOpRemove, ID: id}); err != nil {
+		return err
 	}
 
-	// индекс для ID цитаты из всех текущих статей
-	indexFromQuotes, ex := utils.IndexByValue(p.validQuoteID, id)
+	if err := p.remove(id); err != nil {
+		return err
+	}
+	p.compactIfNeeded()
+
+	p.log.DebugContext(ctx, "db: RemoveQuote", "id", id)
+
+	return nil
+}
+
+// изменение цитаты по ID
+// новый текст не должен совпадать с другой цитатой,
+// индексы 'uniqQuote' и 'listOfQuoteIDByAuthor' меняются вместе с цитатой
+// 'revision' != 0 -> изменяем, только если ревизия цитаты совпадает
+func (p *provider) UpdateQuote(
+	ctx context.Context,
+	id uint,
+	revision uint64,
+	patch QuotePatch) (*model.Quote, error) {
+	defer p.observe("UpdateQuote", time.Now())
+
+	p.lock()
+	defer p.rwMu.Unlock()
+
+	old, ex := p.quoteByID[id]
 	if !ex {
-		log.Printf("db: RemoveQuote - internal - not exist id - {%d} in validQuoteID", id)
-		return ErrDBInternal
+		return nil, ErrDBNotFound
 	}
 
-	delete(p.quoteByID, id)
-	delete(p.uniqQuote, quote.Body)
+	if revision != 0 && old.Revision != revision {
+		p.log.DebugContext(ctx, "db: UpdateQuote revision mismatch", "id", id, "revision", old.Revision, "expected", revision)
+		return nil, ErrDBRevisionMismatch
+	}
 
-	// удаляем цитату из списка автора
-	quotesID = append(quotesID[:indexFromAuhtor], quotesID[indexFromAuhtor+1:]...)
-	if len(quotesID) == 0 {
-		// нет цитат у автора -> удаляем
-		delete(p.listOfQuoteIDByAuthor, quote.Author)
-	} else {
-		// сохраняем новый список
-		p.listOfQuoteIDByAuthor[quote.Author] = quotesID
+	quote := patch.apply(old)
+	quote.UpdatedAt = p.now().UTC()
+
+	if err := p.checkUpdate(quote); err != nil {
+		return nil, err
 	}
 
-	// удаляем из всех текущих индексов цитат
-	p.validQuoteID = append(p.validQuoteID[:indexFromQuotes], p.validQuoteID[indexFromQuotes+1:]...)
+	// запись в журнал до изменения данных
+	if err := p.logRecord(walRecord{Op: walOpUpdate, Quote: &quote}); err != nil {
+		return nil, err
+	}
 
-	log.Printf("db: RemoveQuote by ID - {%d} is deleted;", id)
+	if err := p.update(quote); err != nil {
+		return nil, err
+	}
+	p.compactIfNeeded()
 
-	return nil
+	p.log.DebugContext(ctx, "db: UpdateQuote", "id", id)
+
+	stored := p.quoteByID[id]
+
+	return &stored, nil
 }
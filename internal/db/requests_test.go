@@ -7,8 +7,10 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
 )
 
 // набор цитат для записи в базу
@@ -30,7 +32,7 @@ var quotesData = []model.Quote{
 
 func TestProvider_NewQuote(t *testing.T) {
 	ctx := context.TODO()
-	pr := NewProvider()
+	pr := newTestProvider(t)
 
 	testData := []struct {
 		title           string
@@ -65,7 +67,7 @@ func TestProvider_NewQuote(t *testing.T) {
 				t.Fatal("Test package is broken")
 			}
 
-			err := pr.NewQuote(ctx, quotesData[test.quotesDataIndex])
+			_, err := pr.NewQuote(ctx, quotesData[test.quotesDataIndex])
 
 			if !errors.Is(err, test.err) {
 				t.Errorf("errors not equal {got}:{want} {%v}:{%v};", err, test.err)
@@ -76,7 +78,7 @@ func TestProvider_NewQuote(t *testing.T) {
 
 func TestProvider_QuoteList(t *testing.T) {
 	ctx := context.TODO()
-	pr := NewProvider()
+	pr := newTestProvider(t, zeroClock())
 
 	testData := []struct {
 		title  string
@@ -86,9 +88,10 @@ func TestProvider_QuoteList(t *testing.T) {
 			title: `William James`,
 			quotes: []model.Quote{
 				{
-					ID:     1,
-					Author: `William James`,
-					Body:   `The greatest weapon against stress is our ability to choose one thought over another`,
+					ID:       1,
+					Revision: 1,
+					Author:   `William James`,
+					Body:     `The greatest weapon against stress is our ability to choose one thought over another`,
 				},
 			},
 		},
@@ -96,14 +99,16 @@ func TestProvider_QuoteList(t *testing.T) {
 			title: `William James, Napoleon Bonaparte`,
 			quotes: []model.Quote{
 				{
-					ID:     1,
-					Author: `William James`,
-					Body:   `The greatest weapon against stress is our ability to choose one thought over another`,
+					ID:       1,
+					Revision: 1,
+					Author:   `William James`,
+					Body:     `The greatest weapon against stress is our ability to choose one thought over another`,
 				},
 				{
-					ID:     2,
-					Author: `Napoleon Bonaparte`,
-					Body:   `My dictionary does not contain the word 'impossible'`,
+					ID:       2,
+					Revision: 2,
+					Author:   `Napoleon Bonaparte`,
+					Body:     `My dictionary does not contain the word 'impossible'`,
 				},
 			},
 		},
@@ -111,19 +116,22 @@ func TestProvider_QuoteList(t *testing.T) {
 			title: `William James, Napoleon Bonaparte, Steve Jobs`,
 			quotes: []model.Quote{
 				{
-					ID:     1,
-					Author: `William James`,
-					Body:   `The greatest weapon against stress is our ability to choose one thought over another`,
+					ID:       1,
+					Revision: 1,
+					Author:   `William James`,
+					Body:     `The greatest weapon against stress is our ability to choose one thought over another`,
 				},
 				{
-					ID:     2,
-					Author: `Napoleon Bonaparte`,
-					Body:   `My dictionary does not contain the word 'impossible'`,
+					ID:       2,
+					Revision: 2,
+					Author:   `Napoleon Bonaparte`,
+					Body:     `My dictionary does not contain the word 'impossible'`,
 				},
 				{
-					ID:     3,
-					Author: `Steve Jobs`,
-					Body:   `Your time is limited, so don’t waste it living someone else’s life`,
+					ID:       3,
+					Revision: 3,
+					Author:   `Steve Jobs`,
+					Body:     `Your time is limited, so don’t waste it living someone else’s life`,
 				},
 			},
 		},
@@ -142,7 +150,7 @@ func TestProvider_QuoteList(t *testing.T) {
 	for i, quote := range quotesData {
 		t.Run(testData[i].title, func(t *testing.T) {
 			// добавляем в базу по одному
-			err := pr.NewQuote(ctx, quote)
+			_, err := pr.NewQuote(ctx, quote)
 			if err != nil {
 				t.Fatalf("NewQuote: error should be nil - {%v}", err)
 			}
@@ -168,7 +176,7 @@ func TestProvider_QuoteList(t *testing.T) {
 
 func TestProvider_QuoteListByAuthor(t *testing.T) {
 	ctx := context.TODO()
-	pr := NewProvider()
+	pr := newTestProvider(t, zeroClock())
 
 	tmpQuotesData := append(quotesData, model.Quote{
 		Author: `William James`,
@@ -178,7 +186,7 @@ func TestProvider_QuoteListByAuthor(t *testing.T) {
 	// записываем цитаты в базу
 	for _, quote := range tmpQuotesData {
 		t.Run("add quote", func(t *testing.T) {
-			err := pr.NewQuote(ctx, quote)
+			_, err := pr.NewQuote(ctx, quote)
 			if err != nil {
 				t.Fatalf("NewQuote: error should be nil - {%v}", err)
 			}
@@ -196,9 +204,10 @@ func TestProvider_QuoteListByAuthor(t *testing.T) {
 			author: `Napoleon Bonaparte`,
 			quotes: []model.Quote{
 				{
-					ID:     2,
-					Author: `Napoleon Bonaparte`,
-					Body:   `My dictionary does not contain the word 'impossible'`,
+					ID:       2,
+					Revision: 2,
+					Author:   `Napoleon Bonaparte`,
+					Body:     `My dictionary does not contain the word 'impossible'`,
 				},
 			},
 			err: nil,
@@ -208,9 +217,10 @@ func TestProvider_QuoteListByAuthor(t *testing.T) {
 			author: `Steve Jobs`,
 			quotes: []model.Quote{
 				{
-					ID:     3,
-					Author: `Steve Jobs`,
-					Body:   `Your time is limited, so don’t waste it living someone else’s life`,
+					ID:       3,
+					Revision: 3,
+					Author:   `Steve Jobs`,
+					Body:     `Your time is limited, so don’t waste it living someone else’s life`,
 				},
 			},
 			err: nil,
@@ -220,14 +230,16 @@ func TestProvider_QuoteListByAuthor(t *testing.T) {
 			author: `William James`,
 			quotes: []model.Quote{
 				{
-					ID:     1,
-					Author: `William James`,
-					Body:   `The greatest weapon against stress is our ability to choose one thought over another`,
+					ID:       1,
+					Revision: 1,
+					Author:   `William James`,
+					Body:     `The greatest weapon against stress is our ability to choose one thought over another`,
 				},
 				{
-					ID:     4,
-					Author: `William James`,
-					Body:   `Action may not always bring happiness, but there is no happiness without action`,
+					ID:       4,
+					Revision: 4,
+					Author:   `William James`,
+					Body:     `Action may not always bring happiness, but there is no happiness without action`,
 				},
 			},
 			err: nil,
@@ -243,7 +255,7 @@ func TestProvider_QuoteListByAuthor(t *testing.T) {
 	for _, test := range testData {
 		t.Run(test.title, func(t *testing.T) {
 			// получаем текуший список по автору
-			quoteList, err := pr.QuoteListByAuthor(ctx, test.author)
+			quoteList, _, err := pr.QuoteListByAuthor(ctx, test.author)
 			if !errors.Is(err, test.err) {
 				t.Errorf("errors not equal {got}:{want} {%v}{%v}", err, test.err)
 			}
@@ -258,13 +270,14 @@ func TestProvider_QuoteListByAuthor(t *testing.T) {
 
 func TestProvider_RandomQuote(t *testing.T) {
 	ctx := context.TODO()
-	pr := NewProvider()
+	pr := newTestProvider(t, zeroClock())
 
 	// Создаем для проверки получение случайно выбранной цитаты из хранилища
 	tmpQuoteMap := map[uint]model.Quote{}
 
 	for i, quote := range quotesData {
 		quote.ID = uint(i) + 1
+		quote.Revision = uint64(quote.ID)
 		tmpQuoteMap[quote.ID] = quote
 	}
 
@@ -274,7 +287,7 @@ func TestProvider_RandomQuote(t *testing.T) {
 
 	for i, quote := range quotesData {
 		t.Run(fmt.Sprintf("find random quote: part - {%d}", i+1), func(t *testing.T) {
-			err := pr.NewQuote(ctx, quote)
+			_, err := pr.NewQuote(ctx, quote)
 			if err != nil {
 				t.Fatalf("NewQuote: error should be nil - {%v}", err)
 			}
@@ -301,11 +314,11 @@ func TestProvider_RandomQuote(t *testing.T) {
 
 func TestProvider_RemoveQuote(t *testing.T) {
 	ctx := context.TODO()
-	pr := NewProvider()
+	pr := newTestProvider(t)
 
 	for _, quote := range quotesData {
 		t.Run("add quote", func(t *testing.T) {
-			err := pr.NewQuote(ctx, quote)
+			_, err := pr.NewQuote(ctx, quote)
 			if err != nil {
 				t.Fatalf("NewQuote: error should be nil - {%v}", err)
 			}
@@ -341,10 +354,574 @@ func TestProvider_RemoveQuote(t *testing.T) {
 
 	for _, test := range testData {
 		t.Run(test.title, func(t *testing.T) {
-			err := pr.RemoveQuote(ctx, test.idQuote)
+			err := pr.RemoveQuote(ctx, test.idQuote, 0)
 			if !errors.Is(err, test.err) {
 				t.Errorf("errors not equal {got}:{want} {%v}{%v}", err, test.err)
 			}
 		})
 	}
 }
+
+// нулевое время создания и изменения - для тестов, которые сравнивают цитаты целиком,
+// само время проверяется в 'TestProvider_Timestamps'
+func zeroClock() Option {
+	return WithClock(func() time.Time { return time.Time{} })
+}
+
+// хранилище без снимка для тестов
+func newTestProvider(t *testing.T, opts ...Option) *provider {
+	t.Helper()
+
+	pr, err := NewProvider(opts...)
+	if err != nil {
+		t.Fatalf("NewProvider: error should be nil - {%v}", err)
+	}
+
+	return pr
+}
+
+// хранилище из 'newTestProvider' с цитатами 'data'
+func seedProvider(t *testing.T, data []model.Quote, opts ...Option) *provider {
+	t.Helper()
+
+	pr := newTestProvider(t, opts...)
+	for _, quote := range data {
+		if _, err := pr.NewQuote(context.TODO(), quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	return pr
+}
+
+func TestProvider_UpdateQuote(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t, zeroClock())
+
+	for _, quote := range quotesData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	newAuthor := quotesData[2].Author
+	newBody := `Stay hungry, stay foolish`
+
+	testData := []struct {
+		title   string
+		idQuote uint
+		patch   QuotePatch
+		quote   *model.Quote
+		err     error
+	}{
+		{
+			title:   `body collides with other quote`,
+			idQuote: 1,
+			patch:   QuotePatch{Body: &quotesData[1].Body},
+			quote:   nil,
+			err:     ErrDBAlreadyExists,
+		},
+		{
+			title:   `quote not found`,
+			idQuote: 10,
+			patch:   QuotePatch{Body: &newBody},
+			quote:   nil,
+			err:     ErrDBNotFound,
+		},
+		{
+			title:   `change author and body`,
+			idQuote: 1,
+			patch:   QuotePatch{Author: &newAuthor, Body: &newBody},
+			quote:   &model.Quote{ID: 1, Author: newAuthor, Body: newBody, Revision: 4},
+			err:     nil,
+		},
+		{
+			title:   `same body for the same quote`,
+			idQuote: 1,
+			patch:   QuotePatch{Body: &newBody},
+			quote:   &model.Quote{ID: 1, Author: newAuthor, Body: newBody, Revision: 5},
+			err:     nil,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			quote, err := pr.UpdateQuote(ctx, test.idQuote, 0, test.patch)
+			if !errors.Is(err, test.err) {
+				t.Errorf("errors not equal {got}:{want} {%v}{%v}", err, test.err)
+			}
+
+			if !reflect.DeepEqual(quote, test.quote) {
+				t.Errorf("quote not equal {got}:{want} {%v}{%v}", quote, test.quote)
+			}
+		})
+	}
+
+	// индексы после смены автора и текста
+	if _, _, err := pr.QuoteListByAuthor(ctx, quotesData[0].Author); !errors.Is(err, ErrDBNotFound) {
+		t.Errorf("errors not equal {got}:{want} {%v}{%v}", err, ErrDBNotFound)
+	}
+
+	byAuthor := pr.listOfQuoteIDByAuthor[utils.NormalizeKey(newAuthor)]
+	if !reflect.DeepEqual(byAuthor, []uint{1, 3}) {
+		t.Errorf("author index not equal {got}:{want} {%v}{%v}", byAuthor, []uint{1, 3})
+	}
+
+	// старый текст снова свободен
+	if _, err := pr.NewQuote(ctx, quotesData[0]); err != nil {
+		t.Errorf("NewQuote: error should be nil - {%v}", err)
+	}
+}
+
+func TestProvider_UpdateQuoteBrokenIndex(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t)
+
+	stored, err := pr.NewQuote(ctx, model.Quote{Author: quotesData[0].Author, Body: quotesData[0].Body, Tags: []string{"stress"}})
+	if err != nil {
+		t.Fatalf("NewQuote: error should be nil - {%v}", err)
+	}
+
+	// ID пропал из списка тега - снять тег нельзя
+	delete(pr.listOfQuoteIDByTag, "stress")
+
+	newAuthor, newBody, noTags := quotesData[1].Author, `Stay hungry, stay foolish`, []string{}
+	patch := QuotePatch{Author: &newAuthor, Body: &newBody, Tags: &noTags}
+	if _, err := pr.UpdateQuote(ctx, stored.ID, 0, patch); !errors.Is(err, ErrDBInternal) {
+		t.Fatalf("errors not equal {got}:{want} {%v}{%v}", err, ErrDBInternal)
+	}
+
+	// ошибка до первого изменения - индексы прежние
+	if _, ex := pr.uniqQuote[utils.NormalizeKey(newBody)]; ex {
+		t.Errorf("new body in uniqQuote after failed update")
+	}
+	if _, ex := pr.uniqQuote[utils.NormalizeKey(quotesData[0].Body)]; !ex {
+		t.Errorf("old body not in uniqQuote after failed update")
+	}
+
+	byAuthor := pr.listOfQuoteIDByAuthor[utils.NormalizeKey(quotesData[0].Author)]
+	if !reflect.DeepEqual(byAuthor, []uint{stored.ID}) {
+		t.Errorf("author index not equal {got}:{want} {%v}{%v}", byAuthor, []uint{stored.ID})
+	}
+
+	if quote := pr.quoteByID[stored.ID]; !reflect.DeepEqual(quote, *stored) {
+		t.Errorf("quote not equal {got}:{want} {%v}{%v}", quote, *stored)
+	}
+}
+
+func TestProvider_RemoveQuoteBrokenIndex(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t)
+
+	stored, err := pr.NewQuote(ctx, model.Quote{Author: quotesData[0].Author, Body: quotesData[0].Body, Tags: []string{"stress"}})
+	if err != nil {
+		t.Fatalf("NewQuote: error should be nil - {%v}", err)
+	}
+
+	// ID пропал из списка тега - удалить цитату нельзя
+	delete(pr.listOfQuoteIDByTag, "stress")
+
+	if err := pr.RemoveQuote(ctx, stored.ID, 0); !errors.Is(err, ErrDBInternal) {
+		t.Fatalf("errors not equal {got}:{want} {%v}{%v}", err, ErrDBInternal)
+	}
+
+	// ошибка до первого изменения - индексы прежние
+	byAuthor := pr.listOfQuoteIDByAuthor[utils.NormalizeKey(quotesData[0].Author)]
+	if !reflect.DeepEqual(byAuthor, []uint{stored.ID}) {
+		t.Errorf("author index not equal {got}:{want} {%v}{%v}", byAuthor, []uint{stored.ID})
+	}
+	if _, ex := pr.uniqQuote[utils.NormalizeKey(quotesData[0].Body)]; !ex {
+		t.Errorf("body not in uniqQuote after failed remove")
+	}
+	if !reflect.DeepEqual(pr.validQuoteID, []uint{stored.ID}) {
+		t.Errorf("validQuoteID not equal {got}:{want} {%v}{%v}", pr.validQuoteID, []uint{stored.ID})
+	}
+	if quote := pr.quoteByID[stored.ID]; !reflect.DeepEqual(quote, *stored) {
+		t.Errorf("quote not equal {got}:{want} {%v}{%v}", quote, *stored)
+	}
+}
+
+func TestProvider_QuotePage(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t)
+
+	if _, err := pr.QuotePage(ctx, PageQuery{}); !errors.Is(err, ErrDBEmpty) {
+		t.Fatalf("the errors must be equal - {got}:{want} {%v}:{%v}", err, ErrDBEmpty)
+	}
+
+	for i := 0; i < 6; i++ {
+		quote := model.Quote{Author: `Author`, Body: fmt.Sprintf("Body %d", i)}
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	// курсор может указывать на удаленную цитату
+	if err := pr.RemoveQuote(ctx, 3, 0); err != nil {
+		t.Fatalf("RemoveQuote: error should be nil - {%v}", err)
+	}
+
+	testData := []struct {
+		title      string
+		query      PageQuery
+		ids        []uint
+		nextCursor uint
+	}{
+		{
+			title:      `asc first page`,
+			query:      PageQuery{Limit: 2},
+			ids:        []uint{1, 2},
+			nextCursor: 2,
+		},
+		{
+			title:      `asc after removed id`,
+			query:      PageQuery{Limit: 2, AfterID: 3},
+			ids:        []uint{4, 5},
+			nextCursor: 5,
+		},
+		{
+			title:      `asc last page`,
+			query:      PageQuery{Limit: 2, AfterID: 5},
+			ids:        []uint{6},
+			nextCursor: 0,
+		},
+		{
+			title:      `desc first page`,
+			query:      PageQuery{Limit: 2, Desc: true},
+			ids:        []uint{6, 5},
+			nextCursor: 5,
+		},
+		{
+			title:      `desc after removed id`,
+			query:      PageQuery{Limit: 2, AfterID: 3, Desc: true},
+			ids:        []uint{2, 1},
+			nextCursor: 0,
+		},
+		{
+			title:      `without limit`,
+			query:      PageQuery{},
+			ids:        []uint{1, 2, 4, 5, 6},
+			nextCursor: 0,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			page, err := pr.QuotePage(ctx, test.query)
+			if err != nil {
+				t.Fatalf("QuotePage: error should be nil - {%v}", err)
+			}
+
+			ids := make([]uint, 0, len(page.Quotes))
+			for _, quote := range page.Quotes {
+				ids = append(ids, quote.ID)
+			}
+
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("ids not equal {got}:{want} {%v}:{%v}", ids, test.ids)
+			}
+
+			if page.NextCursor != test.nextCursor {
+				t.Errorf("nextCursor not equal {got}:{want} {%d}:{%d}", page.NextCursor, test.nextCursor)
+			}
+
+			if page.Total != 5 {
+				t.Errorf("total not equal {got}:{want} {%d}:{%d}", page.Total, 5)
+			}
+		})
+	}
+}
+
+func TestProvider_NormalizedKeys(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t, zeroClock())
+
+	for _, quote := range quotesData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	// отличается регистром, пробелами и апострофами
+	duplicate := model.Quote{
+		Author: `steve  jobs`,
+		Body:   `YOUR time is limited,  so don't waste it living someone else's life`,
+	}
+	if _, err := pr.NewQuote(ctx, duplicate); !errors.Is(err, ErrDBAlreadyExists) {
+		t.Errorf("errors not equal {got}:{want} {%v}:{%v};", err, ErrDBAlreadyExists)
+	}
+
+	// поиск автора без учета регистра, отдаем исходное написание
+	quotes, _, err := pr.QuoteListByAuthor(ctx, ` STEVE   Jobs `)
+	if err != nil {
+		t.Fatalf("QuoteListByAuthor: error should be nil - {%v}", err)
+	}
+
+	want := []model.Quote{{ID: 3, Author: quotesData[2].Author, Body: quotesData[2].Body, Revision: 3}}
+	if !reflect.DeepEqual(quotes, want) {
+		t.Errorf("quotes not equal - {got}:{want} {%v}:{%v}", quotes, want)
+	}
+
+	// изменение только регистра - не конфликт с самой собой
+	upper := `MY DICTIONARY DOES NOT CONTAIN THE WORD 'IMPOSSIBLE'`
+	quote, err := pr.UpdateQuote(ctx, 2, 0, QuotePatch{Body: &upper})
+	if err != nil {
+		t.Fatalf("UpdateQuote: error should be nil - {%v}", err)
+	}
+	if quote.Body != upper {
+		t.Errorf("body not equal {got}:{want} {%s}:{%s}", quote.Body, upper)
+	}
+}
+
+func TestProvider_Timestamps(t *testing.T) {
+	ctx := context.TODO()
+
+	// каждый вызов - на минуту позже
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pr := newTestProvider(t, WithClock(func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}))
+
+	if _, err := pr.NewQuote(ctx, quotesData[0]); err != nil {
+		t.Fatalf("NewQuote: error should be nil - {%v}", err)
+	}
+
+	created := time.Date(2025, 1, 1, 0, 1, 0, 0, time.UTC)
+	if quote := pr.quoteByID[1]; !quote.CreatedAt.Equal(created) || !quote.UpdatedAt.Equal(created) {
+		t.Errorf("timestamps not equal {got}:{want} {%v, %v}:{%v}", quote.CreatedAt, quote.UpdatedAt, created)
+	}
+
+	source := `The Principles of Psychology`
+	quote, err := pr.UpdateQuote(ctx, 1, 0, QuotePatch{Source: &source})
+	if err != nil {
+		t.Fatalf("UpdateQuote: error should be nil - {%v}", err)
+	}
+
+	updated := time.Date(2025, 1, 1, 0, 2, 0, 0, time.UTC)
+	if !quote.CreatedAt.Equal(created) || !quote.UpdatedAt.Equal(updated) {
+		t.Errorf("timestamps not equal {got}:{want} {%v, %v}:{%v, %v}",
+			quote.CreatedAt, quote.UpdatedAt, created, updated)
+	}
+
+	if quote.Source != source {
+		t.Errorf("source not equal {got}:{want} {%s}:{%s}", quote.Source, source)
+	}
+}
+
+func TestProvider_QuotePageFilter(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t)
+
+	metadata := []model.Quote{
+		{Author: `A`, Body: `first`, Source: `Essays`, Year: 1841, Language: `en`},
+		{Author: `A`, Body: `second`, Source: `essays `, Year: 1844, Language: `en`},
+		{Author: `B`, Body: `third`, Year: -500, Language: `zh`},
+		{Author: `B`, Body: `fourth`, Language: `en`},
+		{Author: `C`, Body: `fifth`, Source: `Essays`, Year: 1850, Language: `fr`},
+	}
+
+	for _, quote := range metadata {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	testData := []struct {
+		title      string
+		query      PageQuery
+		ids        []uint
+		nextCursor uint
+		total      int
+	}{
+		{
+			title:      `source without case`,
+			query:      PageQuery{Limit: 2, Filter: QuoteFilter{Source: `ESSAYS`}},
+			ids:        []uint{1, 2},
+			nextCursor: 2,
+			total:      3,
+		},
+		{
+			title: `source next page`,
+			query: PageQuery{Limit: 2, AfterID: 2, Filter: QuoteFilter{Source: `ESSAYS`}},
+			ids:   []uint{5},
+			total: 3,
+		},
+		{
+			title: `language`,
+			query: PageQuery{Filter: QuoteFilter{Language: `en`}},
+			ids:   []uint{1, 2, 4},
+			total: 3,
+		},
+		{
+			title: `year range, quotes without year skipped`,
+			query: PageQuery{Desc: true, Filter: QuoteFilter{YearFrom: -1000, YearTo: 1845}},
+			ids:   []uint{3, 2, 1},
+			total: 3,
+		},
+		{
+			title: `nothing matched`,
+			query: PageQuery{Filter: QuoteFilter{Language: `de`}},
+			ids:   []uint{},
+			total: 0,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			page, err := pr.QuotePage(ctx, test.query)
+			if err != nil {
+				t.Fatalf("QuotePage: error should be nil - {%v}", err)
+			}
+
+			ids := make([]uint, 0, len(page.Quotes))
+			for _, quote := range page.Quotes {
+				ids = append(ids, quote.ID)
+			}
+
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("ids not equal {got}:{want} {%v}:{%v}", ids, test.ids)
+			}
+
+			if page.NextCursor != test.nextCursor || page.Total != test.total {
+				t.Errorf("nextCursor, total not equal {got}:{want} {%d, %d}:{%d, %d}",
+					page.NextCursor, page.Total, test.nextCursor, test.total)
+			}
+		})
+	}
+}
+
+func TestProvider_QuoteByID(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t, zeroClock())
+
+	created, err := pr.NewQuote(ctx, quotesData[1])
+	if err != nil {
+		t.Fatalf("NewQuote: error should be nil - {%v}", err)
+	}
+
+	want := quotesData[1]
+	want.ID = 1
+	want.Revision = 1
+	if !reflect.DeepEqual(*created, want) {
+		t.Errorf("NewQuote: quote not equal {got}:{want} {%v}:{%v}", *created, want)
+	}
+
+	testData := []struct {
+		title string
+		id    uint
+		quote *model.Quote
+		err   error
+	}{
+		{
+			title: `exists`,
+			id:    1,
+			quote: &want,
+		},
+		{
+			title: `not found`,
+			id:    2,
+			err:   ErrDBNotFound,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			quote, err := pr.QuoteByID(ctx, test.id)
+			if !errors.Is(err, test.err) {
+				t.Errorf("errors not equal {got}:{want} {%v}:{%v};", err, test.err)
+			}
+
+			if !reflect.DeepEqual(quote, test.quote) {
+				t.Errorf("quote not equal {got}:{want} {%v}:{%v}", quote, test.quote)
+			}
+		})
+	}
+}
+
+func TestProvider_Revision(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t)
+
+	for _, quote := range quotesData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	body := `Stay hungry, stay foolish`
+
+	testData := []struct {
+		title    string
+		change   func() error
+		err      error
+		revision uint64
+	}{
+		{
+			title: `update with old revision`,
+			change: func() error {
+				_, err := pr.UpdateQuote(ctx, 1, 4, QuotePatch{Body: &body})
+				return err
+			},
+			err:      ErrDBRevisionMismatch,
+			revision: 3,
+		},
+		{
+			title: `update with current revision`,
+			change: func() error {
+				_, err := pr.UpdateQuote(ctx, 1, 1, QuotePatch{Body: &body})
+				return err
+			},
+			revision: 4,
+		},
+		{
+			title: `remove with old revision`,
+			change: func() error {
+				return pr.RemoveQuote(ctx, 1, 1)
+			},
+			err:      ErrDBRevisionMismatch,
+			revision: 4,
+		},
+		{
+			title: `remove with current revision`,
+			change: func() error {
+				return pr.RemoveQuote(ctx, 1, 4)
+			},
+			revision: 5,
+		},
+		{
+			title: `change tags`,
+			change: func() error {
+				_, err := pr.AddQuoteTags(ctx, 2, []string{`history`})
+				return err
+			},
+			revision: 6,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			if err := test.change(); !errors.Is(err, test.err) {
+				t.Errorf("errors not equal {got}:{want} {%v}:{%v};", err, test.err)
+			}
+
+			page, err := pr.QuotePage(ctx, PageQuery{})
+			if err != nil {
+				t.Fatalf("QuotePage: error should be nil - {%v}", err)
+			}
+
+			if page.Revision != test.revision {
+				t.Errorf("page revision not equal {got}:{want} {%d}:{%d}", page.Revision, test.revision)
+			}
+		})
+	}
+
+	quote, err := pr.QuoteByID(ctx, 2)
+	if err != nil {
+		t.Fatalf("QuoteByID: error should be nil - {%v}", err)
+	}
+
+	if quote.Revision != 6 {
+		t.Errorf("quote revision not equal {got}:{want} {%d}:{%d}", quote.Revision, 6)
+	}
+}
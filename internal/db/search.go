@@ -0,0 +1,364 @@
+// полнотекстовый поиск по тексту цитат
+package db
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// запрос не содержит ни одного слова
+var ErrDBInvalidQuery = errors.New("invalid search query")
+
+// параметры ранжирования BM25
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// найденная цитата
+// 'Matches' - позиции совпадений в тексте цитаты (в символах, 'End' не включается)
+type SearchHit struct {
+	Quote   model.Quote
+	Score   float64
+	Matches []Match
+}
+
+// позиция совпадения в тексте
+type Match struct {
+	Start int
+	End   int
+}
+
+// слово текста и его позиция
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+// обратный индекс: слово -> ID цитаты -> номера слов в цитате
+// все методы вызываются под блокировкой 'provider'
+type invertedIndex struct {
+	postings map[string]map[uint][]int
+
+	// количество слов в каждой цитате и во всех цитатах, для BM25
+	docLen   map[uint]int
+	totalLen int
+}
+
+func newInvertedIndex() *invertedIndex {
+	return &invertedIndex{
+		postings: make(map[string]map[uint][]int),
+		docLen:   make(map[uint]int),
+	}
+}
+
+// добавляем слова цитаты
+func (idx *invertedIndex) add(id uint, body string) {
+	tokens := tokenize(body)
+
+	for pos, tok := range tokens {
+		docs, ex := idx.postings[tok.term]
+		if !ex {
+			docs = make(map[uint][]int)
+			idx.postings[tok.term] = docs
+		}
+		docs[id] = append(docs[id], pos)
+	}
+
+	idx.docLen[id] = len(tokens)
+	idx.totalLen += len(tokens)
+}
+
+// удаляем слова цитаты, 'body' - текст, с которым цитата добавлялась
+func (idx *invertedIndex) remove(id uint, body string) {
+	for _, tok := range tokenize(body) {
+		docs, ex := idx.postings[tok.term]
+		if !ex {
+			continue
+		}
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, tok.term)
+		}
+	}
+
+	idx.totalLen -= idx.docLen[id]
+	delete(idx.docLen, id)
+}
+
+// разбиваем текст на слова - последовательности букв и цифр в нижнем регистре
+// позиции считаем в символах (rune)
+func tokenize(text string) []token {
+	var (
+		tokens []token
+		word   strings.Builder
+		start  = -1
+		pos    = 0
+	)
+
+	flush := func() {
+		if start >= 0 {
+			tokens = append(tokens, token{term: word.String(), start: start, end: pos})
+			word.Reset()
+			start = -1
+		}
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = pos
+			}
+			word.WriteRune(unicode.ToLower(r))
+		} else {
+			flush()
+		}
+		pos++
+	}
+	flush()
+
+	return tokens
+}
+
+// часть запроса - слово или фраза (слова подряд)
+type clause []string
+
+// запрос: группы через OR, внутри группы условия через AND
+// пример: `time AND "waste it" OR impossible`
+type searchQuery [][]clause
+
+// разбор строки запроса
+// фраза - в двойных кавычках, 'AND' / 'OR' - операторы (AND по умолчанию)
+func parseQuery(raw string) (searchQuery, error) {
+	var (
+		query searchQuery
+		group []clause
+	)
+
+	addClause := func(text string) {
+		terms := make([]string, 0, 1)
+		for _, tok := range tokenize(text) {
+			terms = append(terms, tok.term)
+		}
+		if len(terms) == 0 {
+			return
+		}
+		group = append(group, clause(terms))
+	}
+
+	parts := strings.Split(raw, `"`)
+	for i, part := range parts {
+		// нечетные части - внутри кавычек
+		if i%2 == 1 {
+			addClause(part)
+			continue
+		}
+
+		for _, field := range strings.Fields(part) {
+			switch field {
+			case "OR":
+				if len(group) > 0 {
+					query = append(query, group)
+					group = nil
+				}
+			case "AND":
+			default:
+				// слова без кавычек -> отдельные условия
+				for _, tok := range tokenize(field) {
+					addClause(tok.term)
+				}
+			}
+		}
+	}
+
+	if len(group) > 0 {
+		query = append(query, group)
+	}
+
+	if len(query) == 0 {
+		return nil, ErrDBInvalidQuery
+	}
+
+	return query, nil
+}
+
+// позиции начала фразы 'c' в цитате 'id', nil -> нет совпадений
+func (idx *invertedIndex) phrase(c clause, id uint) []int {
+	first := idx.postings[c[0]][id]
+	if len(first) == 0 {
+		return nil
+	}
+
+	starts := make([]int, 0, len(first))
+
+	for _, start := range first {
+		matched := true
+		for shift, term := range c[1:] {
+			positions := idx.postings[term][id]
+			i := sort.SearchInts(positions, start+shift+1)
+			if i == len(positions) || positions[i] != start+shift+1 {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			starts = append(starts, start)
+		}
+	}
+
+	if len(starts) == 0 {
+		return nil
+	}
+
+	return starts
+}
+
+// цитаты, в которых есть все условия группы
+func (idx *invertedIndex) matchGroup(group []clause) map[uint]struct{} {
+	// кандидаты - цитаты с первым словом первого условия
+	var result map[uint]struct{}
+
+	for _, c := range group {
+		docs := idx.postings[c[0]]
+		next := make(map[uint]struct{})
+
+		for id := range docs {
+			if result != nil {
+				if _, ex := result[id]; !ex {
+					continue
+				}
+			}
+			if idx.phrase(c, id) != nil {
+				next[id] = struct{}{}
+			}
+		}
+
+		result = next
+		if len(result) == 0 {
+			break
+		}
+	}
+
+	return result
+}
+
+// BM25 для цитаты 'id' по всем словам запроса
+// 'terms' упорядочены - сумма не зависит от порядка обхода map
+func (idx *invertedIndex) score(terms []string, id uint) float64 {
+	n := float64(len(idx.docLen))
+	avgLen := float64(idx.totalLen) / n
+	docLen := float64(idx.docLen[id])
+
+	score := 0.0
+	for _, term := range terms {
+		docs := idx.postings[term]
+		tf := float64(len(docs[id]))
+		if tf == 0 {
+			continue
+		}
+
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*docLen/avgLen))
+	}
+
+	return score
+}
+
+// позиции всех совпавших слов и фраз в тексте цитаты
+func (idx *invertedIndex) highlight(query searchQuery, quote model.Quote) []Match {
+	tokens := tokenize(quote.Body)
+
+	seen := make(map[Match]struct{})
+	var matches []Match
+
+	for _, group := range query {
+		for _, c := range group {
+			for _, start := range idx.phrase(c, quote.ID) {
+				end := start + len(c) - 1
+				if end >= len(tokens) {
+					continue
+				}
+				m := Match{Start: tokens[start].start, End: tokens[end].end}
+				if _, ex := seen[m]; !ex {
+					seen[m] = struct{}{}
+					matches = append(matches, m)
+				}
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+
+	return matches
+}
+
+// поиск цитат по тексту с ранжированием BM25
+// результат упорядочен по убыванию 'Score', при равенстве - по возрастанию ID
+func (p *provider) SearchQuotes(ctx context.Context, rawQuery string) ([]SearchHit, error) {
+	defer p.observe("SearchQuotes", time.Now())
+
+	query, err := parseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	p.rwMu.RLock()
+	defer p.rwMu.RUnlock()
+
+	// цитаты, подходящие хотя бы под одну группу
+	found := make(map[uint]struct{})
+	uniqTerms := make(map[string]struct{})
+
+	for _, group := range query {
+		for id := range p.search.matchGroup(group) {
+			found[id] = struct{}{}
+		}
+		for _, c := range group {
+			for _, term := range c {
+				uniqTerms[term] = struct{}{}
+			}
+		}
+	}
+
+	terms := make([]string, 0, len(uniqTerms))
+	for term := range uniqTerms {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	hits := make([]SearchHit, 0, len(found))
+
+	for id := range found {
+		quote, ex := p.quoteByID[id]
+		if !ex {
+			p.log.ErrorContext(ctx, "db: SearchQuotes - internal - not exist quoteID", "id", id)
+			return nil, ErrDBInternal
+		}
+
+		hits = append(hits, SearchHit{
+			Quote:   quote,
+			Score:   p.search.score(terms, id),
+			Matches: p.search.highlight(query, quote),
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Quote.ID < hits[j].Quote.ID
+	})
+
+	return hits, nil
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// цитаты для поиска, ID = индекс + 1
+var searchData = []model.Quote{
+	{Author: `Steve Jobs`, Body: `Your time is limited, so don’t waste it living someone else’s life`},
+	{Author: `Benjamin Franklin`, Body: `Lost time is never found again`},
+	{Author: `William Penn`, Body: `Time is what we want most, but what we use worst`},
+	{Author: `Napoleon Bonaparte`, Body: `My dictionary does not contain the word 'impossible'`},
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize(`Don’t  waste, Время!`)
+	want := []token{
+		{term: `don`, start: 0, end: 3},
+		{term: `t`, start: 4, end: 5},
+		{term: `waste`, start: 7, end: 12},
+		{term: `время`, start: 14, end: 19},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens not equal {got}:{want} {%v}:{%v}", got, want)
+	}
+}
+
+func TestProvider_SearchQuotes(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t)
+
+	for _, quote := range searchData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	testData := []struct {
+		title   string
+		query   string
+		ids     []uint
+		matches []Match // совпадения первой найденной цитаты
+		err     error
+	}{
+		{
+			title:   `one word, short quote ranked first`,
+			query:   `time`,
+			ids:     []uint{2, 3, 1},
+			matches: []Match{{Start: 5, End: 9}},
+		},
+		{
+			title:   `implicit AND`,
+			query:   `time never`,
+			ids:     []uint{2},
+			matches: []Match{{Start: 5, End: 9}, {Start: 13, End: 18}},
+		},
+		{
+			title:   `explicit AND with OR`,
+			query:   `time AND waste OR impossible`,
+			ids:     []uint{1, 4},
+			matches: []Match{{Start: 5, End: 9}, {Start: 31, End: 36}},
+		},
+		{
+			title:   `phrase`,
+			query:   `"time is never"`,
+			ids:     []uint{2},
+			matches: []Match{{Start: 5, End: 18}},
+		},
+		{
+			title: `phrase words out of order`,
+			query: `"is time"`,
+			ids:   []uint{},
+		},
+		{
+			title: `case insensitive`,
+			query: `DICTIONARY`,
+			ids:   []uint{4},
+		},
+		{
+			title: `nothing to search`,
+			query: `AND " " OR`,
+			err:   ErrDBInvalidQuery,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			hits, err := pr.SearchQuotes(ctx, test.query)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("errors not equal {got}:{want} {%v}{%v}", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+
+			ids := make([]uint, 0, len(hits))
+			for _, hit := range hits {
+				ids = append(ids, hit.Quote.ID)
+			}
+
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("ids not equal {got}:{want} {%v}:{%v}", ids, test.ids)
+			}
+
+			if test.matches != nil && !reflect.DeepEqual(hits[0].Matches, test.matches) {
+				t.Errorf("matches not equal {got}:{want} {%v}:{%v}", hits[0].Matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestProvider_SearchIndexMaintenance(t *testing.T) {
+	ctx := context.TODO()
+	pr := newTestProvider(t)
+
+	for _, quote := range searchData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	if err := pr.RemoveQuote(ctx, 2, 0); err != nil {
+		t.Fatalf("RemoveQuote: error should be nil - {%v}", err)
+	}
+
+	body := `Dictionary of time`
+	if _, err := pr.UpdateQuote(ctx, 3, 0, QuotePatch{Body: &body}); err != nil {
+		t.Fatalf("UpdateQuote: error should be nil - {%v}", err)
+	}
+
+	hits, err := pr.SearchQuotes(ctx, `lost OR worst OR dictionary`)
+	if err != nil {
+		t.Fatalf("SearchQuotes: error should be nil - {%v}", err)
+	}
+
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.Quote.ID)
+	}
+
+	// короткий текст цитаты 3 выше по BM25
+	if !reflect.DeepEqual(ids, []uint{3, 4}) {
+		t.Errorf("ids not equal {got}:{want} {%v}:{%v}", ids, []uint{3, 4})
+	}
+
+	// слов в цитатах 1, 3, 4
+	if pr.search.totalLen != 14+3+8 {
+		t.Errorf("totalLen not equal {got}:{want} {%d}:{%d}", pr.search.totalLen, 14+3+8)
+	}
+}
@@ -0,0 +1,176 @@
+// снимок хранилища на диске
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// текущая версия формата снимка
+const snapshotVersion = 1
+
+// содержимое файла снимка
+// 'CurID' сохраняем отдельно - ID удаленных цитат не используются повторно
+// 'Seq' - номер последней записи журнала, вошедшей в снимок
+// 'Revision' - ревизия хранилища, нет в старых снимках -> считаем по цитатам
+type snapshot struct {
+	Version  int           `json:"version"`
+	CurID    uint          `json:"cur_id"`
+	Seq      uint64        `json:"seq"`
+	Revision uint64        `json:"revision,omitempty"`
+	Quotes   []model.Quote `json:"quotes"`
+}
+
+// читаем снимок и восстанавливаем все индексы
+// файла нет -> начинаем с пустого хранилища
+func (p *provider) loadSnapshot() error {
+	data, err := os.ReadFile(p.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		p.log.Info("db: loadSnapshot file not found, start empty", "path", p.snapshotPath)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("db: snapshot decode - %w", err)
+	}
+
+	if snap.Version != snapshotVersion {
+		return ErrDBSnapshotVersion
+	}
+
+	p.lock()
+	defer p.rwMu.Unlock()
+
+	p.restore(snap)
+	p.dirty.Store(false)
+
+	p.log.Info("db: loadSnapshot", "quotes", len(snap.Quotes), "cur_id", p.curID)
+
+	return nil
+}
+
+// заполняем индексы из снимка, вызывается под Lock()
+func (p *provider) restore(snap snapshot) {
+	// 'insert' ожидает ID по возрастанию
+	sort.Slice(snap.Quotes, func(i, j int) bool {
+		return snap.Quotes[i].ID < snap.Quotes[j].ID
+	})
+
+	for _, quote := range snap.Quotes {
+		p.insert(quote)
+	}
+
+	p.curID = snap.CurID
+	p.seq = snap.Seq
+	p.revision = max(p.revision, snap.Revision)
+	if n := len(p.validQuoteID); n > 0 && p.validQuoteID[n-1] > p.curID {
+		p.curID = p.validQuoteID[n-1]
+	}
+}
+
+// копия данных под RLock()
+func (p *provider) takeSnapshot() snapshot {
+	p.rwMu.RLock()
+	defer p.rwMu.RUnlock()
+
+	return p.snapshotLocked()
+}
+
+// копия данных, вызывается под RLock() или Lock()
+func (p *provider) snapshotLocked() snapshot {
+	snap := snapshot{
+		Version:  snapshotVersion,
+		CurID:    p.curID,
+		Seq:      p.seq,
+		Revision: p.revision,
+		Quotes:   make([]model.Quote, 0, len(p.validQuoteID)),
+	}
+
+	for _, id := range p.validQuoteID {
+		snap.Quotes = append(snap.Quotes, p.quoteByID[id])
+	}
+
+	return snap
+}
+
+// пишем снимок, если были изменения
+// запись через временный файл и 'Rename' - файл снимка всегда целый
+func (p *provider) saveSnapshot() error {
+	if !p.dirty.Swap(false) {
+		return nil
+	}
+
+	if err := writeSnapshot(p.snapshotPath, p.takeSnapshot()); err != nil {
+		p.dirty.Store(true)
+		return err
+	}
+
+	p.log.Debug("db: saveSnapshot", "path", p.snapshotPath)
+
+	return nil
+}
+
+// фоновая запись снимка каждые 'snapshotInterval'
+func (p *provider) snapshotLoop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if err := p.saveSnapshot(); err != nil {
+				p.log.Error("db: snapshotLoop", "error", err)
+			}
+		}
+	}
+}
+
+// атомарная запись 'snap' в 'path'
+func writeSnapshot(path string, snap snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+func TestProvider_Snapshot(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "quotes.snapshot")
+
+	pr := newTestProvider(t, zeroClock(), WithSnapshot(path, time.Hour))
+
+	for _, quote := range quotesData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	// последний ID удален, после загрузки он не должен использоваться повторно
+	if err := pr.RemoveQuote(ctx, 3, 0); err != nil {
+		t.Fatalf("RemoveQuote: error should be nil - {%v}", err)
+	}
+
+	if err := pr.Close(); err != nil {
+		t.Fatalf("Close: error should be nil - {%v}", err)
+	}
+
+	restored := newTestProvider(t, WithSnapshot(path, time.Hour))
+	defer restored.Close()
+
+	if restored.curID != 3 {
+		t.Errorf("curID not equal {got}:{want} {%d}:{%d}", restored.curID, 3)
+	}
+
+	// удаление тоже меняет ревизию -> берем ее из снимка, а не из цитат
+	if restored.revision != 4 {
+		t.Errorf("revision not equal {got}:{want} {%d}:{%d}", restored.revision, 4)
+	}
+
+	if !reflect.DeepEqual(restored.validQuoteID, []uint{1, 2}) {
+		t.Errorf("validQuoteID not equal {got}:{want} {%v}:{%v}", restored.validQuoteID, []uint{1, 2})
+	}
+
+	quotes, _, err := restored.QuoteListByAuthor(ctx, `Napoleon Bonaparte`)
+	if err != nil {
+		t.Fatalf("QuoteListByAuthor: error should be nil - {%v}", err)
+	}
+
+	want := []model.Quote{{ID: 2, Author: quotesData[1].Author, Body: quotesData[1].Body, Revision: 2}}
+	if !reflect.DeepEqual(quotes, want) {
+		t.Errorf("quotes not equal - {got}:{want} {%v}:{%v}", quotes, want)
+	}
+
+	// проверка уникальности после загрузки
+	if _, err := restored.NewQuote(ctx, quotesData[0]); !errors.Is(err, ErrDBAlreadyExists) {
+		t.Errorf("errors not equal {got}:{want} {%v}:{%v};", err, ErrDBAlreadyExists)
+	}
+
+	if _, err := restored.NewQuote(ctx, quotesData[2]); err != nil {
+		t.Fatalf("NewQuote: error should be nil - {%v}", err)
+	}
+
+	if restored.curID != 4 {
+		t.Errorf("curID not equal {got}:{want} {%d}:{%d}", restored.curID, 4)
+	}
+}
+
+func TestProvider_SnapshotVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.snapshot")
+
+	if err := os.WriteFile(path, []byte(`{"version":100,"cur_id":0,"quotes":[]}`), 0o644); err != nil {
+		t.Fatalf("os.WriteFile error - {%v};", err)
+	}
+
+	if _, err := NewProvider(WithSnapshot(path, time.Hour)); !errors.Is(err, ErrDBSnapshotVersion) {
+		t.Errorf("errors not equal {got}:{want} {%v}:{%v};", err, ErrDBSnapshotVersion)
+	}
+}
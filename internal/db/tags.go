@@ -0,0 +1,232 @@
+// теги цитат и индекс цитат по тегу
+package db
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// тег и количество цитат с ним
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// теги в виде 'utils.NormalizeKey', без пустых и повторов, по алфавиту
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	uniq := make(map[string]struct{}, len(tags))
+	result := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = utils.NormalizeKey(tag)
+		if tag == "" {
+			continue
+		}
+		if _, ex := uniq[tag]; ex {
+			continue
+		}
+		uniq[tag] = struct{}{}
+		result = append(result, tag)
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// удаляем 'id' из отсортированного списка 'index[key]', пустой список удаляется
+// false -> 'id' не найден
+func unlinkFromIndex(index map[string][]uint, key string, id uint) bool {
+	quotesID, ex := index[key]
+	if !ex {
+		return false
+	}
+
+	i, ex := utils.IndexByValue(quotesID, id)
+	if !ex {
+		return false
+	}
+
+	quotesID = append(quotesID[:i], quotesID[i+1:]...)
+	if len(quotesID) == 0 {
+		delete(index, key)
+	} else {
+		index[key] = quotesID
+	}
+
+	return true
+}
+
+// меняем теги цитаты 'id' в 'listOfQuoteIDByTag', вызывается под Lock()
+func (p *provider) relinkTags(id uint, oldTags, newTags []string) error {
+	for _, tag := range oldTags {
+		if !containsTag(newTags, tag) && !unlinkFromIndex(p.listOfQuoteIDByTag, tag, id) {
+			p.log.Error("db: relinkTags - internal - not exist id in listOfQuoteIDByTag", "id", id, "tag", tag)
+			return ErrDBInternal
+		}
+	}
+
+	for _, tag := range newTags {
+		if !containsTag(oldTags, tag) {
+			p.listOfQuoteIDByTag[tag] = utils.InsertByValue(p.listOfQuoteIDByTag[tag], id)
+		}
+	}
+
+	return nil
+}
+
+// теги отсортированы -> бинарный поиск
+func containsTag(tags []string, tag string) bool {
+	i := sort.SearchStrings(tags, tag)
+	return i < len(tags) && tags[i] == tag
+}
+
+// ID цитат с тегами 'tags' по возрастанию, вызывается под RLock()
+// 'any' - хотя бы один тег, иначе - все теги
+func (p *provider) idsByTags(tags []string, any bool) []uint {
+	lists := make([][]uint, 0, len(tags))
+	for _, tag := range tags {
+		lists = append(lists, p.listOfQuoteIDByTag[tag])
+	}
+
+	if any {
+		uniq := make(map[uint]struct{})
+		for _, list := range lists {
+			for _, id := range list {
+				uniq[id] = struct{}{}
+			}
+		}
+
+		ids := make([]uint, 0, len(uniq))
+		for id := range uniq {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		return ids
+	}
+
+	// пересечение начинаем с самого короткого списка
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	ids := make([]uint, 0, len(lists[0]))
+	for _, id := range lists[0] {
+		inAll := true
+		for _, list := range lists[1:] {
+			if _, ex := utils.IndexByValue(list, id); !ex {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// все теги с количеством цитат, по убыванию количества, затем по алфавиту
+func (p *provider) TagList(_ context.Context) ([]TagCount, error) {
+	defer p.observe("TagList", time.Now())
+
+	p.rwMu.RLock()
+	defer p.rwMu.RUnlock()
+
+	tags := make([]TagCount, 0, len(p.listOfQuoteIDByTag))
+	for tag, ids := range p.listOfQuoteIDByTag {
+		tags = append(tags, TagCount{Tag: tag, Count: len(ids)})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	return tags, nil
+}
+
+// добавляем теги к цитате 'id'
+func (p *provider) AddQuoteTags(ctx context.Context, id uint, tags []string) (*model.Quote, error) {
+	defer p.observe("AddQuoteTags", time.Now())
+
+	return p.changeTags(ctx, id, func(current []string) []string {
+		return normalizeTags(append(append([]string{}, current...), tags...))
+	})
+}
+
+// удаляем теги у цитаты 'id', отсутствующие теги пропускаются
+func (p *provider) RemoveQuoteTags(ctx context.Context, id uint, tags []string) (*model.Quote, error) {
+	defer p.observe("RemoveQuoteTags", time.Now())
+
+	remove := normalizeTags(tags)
+
+	return p.changeTags(ctx, id, func(current []string) []string {
+		result := make([]string, 0, len(current))
+		for _, tag := range current {
+			if !containsTag(remove, tag) {
+				result = append(result, tag)
+			}
+		}
+		return normalizeTags(result)
+	})
+}
+
+// общая часть 'AddQuoteTags' и 'RemoveQuoteTags'
+// новый набор тегов считаем под Lock() -> изменения не теряются при параллельных запросах
+func (p *provider) changeTags(
+	ctx context.Context,
+	id uint,
+	change func(current []string) []string) (*model.Quote, error) {
+	p.lock()
+	defer p.rwMu.Unlock()
+
+	quote, ex := p.quoteByID[id]
+	if !ex {
+		return nil, ErrDBNotFound
+	}
+
+	// набор тегов не изменился -> цитата как есть, без новой ревизии и записи в журнал
+	tags := change(quote.Tags)
+	if slices.Equal(tags, quote.Tags) {
+		return &quote, nil
+	}
+
+	quote.Tags = tags
+	quote.UpdatedAt = p.now().UTC()
+
+	if err := p.checkUpdate(quote); err != nil {
+		return nil, err
+	}
+
+	// запись в журнал до изменения данных
+	if err := p.logRecord(walRecord{Op: walOpUpdate, Quote: &quote}); err != nil {
+		return nil, err
+	}
+
+	if err := p.update(quote); err != nil {
+		return nil, err
+	}
+	p.compactIfNeeded()
+
+	p.log.DebugContext(ctx, "db: changeTags", "id", id, "tags", quote.Tags)
+
+	stored := p.quoteByID[id]
+
+	return &stored, nil
+}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// цитаты с тегами для тестов фильтра и индекса тегов
+var tagsData = []model.Quote{
+	{Author: `A`, Body: `first`, Language: `en`, Tags: []string{`Motivation`, `humor`}},
+	{Author: `B`, Body: `second`, Language: `en`, Tags: []string{`leadership`}},
+	{Author: `C`, Body: `third`, Language: `fr`, Tags: []string{`humor`, ` HUMOR `, ``}},
+	{Author: `D`, Body: `fourth`},
+}
+
+func Test_normalizeTags(t *testing.T) {
+	testData := []struct {
+		title string
+		tags  []string
+		want  []string
+	}{
+		{
+			title: `nil`,
+			tags:  nil,
+			want:  nil,
+		},
+		{
+			title: `only empty`,
+			tags:  []string{``, `  `},
+			want:  nil,
+		},
+		{
+			title: `case, spaces, duplicates and order`,
+			tags:  []string{`Humor`, ` motivation`, `HUMOR`, `Life  Lessons`},
+			want:  []string{`humor`, `life lessons`, `motivation`},
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			if got := normalizeTags(test.tags); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("normalizeTags: got - {%v} not equal want - {%v};", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProvider_QuotePageTags(t *testing.T) {
+	ctx := context.TODO()
+	pr := seedProvider(t, tagsData)
+
+	testData := []struct {
+		title      string
+		query      PageQuery
+		ids        []uint
+		nextCursor uint
+		total      int
+	}{
+		{
+			title: `one tag`,
+			query: PageQuery{Filter: QuoteFilter{Tags: []string{`Humor`}}},
+			ids:   []uint{1, 3},
+			total: 2,
+		},
+		{
+			title: `all tags`,
+			query: PageQuery{Filter: QuoteFilter{Tags: []string{`humor`, `motivation`}}},
+			ids:   []uint{1},
+			total: 1,
+		},
+		{
+			title:      `any tag with limit`,
+			query:      PageQuery{Limit: 2, Filter: QuoteFilter{Tags: []string{`humor`, `leadership`}, AnyTag: true}},
+			ids:        []uint{1, 2},
+			nextCursor: 2,
+			total:      3,
+		},
+		{
+			title: `any tag desc after cursor`,
+			query: PageQuery{Desc: true, AfterID: 3, Filter: QuoteFilter{Tags: []string{`humor`, `leadership`}, AnyTag: true}},
+			ids:   []uint{2, 1},
+			total: 3,
+		},
+		{
+			title: `all tags, first id missing in longer list`,
+			query: PageQuery{Filter: QuoteFilter{Tags: []string{`humor`, `leadership`}}},
+			ids:   []uint{},
+			total: 0,
+		},
+		{
+			title: `tag and language`,
+			query: PageQuery{Filter: QuoteFilter{Tags: []string{`humor`}, Language: `fr`}},
+			ids:   []uint{3},
+			total: 1,
+		},
+		{
+			title: `unknown tag`,
+			query: PageQuery{Filter: QuoteFilter{Tags: []string{`humor`, `unknown`}}},
+			ids:   []uint{},
+			total: 0,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			page, err := pr.QuotePage(ctx, test.query)
+			if err != nil {
+				t.Fatalf("QuotePage: error should be nil - {%v}", err)
+			}
+
+			ids := make([]uint, 0, len(page.Quotes))
+			for _, quote := range page.Quotes {
+				ids = append(ids, quote.ID)
+			}
+
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("ids not equal {got}:{want} {%v}:{%v}", ids, test.ids)
+			}
+
+			if page.NextCursor != test.nextCursor || page.Total != test.total {
+				t.Errorf("nextCursor, total not equal {got}:{want} {%d, %d}:{%d, %d}",
+					page.NextCursor, page.Total, test.nextCursor, test.total)
+			}
+		})
+	}
+}
+
+func TestProvider_TagList(t *testing.T) {
+	ctx := context.TODO()
+	pr := seedProvider(t, tagsData)
+
+	tags, err := pr.TagList(ctx)
+	if err != nil {
+		t.Fatalf("TagList: error should be nil - {%v}", err)
+	}
+
+	want := []TagCount{{`humor`, 2}, {`leadership`, 1}, {`motivation`, 1}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("TagList: got - {%v} not equal want - {%v};", tags, want)
+	}
+}
+
+func TestProvider_ChangeTags(t *testing.T) {
+	ctx := context.TODO()
+	pr := seedProvider(t, tagsData)
+
+	quote, err := pr.AddQuoteTags(ctx, 4, []string{`Leadership`, `wisdom`})
+	if err != nil {
+		t.Fatalf("AddQuoteTags: error should be nil - {%v}", err)
+	}
+	if want := []string{`leadership`, `wisdom`}; !reflect.DeepEqual(quote.Tags, want) {
+		t.Errorf("AddQuoteTags: tags - {%v} not equal want - {%v};", quote.Tags, want)
+	}
+
+	if _, err := pr.RemoveQuoteTags(ctx, 1, []string{`HUMOR`, `absent`}); err != nil {
+		t.Fatalf("RemoveQuoteTags: error should be nil - {%v}", err)
+	}
+
+	if err := pr.RemoveQuote(ctx, 2, 0); err != nil {
+		t.Fatalf("RemoveQuote: error should be nil - {%v}", err)
+	}
+
+	// тег удаленной цитаты остается только у цитаты 4
+	want := map[string][]uint{
+		`humor`:      {3},
+		`leadership`: {4},
+		`motivation`: {1},
+		`wisdom`:     {4},
+	}
+	if !reflect.DeepEqual(pr.listOfQuoteIDByTag, want) {
+		t.Errorf("listOfQuoteIDByTag: got - {%v} not equal want - {%v};", pr.listOfQuoteIDByTag, want)
+	}
+
+	// замена всех тегов через 'UpdateQuote'
+	if _, err := pr.UpdateQuote(ctx, 3, 0, QuotePatch{Tags: &[]string{}}); err != nil {
+		t.Fatalf("UpdateQuote: error should be nil - {%v}", err)
+	}
+	if _, ex := pr.listOfQuoteIDByTag[`humor`]; ex {
+		t.Errorf("listOfQuoteIDByTag: tag humor should be deleted")
+	}
+
+	if _, err := pr.AddQuoteTags(ctx, 100, []string{`humor`}); !errors.Is(err, ErrDBNotFound) {
+		t.Errorf("AddQuoteTags: error should be {%v}, got - {%v}", ErrDBNotFound, err)
+	}
+}
+
+func TestProvider_ChangeTagsNoop(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	pr := newWALProvider(t, dir, 1<<20)
+	defer pr.Close()
+
+	stored, err := pr.NewQuote(ctx, tagsData[0])
+	if err != nil {
+		t.Fatalf("NewQuote: error should be nil - {%v}", err)
+	}
+	size := pr.wal.size
+
+	// теги уже есть / тегов нет -> цитата без изменений
+	for title, change := range map[string]func() (*model.Quote, error){
+		`add present`:    func() (*model.Quote, error) { return pr.AddQuoteTags(ctx, stored.ID, []string{` HUMOR`}) },
+		`remove missing`: func() (*model.Quote, error) { return pr.RemoveQuoteTags(ctx, stored.ID, []string{`absent`}) },
+	} {
+		quote, err := change()
+		if err != nil {
+			t.Fatalf("%s: error should be nil - {%v}", title, err)
+		}
+		if !reflect.DeepEqual(*quote, *stored) {
+			t.Errorf("%s: quote - {%v} not equal want - {%v};", title, *quote, *stored)
+		}
+	}
+
+	if pr.revision != stored.Revision {
+		t.Errorf("revision: got - {%d} not equal want - {%d};", pr.revision, stored.Revision)
+	}
+	if pr.wal.size != size {
+		t.Errorf("wal size: got - {%d} not equal want - {%d};", pr.wal.size, size)
+	}
+}
+
+func TestProvider_TagsWALReplay(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	pr := newWALProvider(t, dir, 1<<20)
+	for _, quote := range tagsData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+	if _, err := pr.AddQuoteTags(ctx, 2, []string{`humor`}); err != nil {
+		t.Fatalf("AddQuoteTags: error should be nil - {%v}", err)
+	}
+	crash(t, pr)
+
+	restored := newWALProvider(t, dir, 1<<20)
+	defer restored.Close()
+
+	if !reflect.DeepEqual(restored.listOfQuoteIDByTag, pr.listOfQuoteIDByTag) {
+		t.Errorf("listOfQuoteIDByTag: got - {%v} not equal want - {%v};",
+			restored.listOfQuoteIDByTag, pr.listOfQuoteIDByTag)
+	}
+}
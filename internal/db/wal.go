@@ -0,0 +1,320 @@
+// журнал предзаписи (write-ahead log) для изменений хранилища
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// ошибка записи в журнал, изменение не применяется
+var ErrDBWAL = errors.New("write-ahead log error")
+
+// размер заголовка записи: длина данных (4 байта) + crc32 данных (4 байта)
+const walHeaderSize = 8
+
+// наибольший размер данных записи, длина больше - поврежденный заголовок
+// пакеты batch-запросов и импорта ограничены размером тела и с запасом меньше
+const walMaxRecordSize = 64 << 20
+
+// виды операций в журнале
+const (
+	walOpAdd    = "add"
+	walOpRemove = "remove"
+	walOpUpdate = "update"
+
+	// пакет цитат одной записью -> пакет применяется целиком или не применяется
+	walOpAddBatch    = "add_batch"
+	walOpRemoveBatch = "remove_batch"
+)
+
+// одна запись журнала
+// 'Seq' - порядковый номер, записи с 'Seq' <= 'snapshot.Seq' уже есть в снимке
+type walRecord struct {
+	Seq    uint64        `json:"seq"`
+	Op     string        `json:"op"`
+	Quote  *model.Quote  `json:"quote,omitempty"`
+	Quotes []model.Quote `json:"quotes,omitempty"`
+	ID     uint          `json:"id,omitempty"`
+	IDs    []uint        `json:"ids,omitempty"`
+}
+
+// файл журнала, все методы вызываются под Lock() 'provider'
+type wal struct {
+	file *os.File
+
+	// текущий размер файла, для компактизации
+	size int64
+}
+
+// читаем все целые записи из 'path' и открываем файл на дозапись
+// поврежденный или оборванный хвост (сбой во время записи) обрезается
+func openWAL(path string, logger *slog.Logger) (*wal, []walRecord, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, valid, err := readWAL(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	if info.Size() != valid {
+		logger.Warn("db: openWAL torn tail - truncate", "from", info.Size(), "to", valid)
+		if err := file.Truncate(valid); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+	}
+
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return &wal{file: file, size: valid}, records, nil
+}
+
+// последовательное чтение записей
+// возвращаем записи и размер целой части файла
+func readWAL(r io.Reader) ([]walRecord, int64, error) {
+	var (
+		records []walRecord
+		valid   int64
+		header  [walHeaderSize]byte
+	)
+
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			// EOF - конец журнала, ErrUnexpectedEOF - оборванный заголовок
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, valid, nil
+			}
+			return nil, 0, err
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		sum := binary.BigEndian.Uint32(header[4:])
+
+		// длине из заголовка не верим до проверки - не выделяем память под мусор
+		if length > walMaxRecordSize {
+			return records, valid, nil
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, valid, nil
+			}
+			return nil, 0, err
+		}
+
+		if crc32.ChecksumIEEE(payload) != sum {
+			return records, valid, nil
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return records, valid, nil
+		}
+
+		records = append(records, rec)
+		valid += walHeaderSize + int64(length)
+	}
+}
+
+// дописываем запись и ждем 'Sync' - только после этого изменение подтверждается
+func (w *wal) append(rec walRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > walMaxRecordSize {
+		return fmt.Errorf("record size - {%d} exceeds {%d}", len(payload), walMaxRecordSize)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(walHeaderSize + len(payload))
+
+	var header [walHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload))
+	buf.Write(header[:])
+	buf.Write(payload)
+
+	n, err := w.file.Write(buf.Bytes())
+	if err != nil {
+		w.rollback()
+		return err
+	}
+
+	// запись не подтверждена - убираем ее, иначе она всплывет при повторе журнала
+	if err := w.file.Sync(); err != nil {
+		w.rollback()
+		return err
+	}
+
+	w.size += int64(n)
+
+	return nil
+}
+
+// не оставляем в файле часть или неподтвержденную запись
+func (w *wal) rollback() {
+	if err := w.file.Truncate(w.size); err == nil {
+		w.file.Seek(w.size, io.SeekStart)
+	}
+}
+
+// очищаем журнал после записи снимка
+func (w *wal) truncate() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	w.size = 0
+
+	return w.file.Sync()
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
+
+// пишем изменение в журнал, вызывается под Lock() до изменения индексов
+// журнал не задан -> ничего не делаем
+func (p *provider) logRecord(rec walRecord) error {
+	if p.wal == nil {
+		return nil
+	}
+
+	rec.Seq = p.seq + 1
+	if err := p.wal.append(rec); err != nil {
+		p.log.Error("db: logRecord", "error", err)
+		return fmt.Errorf("%w: %v", ErrDBWAL, err)
+	}
+
+	p.seq = rec.Seq
+
+	return nil
+}
+
+// повтор записей журнала после загрузки снимка, вызывается под Lock()
+// операции идемпотентны - повторное применение не меняет данные
+func (p *provider) replay(records []walRecord) error {
+	applied := 0
+
+	for _, rec := range records {
+		if rec.Seq <= p.seq {
+			continue
+		}
+
+		switch rec.Op {
+		case walOpAdd:
+			if rec.Quote == nil {
+				return fmt.Errorf("%w: record - {%d} without quote", ErrDBWAL, rec.Seq)
+			}
+			if _, ex := p.quoteByID[rec.Quote.ID]; !ex {
+				p.insert(*rec.Quote)
+			}
+			if rec.Quote.ID > p.curID {
+				p.curID = rec.Quote.ID
+			}
+		case walOpAddBatch:
+			for _, quote := range rec.Quotes {
+				if _, ex := p.quoteByID[quote.ID]; !ex {
+					p.insert(quote)
+				}
+				if quote.ID > p.curID {
+					p.curID = quote.ID
+				}
+			}
+		case walOpUpdate:
+			if rec.Quote == nil {
+				return fmt.Errorf("%w: record - {%d} without quote", ErrDBWAL, rec.Seq)
+			}
+			if err := p.update(*rec.Quote); err != nil && !errors.Is(err, ErrDBNotFound) {
+				return err
+			}
+		case walOpRemove:
+			if err := p.remove(rec.ID); err != nil && !errors.Is(err, ErrDBNotFound) {
+				return err
+			}
+		case walOpRemoveBatch:
+			for _, id := range rec.IDs {
+				if err := p.remove(id); err != nil && !errors.Is(err, ErrDBNotFound) {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("%w: unknown operation - {%s}", ErrDBWAL, rec.Op)
+		}
+
+		p.seq = rec.Seq
+		applied++
+	}
+
+	p.log.Info("db: replay", "records", applied, "seq", p.seq)
+
+	return nil
+}
+
+// журнал вырос больше 'walMaxSize' -> сворачиваем его в снимок
+// вызывается под Lock()
+func (p *provider) compactIfNeeded() {
+	if p.wal == nil || p.snapshotPath == "" || p.walMaxSize <= 0 || p.wal.size < p.walMaxSize {
+		return
+	}
+
+	if err := p.compact(); err != nil {
+		p.log.Error("db: compact", "error", err)
+	}
+}
+
+// пишем снимок текущего состояния и очищаем журнал, вызывается под Lock()
+// сбой между записью снимка и очисткой журнала безопасен - записи пропускаются по 'Seq'
+func (p *provider) compact() error {
+	if err := writeSnapshot(p.snapshotPath, p.snapshotLocked()); err != nil {
+		return err
+	}
+
+	p.dirty.Store(false)
+
+	if err := p.wal.truncate(); err != nil {
+		return err
+	}
+
+	p.log.Info("db: compact WAL into snapshot", "seq", p.seq)
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// хранилище со снимком и журналом в 't.TempDir()'
+// фоновая запись снимка выключена - на диск попадает только журнал
+func newWALProvider(t *testing.T, dir string, maxSize int64) *provider {
+	t.Helper()
+
+	return newTestProvider(t,
+		WithSnapshot(filepath.Join(dir, "quotes.snapshot"), 0),
+		WithWAL(filepath.Join(dir, "quotes.wal"), maxSize),
+	)
+}
+
+// сбой процесса - закрываем файл журнала без снимка
+func crash(t *testing.T, pr *provider) {
+	t.Helper()
+
+	if err := pr.wal.close(); err != nil {
+		t.Fatalf("wal.close: error should be nil - {%v}", err)
+	}
+}
+
+func TestProvider_WALReplay(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	pr := newWALProvider(t, dir, 1<<20)
+
+	for _, quote := range quotesData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	if err := pr.RemoveQuote(ctx, 1, 0); err != nil {
+		t.Fatalf("RemoveQuote: error should be nil - {%v}", err)
+	}
+
+	if _, err := pr.UpdateQuote(ctx, 2, 0, QuotePatch{Author: &quotesData[2].Author}); err != nil {
+		t.Fatalf("UpdateQuote: error should be nil - {%v}", err)
+	}
+
+	crash(t, pr)
+
+	if _, err := os.Stat(filepath.Join(dir, "quotes.snapshot")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("snapshot should not exist - {%v}", err)
+	}
+
+	restored := newWALProvider(t, dir, 1<<20)
+	defer restored.Close()
+
+	if !reflect.DeepEqual(restored.validQuoteID, []uint{2, 3}) {
+		t.Errorf("validQuoteID not equal {got}:{want} {%v}:{%v}", restored.validQuoteID, []uint{2, 3})
+	}
+
+	if restored.curID != 3 {
+		t.Errorf("curID not equal {got}:{want} {%d}:{%d}", restored.curID, 3)
+	}
+
+	if restored.seq != 5 {
+		t.Errorf("seq not equal {got}:{want} {%d}:{%d}", restored.seq, 5)
+	}
+
+	// ревизии после повтора журнала те же, что и до сбоя
+	if restored.revision != 5 || restored.quoteByID[2].Revision != 5 {
+		t.Errorf("revision not equal {got}:{want} {%d, %d}:{%d, %d}",
+			restored.revision, restored.quoteByID[2].Revision, 5, 5)
+	}
+
+	byAuthor := restored.listOfQuoteIDByAuthor[utils.NormalizeKey(quotesData[2].Author)]
+	if !reflect.DeepEqual(byAuthor, []uint{2, 3}) {
+		t.Errorf("author index not equal {got}:{want} {%v}:{%v}", byAuthor, []uint{2, 3})
+	}
+}
+
+func TestProvider_WALTornRecord(t *testing.T) {
+	testData := []struct {
+		title string
+		// портим последнюю запись в содержимом журнала, 'last' - ее смещение
+		tear func(data []byte, last int) []byte
+	}{
+		{
+			title: `last record cut in payload`,
+			tear: func(data []byte, _ int) []byte {
+				return data[:len(data)-5]
+			},
+		},
+		{
+			title: `last record cut in header`,
+			tear: func(data []byte, last int) []byte {
+				return data[:last+walHeaderSize/2]
+			},
+		},
+		{
+			title: `last record with wrong checksum`,
+			tear: func(data []byte, _ int) []byte {
+				data[len(data)-2] ^= 0xff
+				return data
+			},
+		},
+		{
+			title: `last record with huge length`,
+			tear: func(data []byte, last int) []byte {
+				binary.BigEndian.PutUint32(data[last:last+4], math.MaxUint32)
+				return data
+			},
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			ctx := context.TODO()
+			dir := t.TempDir()
+			walPath := filepath.Join(dir, "quotes.wal")
+
+			pr := newWALProvider(t, dir, 1<<20)
+
+			for _, quote := range quotesData {
+				if _, err := pr.NewQuote(ctx, quote); err != nil {
+					t.Fatalf("NewQuote: error should be nil - {%v}", err)
+				}
+			}
+
+			crash(t, pr)
+
+			data, err := os.ReadFile(walPath)
+			if err != nil {
+				t.Fatalf("os.ReadFile error - {%v};", err)
+			}
+
+			// смещение последней записи
+			last := 0
+			for next := 0; next < len(data); {
+				last = next
+				next += walHeaderSize + int(binary.BigEndian.Uint32(data[next:next+4]))
+			}
+
+			if err := os.WriteFile(walPath, test.tear(data, last), 0o644); err != nil {
+				t.Fatalf("os.WriteFile error - {%v};", err)
+			}
+
+			restored := newWALProvider(t, dir, 1<<20)
+
+			// после оборванной записи журнал продолжает работать
+			if _, err := restored.NewQuote(ctx, quotesData[2]); err != nil {
+				t.Fatalf("NewQuote: error should be nil - {%v}", err)
+			}
+
+			crash(t, restored)
+
+			again := newWALProvider(t, dir, 1<<20)
+			defer again.Close()
+
+			if len(again.quoteByID) != len(quotesData) {
+				t.Errorf("quotes count not equal {got}:{want} {%d}:{%d}", len(again.quoteByID), len(quotesData))
+			}
+
+			if _, _, err := again.QuoteListByAuthor(ctx, quotesData[2].Author); err != nil {
+				t.Errorf("QuoteListByAuthor: error should be nil - {%v}", err)
+			}
+		})
+	}
+}
+
+func TestProvider_WALCompaction(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	// каждая запись больше 1 байта -> сворачиваем после каждого изменения
+	pr := newWALProvider(t, dir, 1)
+
+	for _, quote := range quotesData {
+		if _, err := pr.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("NewQuote: error should be nil - {%v}", err)
+		}
+	}
+
+	if pr.wal.size != 0 {
+		t.Errorf("wal size not equal {got}:{want} {%d}:{%d}", pr.wal.size, 0)
+	}
+
+	crash(t, pr)
+
+	restored := newWALProvider(t, dir, 1)
+	defer restored.Close()
+
+	if len(restored.quoteByID) != len(quotesData) || restored.seq != 3 {
+		t.Errorf("restored state not equal {got}:{want} {%d, %d}:{%d, %d}",
+			len(restored.quoteByID), restored.seq, len(quotesData), 3)
+	}
+}
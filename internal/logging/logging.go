@@ -0,0 +1,94 @@
+// структурированный лог (log/slog) с ID запроса из контекста
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// форматы вывода лога
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ключ ID запроса в логе
+const RequestIDKey = "request_id"
+
+var ErrLoggingFormat = errors.New("unknown log format")
+
+// ключи значений в 'context.Context'
+type (
+	requestIDKey struct{}
+	loggerKey    struct{}
+)
+
+// логгер с выводом в 'w' в формате 'format' ("text" или "json")
+// 'level' можно менять на ходу, если это '*slog.LevelVar'
+// записи с контекстом ('InfoContext' и т.д.) получают ID запроса из контекста
+func New(w io.Writer, level slog.Leveler, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, ErrLoggingFormat
+	}
+
+	return slog.New(contextHandler{Handler: handler}), nil
+}
+
+// проверка формата для 'config'
+func ValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// контекст с ID запроса
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// ID запроса из 'ctx', нет -> пустая строка
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// контекст с логгером для обработчиков запроса
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// логгер из 'ctx', нет -> 'slog.Default()'
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// добавляет к записи ID запроса из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
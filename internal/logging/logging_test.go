@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	testData := []struct {
+		title    string
+		level    slog.Level
+		format   string
+		withID   bool
+		expected string
+		err      error
+	}{
+		{
+			title:    `text with request id`,
+			level:    slog.LevelInfo,
+			format:   FormatText,
+			withID:   true,
+			expected: "level=INFO msg=\"db: NewQuote\" id=1 request_id=abc\n",
+		},
+		{
+			title:    `json without request id`,
+			level:    slog.LevelDebug,
+			format:   FormatJSON,
+			expected: "{\"level\":\"INFO\",\"msg\":\"db: NewQuote\",\"id\":1}\n",
+		},
+		{
+			title:  `level filters record`,
+			level:  slog.LevelWarn,
+			format: FormatJSON,
+			withID: true,
+		},
+		{
+			title:  `unknown format`,
+			format: "xml",
+			err:    ErrLoggingFormat,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+
+			logger, err := New(buf, test.level, test.format)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("New error {got}:{want} {%v}:{%v};", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+
+			ctx := context.Background()
+			if test.withID {
+				ctx = WithRequestID(ctx, "abc")
+			}
+			logger.InfoContext(ctx, "db: NewQuote", "id", 1)
+
+			// время в записи каждый раз новое
+			got := buf.String()
+			if test.format == FormatText {
+				got = strings.TrimPrefix(got[strings.Index(got, " ")+1:], " ")
+			} else if got != "" {
+				got = "{" + got[strings.Index(got, `"level"`):]
+			}
+
+			if got != test.expected {
+				t.Errorf("record {got}:{want} {%s}:{%s};", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if logger := FromContext(context.Background()); logger != slog.Default() {
+		t.Errorf("FromContext without logger is not slog.Default()")
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	if got := FromContext(NewContext(context.Background(), logger)); got != logger {
+		t.Errorf("FromContext {got}:{want} {%p}:{%p};", got, logger)
+	}
+}
@@ -0,0 +1,321 @@
+// метрики в текстовом формате Prometheus
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// "Content-Type" ответа 'Handler'
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// границы корзин гистограммы по умолчанию, в секундах
+var DefBuckets = []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// метрика, которую умеет выводить 'Registry'
+type collector interface {
+	// имя метрики, для сортировки и проверки повторов
+	metricName() string
+	// "# HELP", "# TYPE" и значения
+	write(w *bufio.Writer)
+}
+
+// набор метрик для 'GET /metrics'
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// добавляем метрику, имя уже занято -> panic (ошибка в коде, а не в данных)
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, exist := range r.collectors {
+		if exist.metricName() == c.metricName() {
+			panic("metrics: duplicate metric " + c.metricName())
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// пишем все метрики в 'w' по возрастанию имени
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].metricName() < collectors[j].metricName()
+	})
+
+	cw := &countWriter{w: w}
+	buf := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+	err := buf.Flush()
+
+	return cw.n, err
+}
+
+// обработчик для 'GET /metrics'
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	}
+}
+
+// счетчик с метками
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels []string
+	value  float64
+}
+
+// новый счетчик в 'r', 'labels' - имена меток
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name: name, help: help, labels: labels}, series: map[string]*counterSeries{}}
+	r.register(c)
+	return c
+}
+
+// +1 для значений меток 'values' (в порядке имен меток)
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// +'v' для значений меток 'values', 'v' < 0 -> panic
+func (c *CounterVec) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	c.check(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := seriesKey(values)
+	s, ex := c.series[key]
+	if !ex {
+		s = &counterSeries{labels: slices.Clone(values)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.header(w, "counter")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		c.sample(w, "", s.labels, "", s.value)
+	}
+}
+
+// гистограмма с метками
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	// количество значений по корзинам, без накопления
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// новая гистограмма в 'r', 'buckets' - верхние границы корзин по возрастанию (без +Inf)
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !slices.IsSorted(buckets) {
+		panic("metrics: histogram " + name + " buckets are not sorted")
+	}
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, labels: labels},
+		buckets: slices.Clone(buckets),
+		series:  map[string]*histogramSeries{},
+	}
+	r.register(h)
+	return h
+}
+
+// значение 'v' для значений меток 'values'
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.check(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := seriesKey(values)
+	s, ex := h.series[key]
+	if !ex {
+		s = &histogramSeries{labels: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.header(w, "histogram")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			h.sample(w, "_bucket", s.labels, formatFloat(bound), float64(cumulative))
+		}
+		h.sample(w, "_bucket", s.labels, "+Inf", float64(s.count))
+		h.sample(w, "_sum", s.labels, "", s.sum)
+		h.sample(w, "_count", s.labels, "", float64(s.count))
+	}
+}
+
+// значение считается при каждом чтении метрик
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// новая метрика в 'r' со значением от 'fn', 'fn' вызывается конкурентно
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help}, fn: fn}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.header(w, "gauge")
+	g.sample(w, "", nil, "", g.fn())
+}
+
+// имя, описание и имена меток метрики
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) metricName() string {
+	return d.name
+}
+
+// количество значений меток не совпадает с именами -> panic
+func (d *desc) check(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+func (d *desc) header(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, kind)
+}
+
+// строка значения: имя с суффиксом, метки, 'le' для корзины гистограммы
+func (d *desc) sample(w *bufio.Writer, suffix string, values []string, le string, v float64) {
+	w.WriteString(d.name)
+	w.WriteString(suffix)
+
+	if len(values) > 0 || le != "" {
+		w.WriteByte('{')
+		for i, value := range values {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, d.labels[i], value)
+		}
+		if le != "" {
+			if len(values) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, "le", le)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	labelEscaper.WriteString(w, value)
+	w.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// ключ серии - значения меток через байт, которого нет в UTF-8
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// серии выводятся в одном порядке при каждом чтении
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// считает записанные байты для 'WriteTo'
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.NewCounterVec("test_requests_total", "Requests.", "route", "status")
+	requests.Inc("GET /quotes", "200")
+	requests.Inc("GET /quotes", "200")
+	requests.Add(3, `say "hi"\`, "404")
+
+	duration := reg.NewHistogramVec("test_duration_seconds", "Duration\nof requests.", []float64{0.1, 1}, "route")
+	duration.Observe(0.05, "GET /quotes")
+	duration.Observe(0.1, "GET /quotes")
+	duration.Observe(2, "GET /quotes")
+
+	reg.NewGaugeFunc("test_quotes", "Quotes.", func() float64 { return 7 })
+
+	lockWait := reg.NewHistogramVec("test_lock_wait_seconds", "Lock wait.", []float64{1})
+	lockWait.Observe(0.5)
+
+	const expected = `# HELP test_duration_seconds Duration\nof requests.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="GET /quotes",le="0.1"} 2
+test_duration_seconds_bucket{route="GET /quotes",le="1"} 2
+test_duration_seconds_bucket{route="GET /quotes",le="+Inf"} 3
+test_duration_seconds_sum{route="GET /quotes"} 2.15
+test_duration_seconds_count{route="GET /quotes"} 3
+# HELP test_lock_wait_seconds Lock wait.
+# TYPE test_lock_wait_seconds histogram
+test_lock_wait_seconds_bucket{le="1"} 1
+test_lock_wait_seconds_bucket{le="+Inf"} 1
+test_lock_wait_seconds_sum 0.5
+test_lock_wait_seconds_count 1
+# HELP test_quotes Quotes.
+# TYPE test_quotes gauge
+test_quotes 7
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="GET /quotes",status="200"} 2
+test_requests_total{route="say \"hi\"\\",status="404"} 3
+`
+
+	buf := &bytes.Buffer{}
+	n, err := reg.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("WriteTo error - {%v};", err)
+	}
+	if int(n) != buf.Len() {
+		t.Errorf("WriteTo n {got}:{want} {%d}:{%d};", n, buf.Len())
+	}
+	if buf.String() != expected {
+		t.Errorf("WriteTo {got}:{want} {%s}:{%s};", buf.String(), expected)
+	}
+
+	w := httptest.NewRecorder()
+	reg.Handler()(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Header().Get("Content-Type") != ContentType || w.Body.String() != expected {
+		t.Errorf("Handler {got}:{want} {%s %s}:{%s %s};", w.Header().Get("Content-Type"), w.Body.String(), ContentType, expected)
+	}
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	testData := []struct {
+		title string
+		fn    func(reg *Registry)
+	}{
+		{
+			title: `duplicate name`,
+			fn: func(reg *Registry) {
+				reg.NewCounterVec("dup", "a")
+				reg.NewGaugeFunc("dup", "b", func() float64 { return 0 })
+			},
+		},
+		{
+			title: `wrong label count`,
+			fn: func(reg *Registry) {
+				reg.NewCounterVec("labels", "a", "route").Inc()
+			},
+		},
+		{
+			title: `counter decrease`,
+			fn: func(reg *Registry) {
+				reg.NewCounterVec("decrease", "a").Add(-1)
+			},
+		},
+		{
+			title: `unsorted buckets`,
+			fn: func(reg *Registry) {
+				reg.NewHistogramVec("buckets", "a", []float64{1, 0.5})
+			},
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			test.fn(NewRegistry())
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// метрики хранилища цитат
+package metrics
+
+import "time"
+
+// время операций хранилища и ожидания блокировки на запись
+// подходит для 'db.WithObserver'
+type StoreMetrics struct {
+	operations *HistogramVec
+	lockWait   *HistogramVec
+}
+
+func NewStoreMetrics(reg *Registry) *StoreMetrics {
+	return &StoreMetrics{
+		operations: reg.NewHistogramVec("quotebook_store_operation_duration_seconds",
+			"Duration of store operations.", DefBuckets, "operation"),
+		lockWait: reg.NewHistogramVec("quotebook_store_lock_wait_seconds",
+			"Time spent waiting for the store write lock.", DefBuckets),
+	}
+}
+
+func (m *StoreMetrics) ObserveOperation(op string, d time.Duration) {
+	m.operations.Observe(d.Seconds(), op)
+}
+
+func (m *StoreMetrics) ObserveLockWait(d time.Duration) {
+	m.lockWait.Observe(d.Seconds())
+}
+
+// количество цитат и авторов на момент чтения метрик
+func NewStoreStats(reg *Registry, quotes, authors func() int) {
+	reg.NewGaugeFunc("quotebook_quotes", "Current number of quotes.", func() float64 {
+		return float64(quotes())
+	})
+	reg.NewGaugeFunc("quotebook_authors", "Current number of authors.", func() float64 {
+		return float64(authors())
+	})
+}
@@ -1,8 +1,32 @@
 // описание цитаты
 package model
 
+import "time"
+
 type Quote struct {
 	ID     uint
 	Author string
 	Body   string
+
+	// произведение, из которого взята цитата
+	Source string
+	// год, 0 -> неизвестен, до н.э. - отрицательный
+	Year int
+	// ссылка на источник
+	SourceURL string
+	// язык цитаты, тег BCP-47 в канонической форме
+	Language string
+
+	// темы цитаты, хранятся в виде 'utils.NormalizeKey', по алфавиту
+	Tags []string
+
+	// клиент, создавший цитату ('auth.Principal.Subject'), пусто -> создана без аутентификации
+	CreatedBy string
+
+	// задаются базой при создании и изменении
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// ревизия хранилища при последнем изменении цитаты, задается базой
+	Revision uint64
 }
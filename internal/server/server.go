@@ -17,12 +17,43 @@ type Srv struct {
 	*http.Server
 }
 
-// создаем адрес для сервера, добавляем 'Handler'
+// создаем адрес для сервера, добавляем 'Handler', таймауты и размер заголовков из 'cfg'
+// TLS задается отдельно - 'TLSConfig' из 'NewTLSConfig'
 func InitSRV(cfg *config.Config, router http.Handler) Srv {
+	return newSrv(cfg, cfg.ServerPort, router)
+}
+
+// сервер для проб и метрик на 'cfg.AdminPort', всегда без TLS
+func InitAdminSRV(cfg *config.Config, router http.Handler) Srv {
+	return newSrv(cfg, cfg.AdminPort, router)
+}
+
+func newSrv(cfg *config.Config, port string, router http.Handler) Srv {
 	return Srv{
 		Server: &http.Server{
-			Addr:    net.JoinHostPort(cfg.ServerHost, cfg.ServerPort),
-			Handler: router,
+			Addr:              net.JoinHostPort(cfg.ServerHost, port),
+			Handler:           router,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
 		},
 	}
 }
+
+// задан 'TLSConfig' -> 'ListenAndServeTLS' с сертификатами из него
+func (s Srv) ListenAndServe() error {
+	if s.TLSConfig == nil {
+		return s.Server.ListenAndServe()
+	}
+	return s.Server.ListenAndServeTLS("", "")
+}
+
+// то же для готового 'l'
+func (s Srv) Serve(l net.Listener) error {
+	if s.TLSConfig == nil {
+		return s.Server.Serve(l)
+	}
+	return s.Server.ServeTLS(l, "", "")
+}
@@ -0,0 +1,383 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
+)
+
+// сертификат с ключом и их PEM
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// сертификат 'template', подписанный 'parent' (nil -> самоподписанный)
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey error - {%v};", err)
+	}
+
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate error - {%v};", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate error - {%v};", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey error - {%v};", err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatalf("tls.X509KeyPair error - {%v};", err)
+	}
+	return cert
+}
+
+func caTemplate(name string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+}
+
+func leafTemplate(name string, usage x509.ExtKeyUsage) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+}
+
+// пишем 'data' в файл 'name' в 'dir'
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("os.WriteFile error - {%v};", err)
+	}
+	return path
+}
+
+func Test_InitSRV(t *testing.T) {
+	cfg := &config.Config{
+		ServerHost:        "127.0.0.1",
+		ServerPort:        "8080",
+		AdminPort:         "9090",
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      2 * time.Minute,
+		IdleTimeout:       3 * time.Minute,
+		MaxHeaderBytes:    64 << 10,
+	}
+
+	for _, srv := range []Srv{InitSRV(cfg, http.NotFoundHandler()), InitAdminSRV(cfg, http.NotFoundHandler())} {
+		got := [...]any{srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout, srv.MaxHeaderBytes}
+		expected := [...]any{cfg.ReadHeaderTimeout, cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout, cfg.MaxHeaderBytes}
+		if got != expected {
+			t.Errorf("%s limits {got}:{want} {%v}:{%v};", srv.Addr, got, expected)
+		}
+	}
+
+	if addr := InitAdminSRV(cfg, nil).Addr; addr != "127.0.0.1:9090" {
+		t.Errorf("admin addr {got}:{want} {%s}:{127.0.0.1:9090};", addr)
+	}
+}
+
+func Test_NewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, caTemplate("test ca"), nil)
+	srv := newTestCert(t, leafTemplate("server", x509.ExtKeyUsageServerAuth), ca)
+	other := newTestCert(t, leafTemplate("other", x509.ExtKeyUsageServerAuth), ca)
+
+	certPath := writeFile(t, dir, "server.pem", srv.certPEM)
+	keyPath := writeFile(t, dir, "server.key", srv.keyPEM)
+	otherKeyPath := writeFile(t, dir, "other.key", other.keyPEM)
+	caPath := writeFile(t, dir, "ca.pem", ca.certPEM)
+
+	testData := []struct {
+		title       string
+		cfg         config.Config
+		expectedNil bool
+		expectedErr error
+	}{
+		{
+			title:       `no tls`,
+			expectedNil: true,
+		},
+		{
+			title: `server certificate`,
+			cfg:   config.Config{TLSCertPath: certPath, TLSKeyPath: keyPath},
+		},
+		{
+			title: `client ca`,
+			cfg:   config.Config{TLSCertPath: certPath, TLSKeyPath: keyPath, TLSClientCAPath: caPath},
+		},
+		{
+			title:       `key of other certificate`,
+			cfg:         config.Config{TLSCertPath: certPath, TLSKeyPath: otherKeyPath},
+			expectedErr: ErrTLSInvalid,
+		},
+		{
+			title:       `no certificate file`,
+			cfg:         config.Config{TLSCertPath: filepath.Join(dir, "none.pem"), TLSKeyPath: keyPath},
+			expectedErr: ErrTLSInvalid,
+		},
+		{
+			title:       `client ca without certificates`,
+			cfg:         config.Config{TLSCertPath: certPath, TLSKeyPath: keyPath, TLSClientCAPath: keyPath},
+			expectedErr: ErrTLSInvalid,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			var cert *Certificate
+			var err error
+			if test.cfg.TLSCertPath != "" {
+				cert, err = LoadCertificate(test.cfg.TLSCertPath, test.cfg.TLSKeyPath)
+			}
+			var tlsConfig *tls.Config
+			if err == nil {
+				tlsConfig, err = NewTLSConfig(&test.cfg, cert)
+			}
+
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("error {got}:{want} {%v}:{%v};", err, test.expectedErr)
+			}
+			if err == nil && (tlsConfig == nil) != test.expectedNil {
+				t.Errorf("tls config is nil {got}:{want} {%t}:{%t};", tlsConfig == nil, test.expectedNil)
+			}
+		})
+	}
+}
+
+func Test_ServeTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, caTemplate("test ca"), nil)
+	srv := newTestCert(t, leafTemplate("server", x509.ExtKeyUsageServerAuth), ca)
+	client := newTestCert(t, leafTemplate("client", x509.ExtKeyUsageClientAuth), ca)
+	rogue := newTestCert(t, leafTemplate("rogue", x509.ExtKeyUsageClientAuth), newTestCert(t, caTemplate("rogue ca"), nil))
+
+	certPath := writeFile(t, dir, "server.pem", srv.certPEM)
+	keyPath := writeFile(t, dir, "server.key", srv.keyPEM)
+	caPath := writeFile(t, dir, "ca.pem", ca.certPEM)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	testData := []struct {
+		title            string
+		clientCAPath     string
+		minVersion       uint16
+		clientMaxVersion uint16
+		clientCert       *testCert
+		expectedErr      bool
+	}{
+		{
+			title:      `tls`,
+			minVersion: tls.VersionTLS12,
+		},
+		{
+			title:            `client below min version`,
+			minVersion:       tls.VersionTLS13,
+			clientMaxVersion: tls.VersionTLS12,
+			expectedErr:      true,
+		},
+		{
+			title:        `mtls`,
+			clientCAPath: caPath,
+			minVersion:   tls.VersionTLS12,
+			clientCert:   client,
+		},
+		{
+			title:        `mtls without client certificate`,
+			clientCAPath: caPath,
+			minVersion:   tls.VersionTLS12,
+			expectedErr:  true,
+		},
+		{
+			title:        `mtls with unknown ca`,
+			clientCAPath: caPath,
+			minVersion:   tls.VersionTLS12,
+			clientCert:   rogue,
+			expectedErr:  true,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			cfg := &config.Config{
+				ServerHost:        "127.0.0.1",
+				ReadHeaderTimeout: time.Second,
+				MaxHeaderBytes:    1 << 20,
+				TLSCertPath:       certPath,
+				TLSKeyPath:        keyPath,
+				TLSMinVersion:     test.minVersion,
+				TLSClientCAPath:   test.clientCAPath,
+			}
+
+			cert, err := LoadCertificate(cfg.TLSCertPath, cfg.TLSKeyPath)
+			if err != nil {
+				t.Fatalf("LoadCertificate error - {%v};", err)
+			}
+			tlsConfig, err := NewTLSConfig(cfg, cert)
+			if err != nil {
+				t.Fatalf("NewTLSConfig error - {%v};", err)
+			}
+
+			s := InitSRV(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			s.TLSConfig = tlsConfig
+			// ошибки рукопожатия ожидаемы
+			s.ErrorLog = log.New(io.Discard, "", 0)
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("net.Listen error - {%v};", err)
+			}
+			go s.Serve(l)
+			defer s.Close()
+
+			clientTLS := &tls.Config{RootCAs: roots, MaxVersion: test.clientMaxVersion}
+			if test.clientCert != nil {
+				clientTLS.Certificates = []tls.Certificate{test.clientCert.tlsCertificate(t)}
+			}
+			httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}, Timeout: 5 * time.Second}
+			defer httpClient.CloseIdleConnections()
+
+			resp, err := httpClient.Get("https://" + l.Addr().String() + "/")
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("request error {got}:{want} {%v}:{error %t};", err, test.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusNoContent || resp.TLS == nil {
+				t.Errorf("response {got}:{want} {%d %t}:{%d true};", resp.StatusCode, resp.TLS != nil, http.StatusNoContent)
+			}
+		})
+	}
+}
+
+func Test_CertificateReload(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, caTemplate("test ca"), nil)
+	first := newTestCert(t, leafTemplate("first", x509.ExtKeyUsageServerAuth), ca)
+	second := newTestCert(t, leafTemplate("second", x509.ExtKeyUsageServerAuth), ca)
+
+	certPath := writeFile(t, dir, "server.pem", first.certPEM)
+	keyPath := writeFile(t, dir, "server.key", first.keyPEM)
+
+	cert, err := LoadCertificate(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("LoadCertificate error - {%v};", err)
+	}
+	cfg := &config.Config{ServerHost: "127.0.0.1", TLSMinVersion: tls.VersionTLS12}
+	tlsConfig, err := NewTLSConfig(cfg, cert)
+	if err != nil {
+		t.Fatalf("NewTLSConfig error - {%v};", err)
+	}
+
+	s := InitSRV(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	s.TLSConfig = tlsConfig
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error - {%v};", err)
+	}
+	go s.Serve(l)
+	defer s.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	// имя в сертификате сервера для нового соединения
+	serverName := func() string {
+		conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: roots})
+		if err != nil {
+			t.Fatalf("tls.Dial error - {%v};", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	if name := serverName(); name != "first" {
+		t.Errorf("certificate before reload {got}:{want} {%s}:{first};", name)
+	}
+
+	// ключ от другого сертификата -> остается прежний
+	writeFile(t, dir, "server.pem", second.certPEM)
+	if err := cert.Reload(certPath, keyPath); !errors.Is(err, ErrTLSInvalid) {
+		t.Errorf("Reload mismatched key error {got}:{want} {%v}:{%v};", err, ErrTLSInvalid)
+	}
+	if name := serverName(); name != "first" {
+		t.Errorf("certificate after failed reload {got}:{want} {%s}:{first};", name)
+	}
+
+	writeFile(t, dir, "server.key", second.keyPEM)
+	if err := cert.Reload(certPath, keyPath); err != nil {
+		t.Fatalf("Reload error - {%v};", err)
+	}
+	if name := serverName(); name != "second" {
+		t.Errorf("certificate after reload {got}:{want} {%s}:{second};", name)
+	}
+}
@@ -0,0 +1,87 @@
+// настройки TLS сервера
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
+)
+
+// сертификат, ключ или CA клиентов не загружаются
+var ErrTLSInvalid = errors.New("invalid tls files")
+
+// сертификат сервера, который перечитывается без перезапуска ('Reload')
+// новые соединения получают новый сертификат, открытые - работают со старым
+type Certificate struct {
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// сертификат и ключ (PEM) из 'certPath' и 'keyPath'
+func LoadCertificate(certPath, keyPath string) (*Certificate, error) {
+	c := &Certificate{}
+	if err := c.Reload(certPath, keyPath); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// новый сертификат из файлов, ошибка -> остается прежний
+func (c *Certificate) Reload(certPath, keyPath string) error {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTLSInvalid, err)
+	}
+
+	c.cert.Store(&cert)
+	return nil
+}
+
+// для 'tls.Config.GetCertificate'
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
+
+// TLS с сертификатом 'cert' и версией не ниже 'cfg.TLSMinVersion'
+// задан 'cfg.TLSClientCAPath' -> клиент обязан предъявить сертификат, подписанный одним из этих CA
+// 'cert' == nil -> nil, nil
+func NewTLSConfig(cfg *config.Config, cert *Certificate) (*tls.Config, error) {
+	if cert == nil {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: cert.GetCertificate,
+		MinVersion:     cfg.TLSMinVersion,
+	}
+
+	if cfg.TLSClientCAPath != "" {
+		pool, err := loadCertPool(cfg.TLSClientCAPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// сертификаты CA из PEM, ни одного сертификата -> 'ErrTLSInvalid'
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTLSInvalid, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: no certificates in %s", ErrTLSInvalid, path)
+	}
+
+	return pool, nil
+}
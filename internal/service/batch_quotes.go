@@ -0,0 +1,122 @@
+// логика пакетного создания и удаления цитат
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+// содержит 'CreateQuotes' -> создание пакета цитат, 'DeleteQuotes' -> удаление пакета по ID
+type BatchQuote interface {
+	CreateQuotes(ctx context.Context, items []BatchItem, atomic bool) (*BatchResponse, error)
+	DeleteQuotes(ctx context.Context, ids []uint, atomic bool) (*BatchResponse, error)
+}
+
+// создаем верные цитаты пакета одним 'db.Provider.NewQuotes', создатель - клиент из 'ctx'
+// 'atomic' - есть неверная цитата или отказ базы -> ничего не создается, возвращаем 'db.ErrDBBatchAborted'
+func (s *serviceQuote) CreateQuotes(
+	ctx context.Context,
+	items []BatchItem,
+	atomic bool) (*BatchResponse, error) {
+	response := &BatchResponse{Atomic: atomic, Results: make([]BatchResultResponse, len(items))}
+
+	// верные цитаты и их индексы в 'items'
+	quotes := make([]model.Quote, 0, len(items))
+	pending := make([]int, 0, len(items))
+	creator := auth.Subject(ctx)
+
+	for i, item := range items {
+		response.Results[i].Index = i
+		if item.Err != nil {
+			response.Results[i].fail(item.Err)
+			continue
+		}
+		item.Quote.CreatedBy = creator
+		quotes = append(quotes, item.Quote)
+		pending = append(pending, i)
+	}
+
+	if atomic && len(pending) != len(items) {
+		response.abort(pending)
+		return response.count(), db.ErrDBBatchAborted
+	}
+
+	results, err := s.DBProvider.NewQuotes(ctx, quotes, atomic)
+	if err != nil && !errors.Is(err, db.ErrDBBatchAborted) {
+		s.log.DebugContext(ctx, "service: CreateQuotes", "error", err)
+		return nil, err
+	}
+
+	response.apply(pending, results)
+
+	return response.count(), err
+}
+
+// удаляем пакет одним 'db.Provider.RemoveQuotes'
+// 'atomic' - есть отсутствующий ID -> ничего не удаляется, возвращаем 'db.ErrDBBatchAborted'
+func (s *serviceQuote) DeleteQuotes(ctx context.Context, ids []uint, atomic bool) (*BatchResponse, error) {
+	results, err := s.DBProvider.RemoveQuotes(ctx, ids, atomic)
+	if err != nil && !errors.Is(err, db.ErrDBBatchAborted) {
+		s.log.DebugContext(ctx, "service: DeleteQuotes", "error", err)
+		return nil, err
+	}
+
+	response := &BatchResponse{Atomic: atomic, Results: make([]BatchResultResponse, len(ids))}
+	pending := make([]int, len(ids))
+	for i := range ids {
+		response.Results[i].Index = i
+		response.Results[i].ID = strconv.FormatUint(uint64(ids[i]), 10)
+		pending[i] = i
+	}
+
+	response.apply(pending, results)
+
+	return response.count(), err
+}
+
+// результаты базы для элементов с индексами 'pending'
+// элемент без отказа и без ID - пакет "все или ничего" отменен
+func (br *BatchResponse) apply(pending []int, results []db.BatchResult) {
+	for i, res := range results {
+		result := &br.Results[pending[i]]
+		switch {
+		case res.Err != nil:
+			result.fail(res.Err)
+		case res.ID == 0:
+			result.fail(db.ErrDBBatchAborted)
+		default:
+			result.ID = strconv.FormatUint(uint64(res.ID), 10)
+		}
+	}
+}
+
+// верные элементы 'pending' не применялись
+func (br *BatchResponse) abort(pending []int) {
+	for _, i := range pending {
+		br.Results[i].fail(db.ErrDBBatchAborted)
+	}
+}
+
+// количество примененных элементов и отказов
+func (br *BatchResponse) count() *BatchResponse {
+	br.Succeeded, br.Failed = 0, 0
+	for _, result := range br.Results {
+		if result.Err == nil {
+			br.Succeeded++
+		} else {
+			br.Failed++
+		}
+	}
+	return br
+}
+
+// отказ элемента
+func (r *BatchResultResponse) fail(err error) {
+	r.Err = err
+	r.Error = err.Error()
+}
@@ -4,27 +4,30 @@ package service
 // логика создания новой цитаты
 import (
 	"context"
-	"log"
-	"strings"
 
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
 )
 
 // содержит метод 'CreateQuote' -> создания цитаты
 type AddQuote interface {
-	CreateQuote(ctx context.Context, quote model.Quote) error
+	CreateQuote(ctx context.Context, quote model.Quote) (*QuoteResponse, error)
 }
 
-// перед сохранением, переводим все данные 'quote' в нижний регистр
-func (s *serviceQuote) CreateQuote(ctx context.Context, quote model.Quote) error {
-	// предотвращаем повторение данных при разных регистрах
-	quote.Author = strings.ToLower(quote.Author)
-	quote.Body = strings.ToLower(quote.Body)
+// сохраняем 'quote' в том виде, в каком она пришла
+// дубликаты при разных регистрах и оформлении отсекает база ('utils.NormalizeKey')
+// создатель цитаты - клиент из 'ctx' ('auth.Subject')
+// сериализуем сохраненную цитату (с ID) для ответа
+func (s *serviceQuote) CreateQuote(ctx context.Context, quote model.Quote) (*QuoteResponse, error) {
+	quote.CreatedBy = auth.Subject(ctx)
 
-	if err := s.DBProvider.NewQuote(ctx, quote); err != nil {
-		log.Printf("service: CreateQuote error - {%v};", err)
-		return err
+	created, err := s.DBProvider.NewQuote(ctx, quote)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: CreateQuote", "error", err)
+		return nil, err
 	}
 
-	return nil
+	serialize := QuoteSerializer{Quote: *created}
+
+	return serialize.Response(), nil
 }
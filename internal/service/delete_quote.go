@@ -3,18 +3,17 @@ package service
 
 import (
 	"context"
-	"log"
 )
 
 // содержит метод 'DeleteQuote' -> удаление цитаты
 type RemoveQuote interface {
-	DeleteQuote(ctx context.Context, id uint) error
+	DeleteQuote(ctx context.Context, id uint, revision uint64) error
 }
 
-// удаляем по ID
-func (s *serviceQuote) DeleteQuote(ctx context.Context, id uint) error {
-	if err := s.DBProvider.RemoveQuote(ctx, id); err != nil {
-		log.Printf("service: DeleteQuote error - {%v};", err)
+// удаляем по ID, 'revision' != 0 -> только цитату с этой ревизией
+func (s *serviceQuote) DeleteQuote(ctx context.Context, id uint, revision uint64) error {
+	if err := s.DBProvider.RemoveQuote(ctx, id, revision); err != nil {
+		s.log.DebugContext(ctx, "service: DeleteQuote", "error", err)
 		return err
 	}
 
@@ -2,18 +2,45 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"golang.org/x/text/language"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
-	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// самый ранний допустимый год цитаты (до н.э. - отрицательный)
+const MinQuoteYear = -3000
+
+const (
+	// наибольшее количество тегов у цитаты
+	MaxQuoteTags = 20
+
+	// наибольшая длина тега в символах
+	MaxTagLength = 50
 )
 
 // поля для unmarshal 'json'
+// 'author' и 'quote' - обязательные, остальные - нет
 // 'model' - для создания 'model.Quote' из полученных данных
 type QuoteDeserializer struct {
-	Author string `json:"author"`
-	Body   string `json:"quote"`
+	Author    string   `json:"author"`
+	Body      string   `json:"quote"`
+	Source    string   `json:"source"`
+	Year      *int     `json:"year"`
+	SourceURL string   `json:"source_url"`
+	Language  string   `json:"language"`
+	Tags      []string `json:"tags"`
 
 	model model.Quote `json:"-"`
 }
@@ -28,23 +55,596 @@ func (q *QuoteDeserializer) Model() model.Quote {
 	return q.model
 }
 
-// вызывает 'utils.DecodeJSON'
+// вызывает 'decodeJSON'
 // удаляем пробелы, передаем данные а 'model'
 func (q *QuoteDeserializer) Decode(req *http.Request) error {
-	if err := utils.DecodeJSON(req, q); err != nil {
+	if err := decodeJSON(req, q); err != nil {
 		return err
 	}
 
+	return q.validate()
+}
+
+// проверка полученных полей и заполнение 'model'
+// общая часть для 'Decode' и импорта
+// проверяем все поля -> 'ValidationError' со всеми ошибками
+func (q *QuoteDeserializer) validate() error {
+	invalid := &ValidationError{}
+
 	if q.Author = strings.TrimSpace(q.Author); q.Author == "" {
-		return ErrServiceInvalidData
+		invalid.add("author", fieldRequired)
 	}
 
 	if q.Body = strings.TrimSpace(q.Body); q.Body == "" {
-		return ErrServiceInvalidData
+		invalid.add("quote", fieldRequired)
 	}
 
 	q.model.Author = q.Author
 	q.model.Body = q.Body
+	q.model.Source = strings.TrimSpace(q.Source)
+
+	if q.Year != nil {
+		if !validYear(*q.Year) {
+			invalid.add("year", fieldYear)
+		}
+		q.model.Year = *q.Year
+	}
+
+	sourceURL, err := parseSourceURL(q.SourceURL)
+	if err != nil {
+		invalid.add("source_url", fieldSourceURL)
+	}
+	q.model.SourceURL = sourceURL
+
+	lang, err := parseLanguage(q.Language)
+	if err != nil {
+		invalid.add("language", fieldLanguage)
+	}
+	q.model.Language = lang
+
+	tags, err := parseTags(q.Tags)
+	if err != nil {
+		invalid.add("tags", fieldTags)
+	}
+	q.model.Tags = tags
+
+	return invalid.result()
+}
+
+// полная замена цитаты (PUT) - 'author' и 'quote' обязательны,
+// не переданные необязательные поля очищаются
+func (q *QuoteDeserializer) Patch() db.QuotePatch {
+	return db.QuotePatch{
+		Author:    &q.model.Author,
+		Body:      &q.model.Body,
+		Source:    &q.model.Source,
+		Year:      &q.model.Year,
+		SourceURL: &q.model.SourceURL,
+		Language:  &q.model.Language,
+		Tags:      &q.model.Tags,
+	}
+}
+
+// поля для частичного изменения цитаты (PATCH)
+// отсутствующее поле -> nil, не изменяется
+// пустая строка в необязательном поле -> поле очищается
+type QuotePatchDeserializer struct {
+	Author    *string   `json:"author"`
+	Body      *string   `json:"quote"`
+	Source    *string   `json:"source"`
+	Year      *int      `json:"year"`
+	SourceURL *string   `json:"source_url"`
+	Language  *string   `json:"language"`
+	Tags      *[]string `json:"tags"`
+
+	patch db.QuotePatch `json:"-"`
+}
+
+// конструктор для QuotePatchDeserializer
+func NewQuotePatchDeserializer() *QuotePatchDeserializer {
+	return &QuotePatchDeserializer{}
+}
+
+// получение 'db.QuotePatch' после 'Decode'
+func (q *QuotePatchDeserializer) Patch() db.QuotePatch {
+	return q.patch
+}
+
+// вызывает 'decodeJSON'
+// хотя бы одно поле должно быть передано, переданные поля не пустые
+// проверяем все поля -> 'ValidationError' со всеми ошибками
+func (q *QuotePatchDeserializer) Decode(req *http.Request) error {
+	if err := decodeJSON(req, q); err != nil {
+		return err
+	}
+
+	if q.Author == nil && q.Body == nil && q.Source == nil &&
+		q.Year == nil && q.SourceURL == nil && q.Language == nil && q.Tags == nil {
+		return fmt.Errorf("%w: at least one field is required", ErrServiceInvalidData)
+	}
+
+	invalid := &ValidationError{}
+
+	if q.Author != nil {
+		author := strings.TrimSpace(*q.Author)
+		if author == "" {
+			invalid.add("author", fieldRequired)
+		}
+		q.patch.Author = &author
+	}
+
+	if q.Body != nil {
+		body := strings.TrimSpace(*q.Body)
+		if body == "" {
+			invalid.add("quote", fieldRequired)
+		}
+		q.patch.Body = &body
+	}
+
+	if q.Source != nil {
+		source := strings.TrimSpace(*q.Source)
+		q.patch.Source = &source
+	}
+
+	// 0 -> год очищается
+	if q.Year != nil {
+		if *q.Year != 0 && !validYear(*q.Year) {
+			invalid.add("year", fieldYear)
+		}
+		q.patch.Year = q.Year
+	}
+
+	if q.SourceURL != nil {
+		sourceURL, err := parseSourceURL(*q.SourceURL)
+		if err != nil {
+			invalid.add("source_url", fieldSourceURL)
+		}
+		q.patch.SourceURL = &sourceURL
+	}
+
+	if q.Language != nil {
+		lang, err := parseLanguage(*q.Language)
+		if err != nil {
+			invalid.add("language", fieldLanguage)
+		}
+		q.patch.Language = &lang
+	}
+
+	// пустой список -> теги очищаются
+	if q.Tags != nil {
+		tags, err := parseTags(*q.Tags)
+		if err != nil {
+			invalid.add("tags", fieldTags)
+		}
+		q.patch.Tags = &tags
+	}
+
+	return invalid.result()
+}
+
+// поля для добавления тегов к цитате, список не пустой
+type TagsDeserializer struct {
+	Tags []string `json:"tags"`
+}
+
+// конструктор для TagsDeserializer
+func NewTagsDeserializer() *TagsDeserializer {
+	return &TagsDeserializer{}
+}
+
+// вызывает 'decodeJSON', проверяем теги
+func (td *TagsDeserializer) Decode(req *http.Request) error {
+	if err := decodeJSON(req, td); err != nil {
+		return err
+	}
+
+	invalid := &ValidationError{}
+
+	if len(td.Tags) == 0 {
+		invalid.add("tags", fieldRequired)
+		return invalid
+	}
+
+	tags, err := parseTags(td.Tags)
+	if err != nil {
+		invalid.add("tags", fieldTags)
+		return invalid
+	}
+	td.Tags = tags
+
+	return nil
+}
+
+// год не 0 и в диапазоне от 'MinQuoteYear' до текущего
+func validYear(year int) bool {
+	return year != 0 && year >= MinQuoteYear && year <= time.Now().Year()
+}
+
+// ссылка на источник - абсолютный http(s) url, пустая строка допустима
+func parseSourceURL(raw string) (string, error) {
+	if raw = strings.TrimSpace(raw); raw == "" {
+		return "", nil
+	}
+
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", ErrServiceInvalidData
+	}
+
+	return u.String(), nil
+}
+
+// теги без пробелов по краям, не пустые, не длиннее 'MaxTagLength',
+// не больше 'MaxQuoteTags', регистр и повторы убирает база
+func parseTags(raw []string) ([]string, error) {
+	if len(raw) > MaxQuoteTags {
+		return nil, ErrServiceInvalidData
+	}
+
+	tags := make([]string, 0, len(raw))
+	for _, tag := range raw {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, ErrServiceInvalidData
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// тег языка BCP-47 в канонической форме ("EN-us" -> "en-US"), пустая строка допустима
+func parseLanguage(raw string) (string, error) {
+	if raw = strings.TrimSpace(raw); raw == "" {
+		return "", nil
+	}
+
+	tag, err := language.Parse(raw)
+	if err != nil {
+		return "", ErrServiceInvalidData
+	}
+
+	return tag.String(), nil
+}
+
+const (
+	// размер страницы, если 'limit' не передан
+	DefaultPageLimit = 50
+
+	// наибольший размер страницы
+	MaxPageLimit = 1000
+)
+
+// параметры страницы из url: 'limit', 'after_id', 'order=asc|desc'
+// и фильтр: 'source', 'language', 'year', 'year_from', 'year_to',
+// 'tag' (можно несколько) и 'tag_mode=all|any'
+type PageDeserializer struct {
+	query db.PageQuery
+}
+
+// конструктор для PageDeserializer
+func NewPageDeserializer() *PageDeserializer {
+	return &PageDeserializer{query: db.PageQuery{Limit: DefaultPageLimit}}
+}
+
+// получение 'db.PageQuery' после 'Decode'
+func (pd *PageDeserializer) Query() db.PageQuery {
+	return pd.query
+}
+
+// разбираем параметры url, отсутствующий параметр -> значение по умолчанию
+func (pd *PageDeserializer) Decode(req *http.Request) error {
+	param := req.URL.Query()
+
+	if limitStr := param.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > MaxPageLimit {
+			return ErrServiceInvalidData
+		}
+		pd.query.Limit = limit
+	}
+
+	if afterStr := param.Get("after_id"); afterStr != "" {
+		afterID, err := strconv.ParseUint(afterStr, 10, 64)
+		if err != nil {
+			return ErrServiceInvalidData
+		}
+		pd.query.AfterID = uint(afterID)
+	}
+
+	switch param.Get("order") {
+	case "", "asc":
+		pd.query.Desc = false
+	case "desc":
+		pd.query.Desc = true
+	default:
+		return ErrServiceInvalidData
+	}
+
+	return pd.decodeFilter(param)
+}
+
+// фильтр по метаданным, 'year' - один год, 'year_from' / 'year_to' - диапазон
+func (pd *PageDeserializer) decodeFilter(param url.Values) error {
+	filter := &pd.query.Filter
+
+	filter.Source = strings.TrimSpace(param.Get("source"))
+
+	lang, err := parseLanguage(param.Get("language"))
+	if err != nil {
+		return err
+	}
+	filter.Language = lang
+
+	for key, bound := range map[string]*int{"year_from": &filter.YearFrom, "year_to": &filter.YearTo} {
+		if yearStr := param.Get(key); yearStr != "" {
+			year, err := strconv.Atoi(yearStr)
+			if err != nil || year == 0 {
+				return ErrServiceInvalidData
+			}
+			*bound = year
+		}
+	}
+
+	if yearStr := param.Get("year"); yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil || year == 0 || filter.YearFrom != 0 || filter.YearTo != 0 {
+			return ErrServiceInvalidData
+		}
+		filter.YearFrom, filter.YearTo = year, year
+	}
+
+	if filter.YearFrom != 0 && filter.YearTo != 0 && filter.YearFrom > filter.YearTo {
+		return ErrServiceInvalidData
+	}
+
+	return pd.decodeTags(param)
+}
+
+// фильтр по тегам, 'tag_mode=all' (по умолчанию) - все теги, 'any' - хотя бы один
+func (pd *PageDeserializer) decodeTags(param url.Values) error {
+	filter := &pd.query.Filter
+
+	if _, ex := param["tag"]; ex {
+		tags, err := parseTags(param["tag"])
+		if err != nil {
+			return err
+		}
+		filter.Tags = tags
+	}
+
+	switch param.Get("tag_mode") {
+	case "", "all":
+		filter.AnyTag = false
+	case "any":
+		filter.AnyTag = true
+	default:
+		return ErrServiceInvalidData
+	}
+
+	return nil
+}
+
+// параметры страницы авторов из url: 'limit', 'after', 'prefix'
+type AuthorPageDeserializer struct {
+	query db.AuthorQuery
+}
+
+// конструктор для AuthorPageDeserializer
+func NewAuthorPageDeserializer() *AuthorPageDeserializer {
+	return &AuthorPageDeserializer{query: db.AuthorQuery{Limit: DefaultPageLimit}}
+}
+
+// получение 'db.AuthorQuery' после 'Decode'
+func (ad *AuthorPageDeserializer) Query() db.AuthorQuery {
+	return ad.query
+}
+
+// разбираем параметры url, отсутствующий параметр -> значение по умолчанию
+func (ad *AuthorPageDeserializer) Decode(req *http.Request) error {
+	param := req.URL.Query()
+
+	if limitStr := param.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > MaxPageLimit {
+			return ErrServiceInvalidData
+		}
+		ad.query.Limit = limit
+	}
+
+	// курсор - 'next_cursor' предыдущей страницы
+	ad.query.After = param.Get("after")
+	ad.query.Prefix = strings.TrimSpace(param.Get("prefix"))
+
+	return nil
+}
+
+// формат импорта и экспорта цитат
+type BulkFormat string
+
+const (
+	// JSON Lines - одна цитата на строку
+	FormatJSONL BulkFormat = "jsonl"
+
+	// CSV с заголовком, теги через 'CSVTagSeparator'
+	FormatCSV BulkFormat = "csv"
+
+	// массив JSON
+	FormatJSON BulkFormat = "json"
+)
+
+// "Content-Type" для формата
+func (f BulkFormat) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=UTF-8"
+	case FormatJSON:
+		return "application/json; charset=UTF-8"
+	default:
+		return "application/x-ndjson; charset=UTF-8"
+	}
+}
+
+// параметры импорта и экспорта из url:
+// 'format=jsonl|csv|json' (нет -> по "Content-Type", иначе jsonl),
+// 'atomic=true|false' - импорт "все или ничего" (по умолчанию false)
+type BulkDeserializer struct {
+	Format BulkFormat
+	Atomic bool
+}
+
+// конструктор для BulkDeserializer
+func NewBulkDeserializer() *BulkDeserializer {
+	return &BulkDeserializer{Format: FormatJSONL}
+}
+
+// разбираем параметры url, отсутствующий параметр -> значение по умолчанию
+func (bd *BulkDeserializer) Decode(req *http.Request) error {
+	param := req.URL.Query()
+
+	switch format := BulkFormat(param.Get("format")); format {
+	case FormatJSONL, FormatCSV, FormatJSON:
+		bd.Format = format
+	case "":
+		media, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		switch media {
+		case "text/csv":
+			bd.Format = FormatCSV
+		case "application/json":
+			bd.Format = FormatJSON
+		}
+	default:
+		return ErrServiceInvalidData
+	}
+
+	atomic, err := parseAtomic(param)
+	if err != nil {
+		return err
+	}
+	bd.Atomic = atomic
+
+	return nil
+}
+
+// параметр url 'atomic=true|false', нет -> false
+func parseAtomic(param url.Values) (bool, error) {
+	atomicStr := param.Get("atomic")
+	if atomicStr == "" {
+		return false, nil
+	}
+
+	atomic, err := strconv.ParseBool(atomicStr)
+	if err != nil {
+		return false, ErrServiceInvalidData
+	}
+
+	return atomic, nil
+}
+
+// наибольшее количество элементов в пакетном запросе
+const MaxBatchSize = 1000
+
+// элемент пакета на создание: цитата после проверки или ошибка проверки
+type BatchItem struct {
+	Quote model.Quote
+	Err   error
+}
+
+// тело 'POST /quotes/batch': {"quotes":[...]}, каждая цитата - как в 'POST /quotes'
+// неверная цитата не прерывает разбор, параметр url 'atomic'
+type BatchDeserializer struct {
+	Quotes []json.RawMessage `json:"quotes"`
+
+	Atomic bool        `json:"-"`
+	items  []BatchItem `json:"-"`
+}
+
+// конструктор для BatchDeserializer
+func NewBatchDeserializer() *BatchDeserializer {
+	return &BatchDeserializer{}
+}
+
+// получение элементов пакета после 'Decode'
+func (bd *BatchDeserializer) Items() []BatchItem {
+	return bd.items
+}
+
+// вызывает 'decodeJSON', проверяем каждую цитату
+// пустой пакет или больше 'MaxBatchSize' -> ошибка всего запроса
+func (bd *BatchDeserializer) Decode(req *http.Request) error {
+	atomic, err := parseAtomic(req.URL.Query())
+	if err != nil {
+		return err
+	}
+	bd.Atomic = atomic
+
+	if err := decodeJSON(req, bd); err != nil {
+		return err
+	}
+
+	if len(bd.Quotes) == 0 || len(bd.Quotes) > MaxBatchSize {
+		return ErrServiceInvalidData
+	}
+
+	bd.items = make([]BatchItem, 0, len(bd.Quotes))
+	for _, raw := range bd.Quotes {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+
+		quote := NewQuoteDeserializer()
+		if err := dec.Decode(quote); err != nil {
+			bd.items = append(bd.items, BatchItem{Err: jsonError(err)})
+			continue
+		}
+		if err := quote.validate(); err != nil {
+			bd.items = append(bd.items, BatchItem{Err: err})
+			continue
+		}
+		bd.items = append(bd.items, BatchItem{Quote: quote.Model()})
+	}
+
+	return nil
+}
+
+// параметры 'DELETE /quotes': 'ids' - ID через запятую, 'atomic'
+type IDsDeserializer struct {
+	IDs    []uint
+	Atomic bool
+}
+
+// конструктор для IDsDeserializer
+func NewIDsDeserializer() *IDsDeserializer {
+	return &IDsDeserializer{}
+}
+
+// ID - положительные числа, от 1 до 'MaxBatchSize' штук
+func (ds *IDsDeserializer) Decode(req *http.Request) error {
+	param := req.URL.Query()
+
+	atomic, err := parseAtomic(param)
+	if err != nil {
+		return err
+	}
+	ds.Atomic = atomic
+
+	idsStr := strings.TrimSpace(param.Get("ids"))
+	if idsStr == "" {
+		return ErrServiceInvalidData
+	}
+
+	parts := strings.Split(idsStr, ",")
+	if len(parts) > MaxBatchSize {
+		return ErrServiceInvalidData
+	}
+
+	ds.IDs = make([]uint, 0, len(parts))
+	for _, part := range parts {
+		value, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err != nil || value == 0 {
+			return ErrServiceInvalidData
+		}
+		ds.IDs = append(ds.IDs, uint(value))
+	}
 
 	return nil
 }
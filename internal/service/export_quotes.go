@@ -0,0 +1,156 @@
+// логика выгрузки всех цитат в JSON Lines, CSV и массив JSON
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+)
+
+// количество цитат, читаемых из базы за одну блокировку
+const ExportChunkSize = 500
+
+// колонки CSV экспорта, подходят для импорта
+var csvExportHeader = []string{
+	"id", "author", "quote", "source", "year", "source_url", "language", "tags", "created_at", "updated_at",
+}
+
+// пишем все цитаты в 'w' по возрастанию ID
+// цитаты читаются страницами 'db.Provider.QuotePage' -> блокировка базы не держится всю выгрузку,
+// цитаты, добавленные во время выгрузки, попадают в нее, если их ID больше последнего выгруженного
+func (s *serviceQuote) ExportQuotes(ctx context.Context, format BulkFormat, w io.Writer) error {
+	enc := newExportEncoder(format, w)
+
+	if err := enc.begin(); err != nil {
+		s.log.DebugContext(ctx, "service: ExportQuotes", "error", err)
+		return err
+	}
+
+	query := db.PageQuery{Limit: ExportChunkSize}
+	for {
+		page, err := s.DBProvider.QuotePage(ctx, query)
+		if errors.Is(err, db.ErrDBEmpty) {
+			break
+		}
+		if err != nil {
+			s.log.DebugContext(ctx, "service: ExportQuotes", "error", err)
+			return err
+		}
+
+		serialize := QuoteListSerializer{Quotes: page.Quotes}
+		for _, quote := range serialize.Response() {
+			if err := enc.encode(quote); err != nil {
+				s.log.DebugContext(ctx, "service: ExportQuotes", "error", err)
+				return err
+			}
+		}
+
+		if err := enc.flush(); err != nil {
+			s.log.DebugContext(ctx, "service: ExportQuotes", "error", err)
+			return err
+		}
+
+		if page.NextCursor == 0 {
+			break
+		}
+		query.AfterID = page.NextCursor
+	}
+
+	if err := enc.end(); err != nil {
+		s.log.DebugContext(ctx, "service: ExportQuotes", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// запись цитат в формате выгрузки
+type exportEncoder struct {
+	format BulkFormat
+	w      io.Writer
+	csv    *csv.Writer
+
+	// записано цитат, для разделителей массива JSON
+	count int
+}
+
+func newExportEncoder(format BulkFormat, w io.Writer) *exportEncoder {
+	enc := &exportEncoder{format: format, w: w}
+	if format == FormatCSV {
+		enc.csv = csv.NewWriter(w)
+	}
+	return enc
+}
+
+// заголовок CSV или начало массива JSON
+func (e *exportEncoder) begin() error {
+	switch e.format {
+	case FormatCSV:
+		return e.csv.Write(csvExportHeader)
+	case FormatJSON:
+		_, err := io.WriteString(e.w, "[")
+		return err
+	default:
+		return nil
+	}
+}
+
+func (e *exportEncoder) encode(quote QuoteResponse) error {
+	defer func() { e.count++ }()
+
+	switch e.format {
+	case FormatCSV:
+		year := ""
+		if quote.Year != 0 {
+			year = strconv.Itoa(quote.Year)
+		}
+		return e.csv.Write([]string{
+			quote.ID, quote.Author, quote.Body, quote.Source, year, quote.SourceURL, quote.Language,
+			strings.Join(quote.Tags, CSVTagSeparator), quote.CreatedAt, quote.UpdatedAt,
+		})
+	case FormatJSON:
+		data, err := json.Marshal(quote)
+		if err != nil {
+			return err
+		}
+		if e.count > 0 {
+			if _, err := io.WriteString(e.w, ","); err != nil {
+				return err
+			}
+		}
+		_, err = e.w.Write(data)
+		return err
+	default:
+		return json.NewEncoder(e.w).Encode(quote)
+	}
+}
+
+// отправляем записанное клиенту после каждой страницы
+func (e *exportEncoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := e.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// конец массива JSON
+func (e *exportEncoder) end() error {
+	if e.format == FormatJSON {
+		if _, err := io.WriteString(e.w, "]\n"); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
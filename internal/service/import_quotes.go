@@ -0,0 +1,342 @@
+// логика импорта цитат из JSON Lines, CSV и массива JSON
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
+)
+
+const (
+	// наибольшее количество записей в одном импорте
+	MaxImportQuotes = 10000
+
+	// размер пакета для 'db.Provider.NewQuotes' в режиме best-effort
+	ImportChunkSize = 500
+
+	// наибольшая длина строки JSON Lines в байтах
+	MaxImportLine = 1 << 20
+
+	// разделитель тегов в колонке 'tags' CSV
+	CSVTagSeparator = "|"
+)
+
+// статусы записи импорта
+const (
+	ImportCreated   = "created"
+	ImportDuplicate = "duplicate"
+	ImportInvalid   = "invalid"
+
+	// запись верная, но импорт "все или ничего" отменен из-за других записей
+	ImportSkipped = "skipped"
+)
+
+// содержит 'ImportQuotes' -> загрузка цитат, 'ExportQuotes' -> выгрузка всех цитат
+type BulkQuote interface {
+	ImportQuotes(ctx context.Context, format BulkFormat, body io.Reader, atomic bool) (*ImportResponse, error)
+	ExportQuotes(ctx context.Context, format BulkFormat, w io.Writer) error
+}
+
+// запись импорта - поля 'QuoteDeserializer' и поля из экспорта,
+// 'id', 'created_by', 'created_at', 'updated_at' не используются - их задают база и клиент импорта
+type importRecord struct {
+	QuoteDeserializer
+	ID        json.RawMessage `json:"id"`
+	CreatedBy json.RawMessage `json:"created_by"`
+	CreatedAt json.RawMessage `json:"created_at"`
+	UpdatedAt json.RawMessage `json:"updated_at"`
+}
+
+// прочитанная запись: номер строки (для массива JSON - номер элемента),
+// цитата после проверки или ошибка проверки
+type importItem struct {
+	line  int
+	quote model.Quote
+	err   error
+}
+
+// читаем и проверяем все записи, затем пишем в базу, создатель - клиент из 'ctx'
+// ошибка формата всего файла -> в базу ничего не пишется, возвращаем 'ErrServiceInvalidData'
+// 'atomic' - есть неверная запись или дубликат -> ничего не добавляется, возвращаем 'db.ErrDBBatchAborted'
+// иначе верные записи добавляются пакетами по 'ImportChunkSize'
+func (s *serviceQuote) ImportQuotes(
+	ctx context.Context,
+	format BulkFormat,
+	body io.Reader,
+	atomic bool) (*ImportResponse, error) {
+	items, err := readImport(format, body)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: ImportQuotes", "error", err)
+		return nil, err
+	}
+
+	report := &ImportResponse{Atomic: atomic, Results: make([]ImportResultResponse, 0, len(items))}
+
+	// верные записи и их индексы в 'report.Results'
+	quotes := make([]model.Quote, 0, len(items))
+	pending := make([]int, 0, len(items))
+	creator := auth.Subject(ctx)
+
+	for _, item := range items {
+		result := ImportResultResponse{Line: item.line}
+		if item.err != nil {
+			result.Status = ImportInvalid
+			result.Error = item.err.Error()
+			report.Invalid++
+		} else {
+			item.quote.CreatedBy = creator
+			quotes = append(quotes, item.quote)
+			pending = append(pending, len(report.Results))
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if atomic && report.Invalid > 0 {
+		report.skip(pending)
+		return report, db.ErrDBBatchAborted
+	}
+
+	chunk := ImportChunkSize
+	if atomic {
+		chunk = len(quotes)
+	}
+
+	for start := 0; start < len(quotes); start += chunk {
+		end := min(start+chunk, len(quotes))
+
+		results, err := s.DBProvider.NewQuotes(ctx, quotes[start:end], atomic)
+		if err != nil && !errors.Is(err, db.ErrDBBatchAborted) {
+			s.log.DebugContext(ctx, "service: ImportQuotes", "error", err)
+			return nil, err
+		}
+
+		report.apply(pending[start:end], results)
+
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// разбор тела импорта по формату
+func readImport(format BulkFormat, body io.Reader) ([]importItem, error) {
+	switch format {
+	case FormatCSV:
+		return readImportCSV(body)
+	case FormatJSON:
+		return readImportJSON(body)
+	default:
+		return readImportJSONL(body)
+	}
+}
+
+// одна цитата на строку, пустые строки пропускаются
+// неверная строка не прерывает импорт
+func readImportJSONL(body io.Reader) ([]importItem, error) {
+	var items []importItem
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxImportLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		if len(items) == MaxImportQuotes {
+			return nil, fmt.Errorf("%w: more than %d quotes", ErrServiceInvalidData, MaxImportQuotes)
+		}
+
+		dec := json.NewDecoder(strings.NewReader(scanner.Text()))
+		dec.DisallowUnknownFields()
+
+		var record importRecord
+		if err := dec.Decode(&record); err != nil {
+			items = append(items, importItem{line: line, err: jsonError(err)})
+			continue
+		}
+		items = append(items, newImportItem(line, &record.QuoteDeserializer))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrServiceInvalidData, err)
+	}
+
+	return items, nil
+}
+
+// массив JSON, элемент неверного вида не прерывает импорт,
+// нарушение синтаксиса - ошибка всего файла
+func readImportJSON(body io.Reader) ([]importItem, error) {
+	var items []importItem
+
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("%w: expected json array", ErrServiceInvalidData)
+	}
+
+	for line := 1; dec.More(); line++ {
+		if len(items) == MaxImportQuotes {
+			return nil, fmt.Errorf("%w: more than %d quotes", ErrServiceInvalidData, MaxImportQuotes)
+		}
+
+		var record importRecord
+		if err := dec.Decode(&record); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("%w: %w", ErrServiceInvalidData, err)
+			}
+			items = append(items, importItem{line: line, err: jsonError(err)})
+			continue
+		}
+		items = append(items, newImportItem(line, &record.QuoteDeserializer))
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrServiceInvalidData, err)
+	}
+
+	return items, nil
+}
+
+// CSV с заголовком, колонки 'author' и 'quote' обязательные
+// 'id', 'created_at', 'updated_at' не используются, неизвестная колонка - ошибка всего файла
+func readImportCSV(body io.Reader) ([]importItem, error) {
+	var items []importItem
+
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: csv header - %w", ErrServiceInvalidData, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "author", "quote", "source", "year", "source_url", "language", "tags",
+			"id", "created_at", "updated_at":
+			columns[name] = i
+		default:
+			return nil, fmt.Errorf("%w: unknown csv column - %q", ErrServiceInvalidData, name)
+		}
+	}
+	if _, ex := columns["author"]; !ex {
+		return nil, fmt.Errorf("%w: csv column author is required", ErrServiceInvalidData)
+	}
+	if _, ex := columns["quote"]; !ex {
+		return nil, fmt.Errorf("%w: csv column quote is required", ErrServiceInvalidData)
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(items) == MaxImportQuotes {
+			return nil, fmt.Errorf("%w: more than %d quotes", ErrServiceInvalidData, MaxImportQuotes)
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			items = append(items, importItem{line: parseErr.StartLine, err: err})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrServiceInvalidData, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		record, err := csvRecord(columns, row)
+		if err != nil {
+			items = append(items, importItem{line: line, err: err})
+			continue
+		}
+		items = append(items, newImportItem(line, record))
+	}
+
+	return items, nil
+}
+
+// строка CSV -> 'QuoteDeserializer', пустые колонки не заполняются
+func csvRecord(columns map[string]int, row []string) (*QuoteDeserializer, error) {
+	field := func(name string) string {
+		if i, ex := columns[name]; ex {
+			return row[i]
+		}
+		return ""
+	}
+
+	record := &QuoteDeserializer{
+		Author:    field("author"),
+		Body:      field("quote"),
+		Source:    field("source"),
+		SourceURL: field("source_url"),
+		Language:  field("language"),
+	}
+
+	if yearStr := strings.TrimSpace(field("year")); yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil {
+			return nil, ErrServiceInvalidData
+		}
+		record.Year = &year
+	}
+
+	if tags := strings.TrimSpace(field("tags")); tags != "" {
+		record.Tags = strings.Split(tags, CSVTagSeparator)
+	}
+
+	return record, nil
+}
+
+// проверка записи теми же правилами, что и для POST /quotes
+func newImportItem(line int, record *QuoteDeserializer) importItem {
+	if err := record.validate(); err != nil {
+		return importItem{line: line, err: err}
+	}
+	return importItem{line: line, quote: record.Model()}
+}
+
+// результаты 'db.Provider.NewQuotes' для записей с индексами 'pending'
+// элемент без отказа и без ID - пакет "все или ничего" отменен
+func (ir *ImportResponse) apply(pending []int, results []db.BatchResult) {
+	for i, res := range results {
+		result := &ir.Results[pending[i]]
+		switch {
+		case errors.Is(res.Err, db.ErrDBAlreadyExists):
+			result.Status = ImportDuplicate
+			result.Error = res.Err.Error()
+			ir.Duplicate++
+		case res.Err != nil || res.ID == 0:
+			result.Status = ImportSkipped
+		default:
+			result.Status = ImportCreated
+			result.ID = strconv.FormatUint(uint64(res.ID), 10)
+			ir.Created++
+		}
+	}
+}
+
+// верные записи 'pending' не добавлялись
+func (ir *ImportResponse) skip(pending []int) {
+	for _, i := range pending {
+		ir.Results[i].Status = ImportSkipped
+	}
+}
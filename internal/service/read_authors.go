@@ -0,0 +1,41 @@
+// логика получения авторов и их цитат
+package service
+
+import (
+	"context"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+)
+
+// содержит 'ReadAuthorList' -> return страницу авторов,
+// 'ReadRandomQuoteByAuthor' -> return случайную цитату автора
+type FindAuthor interface {
+	ReadAuthorList(ctx context.Context, query db.AuthorQuery) (*AuthorPageResponse, error)
+	ReadRandomQuoteByAuthor(ctx context.Context, author string) (*QuoteResponse, error)
+}
+
+// получение страницы авторов из базы, и дальнейшая сериализация для ответа
+func (s *serviceQuote) ReadAuthorList(ctx context.Context, query db.AuthorQuery) (*AuthorPageResponse, error) {
+	page, err := s.DBProvider.AuthorPage(ctx, query)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: ReadAuthorList", "error", err)
+		return nil, err
+	}
+
+	serialize := AuthorPageSerializer{AuthorPage: *page}
+
+	return serialize.Response(), nil
+}
+
+// идем в базу за случайной цитатой автора, сериализуем цитату в ответ
+func (s *serviceQuote) ReadRandomQuoteByAuthor(ctx context.Context, author string) (*QuoteResponse, error) {
+	quote, err := s.DBProvider.RandomQuoteByAuthor(ctx, author)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: ReadRandomQuoteByAuthor", "error", err)
+		return nil, err
+	}
+
+	serialize := QuoteSerializer{Quote: *quote}
+
+	return serialize.Response(), nil
+}
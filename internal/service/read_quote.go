@@ -0,0 +1,24 @@
+// логика получения цитаты по ID
+package service
+
+import (
+	"context"
+)
+
+// содержит 'ReadQuote' -> return цитату по ID
+type FindQuote interface {
+	ReadQuote(ctx context.Context, id uint) (*QuoteResponse, error)
+}
+
+// идем в базу, при нахождении сериализуем цитату в ответ
+func (s *serviceQuote) ReadQuote(ctx context.Context, id uint) (*QuoteResponse, error) {
+	quote, err := s.DBProvider.QuoteByID(ctx, id)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: ReadQuote", "error", err)
+		return nil, err
+	}
+
+	serialize := QuoteSerializer{Quote: *quote}
+
+	return serialize.Response(), nil
+}
@@ -3,23 +3,24 @@ package service
 
 import (
 	"context"
-	"log"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
 )
 
-// содержит 'ReadQuoteList' -> return список цитат
+// содержит 'ReadQuoteList' -> return страницу списка цитат
 type FindListQuote interface {
-	ReadQuoteList(ctx context.Context) ([]QuoteResponse, error)
+	ReadQuoteList(ctx context.Context, query db.PageQuery) (*QuotePageResponse, error)
 }
 
-// получение цитат из базы, и дальнейшая сериализация для ответа
-func (s *serviceQuote) ReadQuoteList(ctx context.Context) ([]QuoteResponse, error) {
-	quotes, err := s.DBProvider.QuoteList(ctx)
+// получение страницы цитат из базы, и дальнейшая сериализация для ответа
+func (s *serviceQuote) ReadQuoteList(ctx context.Context, query db.PageQuery) (*QuotePageResponse, error) {
+	page, err := s.DBProvider.QuotePage(ctx, query)
 	if err != nil {
-		log.Printf("service: ReadQuoteList error - {%v};", err)
+		s.log.DebugContext(ctx, "service: ReadQuoteList", "error", err)
 		return nil, err
 	}
 
-	serialize := QuoteListSerializer{Quotes: quotes}
+	serialize := QuotePageSerializer{Page: *page}
 
 	return serialize.Response(), nil
 }
@@ -3,30 +3,30 @@ package service
 
 import (
 	"context"
-	"log"
-	"strings"
+
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
 )
 
 // содержит 'FindListQuoteByAuthor' -> -> return список цитат по переданному 'author'
 type FindListQuoteByAuthor interface {
-	ReadQuoteListByAuthor(ctx context.Context, author string) ([]QuoteResponse, error)
+	ReadQuoteListByAuthor(ctx context.Context, author string) (*AuthorQuotesResponse, error)
 }
 
-// перевод 'author' в нижний регистр
+// приводим 'author' к ключу индекса авторов в базе
 // получение цитат из базы по автору, и дальнейшая сериализация для ответа
 func (s *serviceQuote) ReadQuoteListByAuthor(
 	ctx context.Context,
-	author string) ([]QuoteResponse, error) {
-	// все данные в базе - в нижнем регистре
-	author = strings.ToLower(author)
+	author string) (*AuthorQuotesResponse, error) {
+	// тот же нормализатор, что и для индекса авторов
+	author = utils.NormalizeKey(author)
 
-	quotes, err := s.DBProvider.QuoteListByAuthor(ctx, author)
+	quotes, revision, err := s.DBProvider.QuoteListByAuthor(ctx, author)
 	if err != nil {
-		log.Printf("{service: ReadQuoteListByAuthor error - {%v};", err)
+		s.log.DebugContext(ctx, "service: ReadQuoteListByAuthor", "error", err)
 		return nil, err
 	}
 
 	serialize := QuoteListSerializer{Quotes: quotes}
 
-	return serialize.Response(), nil
+	return &AuthorQuotesResponse{Quotes: serialize.Response(), Revision: revision}, nil
 }
@@ -3,7 +3,6 @@ package service
 
 import (
 	"context"
-	"log"
 )
 
 // содержит 'ReadRandomQuote' -> -> return цитату
@@ -15,7 +14,7 @@ type FindRandomQuote interface {
 func (s *serviceQuote) ReadRandomQuote(ctx context.Context) (*QuoteResponse, error) {
 	quote, err := s.DBProvider.RandomQuote(ctx)
 	if err != nil {
-		log.Printf("service: ReadRandomQuote error - {%v};", err)
+		s.log.DebugContext(ctx, "service: ReadRandomQuote", "error", err)
 		return nil, err
 	}
 
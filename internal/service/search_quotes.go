@@ -0,0 +1,30 @@
+// логика полнотекстового поиска цитат
+package service
+
+import (
+	"context"
+	"strings"
+)
+
+// содержит 'SearchQuotes' -> return найденные цитаты по релевантности
+type SearchQuote interface {
+	SearchQuotes(ctx context.Context, query string) (*SearchResponse, error)
+}
+
+// пустой запрос -> ErrServiceInvalidData
+// поиск в базе, и дальнейшая сериализация для ответа
+func (s *serviceQuote) SearchQuotes(ctx context.Context, query string) (*SearchResponse, error) {
+	if query = strings.TrimSpace(query); query == "" {
+		return nil, ErrServiceInvalidData
+	}
+
+	hits, err := s.DBProvider.SearchQuotes(ctx, query)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: SearchQuotes", "error", err)
+		return nil, err
+	}
+
+	serialize := SearchSerializer{Query: query, Hits: hits}
+
+	return serialize.Response(), nil
+}
@@ -2,9 +2,10 @@
 package service
 
 import (
-	"sort"
 	"strconv"
+	"time"
 
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/model"
 )
 
@@ -16,17 +17,44 @@ type QuoteSerializer struct {
 // перевод 'model.Quote' в формат для ответа
 func (q *QuoteSerializer) Response() *QuoteResponse {
 	return &QuoteResponse{
-		ID:     strconv.FormatUint(uint64(q.ID), 10),
-		Author: q.Author,
-		Body:   q.Body,
+		ID:        strconv.FormatUint(uint64(q.ID), 10),
+		Author:    q.Author,
+		Body:      q.Body,
+		Source:    q.Source,
+		Year:      q.Year,
+		SourceURL: q.SourceURL,
+		Language:  q.Language,
+		Tags:      q.Tags,
+		CreatedBy: q.CreatedBy,
+		CreatedAt: formatTime(q.CreatedAt),
+		UpdatedAt: formatTime(q.UpdatedAt),
+		Revision:  q.Revision,
 	}
 }
 
+// время в RFC 3339, нулевое -> пустая строка
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
 // шаблон ответа для 'model.Quote'
+// пустые метаданные не передаются, 'Revision' - для заголовка ETag
 type QuoteResponse struct {
-	ID     string `json:"id"`
-	Author string `json:"author"`
-	Body   string `json:"quote"`
+	ID        string   `json:"id"`
+	Author    string   `json:"author"`
+	Body      string   `json:"quote"`
+	Source    string   `json:"source,omitempty"`
+	Year      int      `json:"year,omitempty"`
+	SourceURL string   `json:"source_url,omitempty"`
+	Language  string   `json:"language,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	CreatedBy string   `json:"created_by,omitempty"`
+	CreatedAt string   `json:"created_at,omitempty"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+	Revision  uint64   `json:"-"`
 }
 
 // список статей из базы
@@ -35,20 +63,200 @@ type QuoteListSerializer struct {
 }
 
 // перевод '[]model.Quote' в формат для ответа
+// порядок цитат задает база
 func (ql *QuoteListSerializer) Response() []QuoteResponse {
-	quotes := ql.Quotes // псевдоним - для удобства
-	n := len(quotes)
-	quoteResponse := make([]QuoteResponse, 0, n)
-
-	// передаём в порядке возрастания ID цитаты
-	sort.Slice(quotes, func(i, j int) bool {
-		return quotes[i].ID < quotes[j].ID
-	})
+	quoteResponse := make([]QuoteResponse, 0, len(ql.Quotes))
 
-	for _, quote := range quotes {
+	for _, quote := range ql.Quotes {
 		serialize := QuoteSerializer{Quote: quote}
 		quoteResponse = append(quoteResponse, *serialize.Response())
 	}
 
 	return quoteResponse
 }
+
+// страница цитат из базы
+type QuotePageSerializer struct {
+	db.Page
+}
+
+// перевод 'db.Page' в формат для ответа
+func (qp *QuotePageSerializer) Response() *QuotePageResponse {
+	serialize := QuoteListSerializer{Quotes: qp.Quotes}
+
+	response := &QuotePageResponse{
+		Quotes:   serialize.Response(),
+		Total:    qp.Total,
+		Revision: qp.Revision,
+	}
+
+	if qp.NextCursor != 0 {
+		response.NextCursor = strconv.FormatUint(uint64(qp.NextCursor), 10)
+	}
+
+	return response
+}
+
+// шаблон ответа для страницы цитат
+// 'NextCursor' - значение 'after_id' для следующей страницы, пустой -> страница последняя
+// 'Revision' - ревизия хранилища, для заголовка ETag
+type QuotePageResponse struct {
+	Quotes     []QuoteResponse `json:"quotes"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	Total      int             `json:"total"`
+	Revision   uint64          `json:"-"`
+}
+
+// цитаты автора для ответа, в тело попадает только 'Quotes'
+// 'Revision' - ревизия хранилища, для заголовка ETag
+type AuthorQuotesResponse struct {
+	Quotes   []QuoteResponse
+	Revision uint64
+}
+
+// результат поиска из базы
+type SearchSerializer struct {
+	Query string
+	Hits  []db.SearchHit
+}
+
+// перевод '[]db.SearchHit' в формат для ответа
+func (ss *SearchSerializer) Response() *SearchResponse {
+	results := make([]SearchHitResponse, 0, len(ss.Hits))
+
+	for _, hit := range ss.Hits {
+		serialize := QuoteSerializer{Quote: hit.Quote}
+
+		matches := make([]MatchResponse, 0, len(hit.Matches))
+		for _, m := range hit.Matches {
+			matches = append(matches, MatchResponse{Start: m.Start, End: m.End})
+		}
+
+		results = append(results, SearchHitResponse{
+			QuoteResponse: *serialize.Response(),
+			Score:         hit.Score,
+			Matches:       matches,
+		})
+	}
+
+	return &SearchResponse{
+		Query:   ss.Query,
+		Total:   len(results),
+		Results: results,
+	}
+}
+
+// шаблон ответа для поиска
+type SearchResponse struct {
+	Query   string              `json:"query"`
+	Total   int                 `json:"total"`
+	Results []SearchHitResponse `json:"results"`
+}
+
+// найденная цитата, 'Matches' - позиции совпадений в 'quote' (в символах)
+type SearchHitResponse struct {
+	QuoteResponse
+	Score   float64         `json:"score"`
+	Matches []MatchResponse `json:"matches"`
+}
+
+// позиция совпадения, 'End' не включается
+type MatchResponse struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// теги из базы
+type TagListSerializer struct {
+	Tags []db.TagCount
+}
+
+// перевод '[]db.TagCount' в формат для ответа, порядок задает база
+func (tl *TagListSerializer) Response() []TagResponse {
+	tagResponse := make([]TagResponse, 0, len(tl.Tags))
+
+	for _, tag := range tl.Tags {
+		tagResponse = append(tagResponse, TagResponse{Tag: tag.Tag, Count: tag.Count})
+	}
+
+	return tagResponse
+}
+
+// шаблон ответа для тега
+type TagResponse struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// страница авторов из базы
+type AuthorPageSerializer struct {
+	db.AuthorPage
+}
+
+// перевод 'db.AuthorPage' в формат для ответа
+func (ap *AuthorPageSerializer) Response() *AuthorPageResponse {
+	authors := make([]AuthorResponse, 0, len(ap.Authors))
+
+	for _, author := range ap.Authors {
+		authors = append(authors, AuthorResponse{Name: author.Name, Count: author.Count})
+	}
+
+	return &AuthorPageResponse{
+		Authors:    authors,
+		NextCursor: ap.NextCursor,
+		Total:      ap.Total,
+	}
+}
+
+// шаблон ответа для страницы авторов
+// 'NextCursor' - значение 'after' для следующей страницы, пустой -> страница последняя
+type AuthorPageResponse struct {
+	Authors    []AuthorResponse `json:"authors"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Total      int              `json:"total"`
+}
+
+// шаблон ответа для автора
+type AuthorResponse struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// отчет импорта: количество по статусам и результат каждой записи
+type ImportResponse struct {
+	Atomic    bool                   `json:"atomic"`
+	Created   int                    `json:"created"`
+	Duplicate int                    `json:"duplicate"`
+	Invalid   int                    `json:"invalid"`
+	Results   []ImportResultResponse `json:"results"`
+}
+
+// результат записи импорта
+// 'Line' - номер строки (для массива JSON - номер элемента), 'ID' - только для 'created'
+type ImportResultResponse struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ответ пакетного запроса
+type BatchResponse struct {
+	Atomic    bool                  `json:"atomic"`
+	Succeeded int                   `json:"succeeded"`
+	Failed    int                   `json:"failed"`
+	Results   []BatchResultResponse `json:"results"`
+}
+
+// результат элемента пакета
+// 'Index' - позиция в запросе, 'Status' и 'Code' - код ответа и код ошибки для элемента, задает 'transport'
+// 'Err' - причина отказа (nil -> элемент применен)
+type BatchResultResponse struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Code   string `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	Err error `json:"-"`
+}
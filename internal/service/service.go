@@ -3,6 +3,7 @@ package service
 
 import (
 	"errors"
+	"log/slog"
 
 	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
 )
@@ -19,17 +20,31 @@ type FindList interface {
 // вся логика
 type ServiceQuote interface {
 	AddQuote
+	FindQuote
 	FindRandomQuote
 	FindList
 	RemoveQuote
+	ChangeQuote
+	SearchQuote
+	FindTagList
+	ChangeTags
+	FindAuthor
+	BulkQuote
+	BatchQuote
 }
 
-// содержит db.Provider
+// содержит db.Provider и лог сервиса
 type serviceQuote struct {
 	DBProvider db.Provider
+
+	log *slog.Logger
 }
 
 // конструктор для serviceQuote
-func NewService(dbProvider db.Provider) *serviceQuote {
-	return &serviceQuote{DBProvider: dbProvider}
+// 'logger' == nil -> 'slog.Default()'
+func NewService(dbProvider db.Provider, logger *slog.Logger) *serviceQuote {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &serviceQuote{DBProvider: dbProvider, log: logger}
 }
@@ -0,0 +1,56 @@
+// логика тегов цитат
+package service
+
+import (
+	"context"
+)
+
+// содержит 'ReadTagList' -> return все теги с количеством цитат
+type FindTagList interface {
+	ReadTagList(ctx context.Context) ([]TagResponse, error)
+}
+
+// содержит 'AttachTags', 'DetachTags' -> добавление и удаление тегов цитаты
+type ChangeTags interface {
+	AttachTags(ctx context.Context, id uint, tags []string) (*QuoteResponse, error)
+	DetachTags(ctx context.Context, id uint, tags []string) (*QuoteResponse, error)
+}
+
+// получение тегов из базы, и дальнейшая сериализация для ответа
+func (s *serviceQuote) ReadTagList(ctx context.Context) ([]TagResponse, error) {
+	tags, err := s.DBProvider.TagList(ctx)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: ReadTagList", "error", err)
+		return nil, err
+	}
+
+	serialize := TagListSerializer{Tags: tags}
+
+	return serialize.Response(), nil
+}
+
+// добавляем теги к цитате по ID, сериализуем цитату для ответа
+func (s *serviceQuote) AttachTags(ctx context.Context, id uint, tags []string) (*QuoteResponse, error) {
+	quote, err := s.DBProvider.AddQuoteTags(ctx, id, tags)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: AttachTags", "error", err)
+		return nil, err
+	}
+
+	serialize := QuoteSerializer{Quote: *quote}
+
+	return serialize.Response(), nil
+}
+
+// удаляем теги у цитаты по ID, сериализуем цитату для ответа
+func (s *serviceQuote) DetachTags(ctx context.Context, id uint, tags []string) (*QuoteResponse, error) {
+	quote, err := s.DBProvider.RemoveQuoteTags(ctx, id, tags)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: DetachTags", "error", err)
+		return nil, err
+	}
+
+	serialize := QuoteSerializer{Quote: *quote}
+
+	return serialize.Response(), nil
+}
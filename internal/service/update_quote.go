@@ -0,0 +1,31 @@
+// логика изменения цитаты
+package service
+
+import (
+	"context"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+)
+
+// содержит метод 'UpdateQuote' -> изменение цитаты
+type ChangeQuote interface {
+	UpdateQuote(ctx context.Context, id uint, revision uint64, patch db.QuotePatch) (*QuoteResponse, error)
+}
+
+// изменяем по ID, сериализуем измененную цитату для ответа
+// 'revision' != 0 -> только цитату с этой ревизией
+func (s *serviceQuote) UpdateQuote(
+	ctx context.Context,
+	id uint,
+	revision uint64,
+	patch db.QuotePatch) (*QuoteResponse, error) {
+	quote, err := s.DBProvider.UpdateQuote(ctx, id, revision, patch)
+	if err != nil {
+		s.log.DebugContext(ctx, "service: UpdateQuote", "error", err)
+		return nil, err
+	}
+
+	serialize := QuoteSerializer{Quote: *quote}
+
+	return serialize.Response(), nil
+}
@@ -0,0 +1,97 @@
+// ошибки проверки полей запроса
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// причины ошибок полей
+const (
+	fieldRequired  = "must not be empty"
+	fieldYear      = "must be between -3000 and the current year, except 0"
+	fieldSourceURL = "must be an absolute http(s) url"
+	fieldLanguage  = "must be a BCP-47 language tag"
+	fieldTags      = "at most 20 tags, each from 1 to 50 characters"
+	fieldUnknown   = "unknown field"
+)
+
+// ошибка одного поля тела запроса, 'Field' - имя поля в json
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// все ошибки полей тела запроса
+// errors.Is(err, ErrServiceInvalidData) -> true
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (ve *ValidationError) Error() string {
+	parts := make([]string, 0, len(ve.Fields))
+	for _, field := range ve.Fields {
+		parts = append(parts, field.Field+" - "+field.Message)
+	}
+
+	return ErrServiceInvalidData.Error() + ": " + strings.Join(parts, "; ")
+}
+
+func (ve *ValidationError) Unwrap() error {
+	return ErrServiceInvalidData
+}
+
+// добавляем ошибку поля
+func (ve *ValidationError) add(field, message string) {
+	ve.Fields = append(ve.Fields, FieldError{Field: field, Message: message})
+}
+
+// nil, если ошибок полей нет
+func (ve *ValidationError) result() error {
+	if len(ve.Fields) == 0 {
+		return nil
+	}
+	return ve
+}
+
+// вызывает 'utils.DecodeJSON', ошибки json переводим в ошибки данных:
+// неверный тип или неизвестное поле -> 'ValidationError', неверный синтаксис -> 'ErrServiceInvalidData'
+// ошибки 'utils' возвращаем как есть
+func decodeJSON(req *http.Request, obj any) error {
+	err := utils.DecodeJSON(req, obj)
+	if err == nil || errors.Is(err, utils.ErrUtilsInvalidMedia) || errors.Is(err, utils.ErrUtilsEmptyBody) {
+		return err
+	}
+
+	return jsonError(err)
+}
+
+// ошибка 'json.Decoder.Decode' -> ошибка данных
+func jsonError(err error) error {
+	if errors.Is(err, io.EOF) {
+		return utils.ErrUtilsEmptyBody
+	}
+
+	invalid := &ValidationError{}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		invalid.add(typeErr.Field, "invalid type "+typeErr.Value+", expected "+typeErr.Type.String())
+		return invalid
+	}
+
+	// у 'DisallowUnknownFields' нет своего типа ошибки
+	if field, ok := strings.CutPrefix(err.Error(), `json: unknown field "`); ok {
+		invalid.add(strings.TrimSuffix(field, `"`), fieldUnknown)
+		return invalid
+	}
+
+	// ошибка чтения тела (например, превышен размер) остается доступна для 'errors.Is'
+	return fmt.Errorf("%w: %w", ErrServiceInvalidData, err)
+}
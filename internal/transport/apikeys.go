@@ -0,0 +1,320 @@
+// ключи API: хранение SHA-256 ключей, проверка и замена
+package transport
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// заголовок запроса с ключом API
+const APIKeyHeader = "X-API-Key"
+
+// начало нового ключа из 'Rotate'
+const APIKeyPrefix = "qb_"
+
+var (
+	// ошибка в файле или списке ключей
+	ErrAPIKeysInvalid = errors.New("invalid api keys")
+
+	// ключа с таким именем нет
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// ключ API: имя, SHA-256 ключа в hex и права
+type APIKey struct {
+	Name   string
+	Hash   string
+	Scopes []string
+}
+
+// ключи API, сами ключи не хранятся - только их SHA-256
+// 'path' пустой -> замены ключей не сохраняются
+type APIKeys struct {
+	mu     sync.RWMutex
+	keys   []APIKey
+	byHash map[string]int
+
+	path string
+
+	// новый ключ для 'Rotate', для тестов
+	generate func() string
+}
+
+// ответ замены ключа, 'Key' показывается только один раз
+type APIKeyResponse struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+}
+
+// SHA-256 ключа в hex, так ключ хранится в файле
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// случайный ключ из 32 байт в hex с 'APIKeyPrefix'
+func newAPIKey() string {
+	var buf [32]byte
+	rand.Read(buf[:])
+	return APIKeyPrefix + hex.EncodeToString(buf[:])
+}
+
+// ключи из списка, ошибка в ключе -> 'ErrAPIKeysInvalid'
+func NewAPIKeys(keys []APIKey) (*APIKeys, error) {
+	k := &APIKeys{generate: newAPIKey}
+	if err := k.set(keys); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// ключи из файла 'path', замены ключей пишутся в этот же файл
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys, err := ParseAPIKeys(file)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := NewAPIKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	k.path = path
+
+	return k, nil
+}
+
+// замена всех ключей, ошибка в ключе -> 'ErrAPIKeysInvalid', ключи не меняются
+func (k *APIKeys) Replace(keys []APIKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.set(keys)
+}
+
+// ключи заново из файла 'LoadAPIKeys', ошибка в файле -> ключи не меняются
+// файл читается под блокировкой - замена из 'Rotate' не потеряется
+// ключи не из файла -> 'ErrAPIKeysInvalid'
+func (k *APIKeys) Reload() error {
+	if k.path == "" {
+		return fmt.Errorf("%w: keys are not loaded from file", ErrAPIKeysInvalid)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	file, err := os.Open(k.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	keys, err := ParseAPIKeys(file)
+	if err != nil {
+		return err
+	}
+
+	return k.set(keys)
+}
+
+// ключи по строкам: "<имя> <sha256 hex> <право>[,<право>]"
+// пустые строки и строки с '#' пропускаются
+func ParseAPIKeys(r io.Reader) ([]APIKey, error) {
+	var keys []APIKey
+
+	scan := bufio.NewScanner(r)
+	for line := 1; scan.Scan(); line++ {
+		str := strings.TrimSpace(scan.Text())
+		if str == "" || strings.HasPrefix(str, "#") {
+			continue
+		}
+
+		fields := strings.Fields(str)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: line %d - expected name, hash and scopes", ErrAPIKeysInvalid, line)
+		}
+
+		keys = append(keys, APIKey{Name: fields[0], Hash: fields[1], Scopes: strings.Split(fields[2], ",")})
+	}
+
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// проверка и замена всех ключей
+func (k *APIKeys) set(keys []APIKey) error {
+	byHash := make(map[string]int, len(keys))
+	names := make(map[string]struct{}, len(keys))
+
+	for i, key := range keys {
+		if key.Name == "" || strings.ContainsFunc(key.Name, nameSeparator) {
+			return fmt.Errorf("%w: invalid name %q", ErrAPIKeysInvalid, key.Name)
+		}
+		if _, ex := names[key.Name]; ex {
+			return fmt.Errorf("%w: duplicate name %q", ErrAPIKeysInvalid, key.Name)
+		}
+		names[key.Name] = struct{}{}
+
+		if !validHash(key.Hash) {
+			return fmt.Errorf("%w: %s - hash must be sha256 in lowercase hex", ErrAPIKeysInvalid, key.Name)
+		}
+		if _, ex := byHash[key.Hash]; ex {
+			return fmt.Errorf("%w: %s - duplicate hash", ErrAPIKeysInvalid, key.Name)
+		}
+		byHash[key.Hash] = i
+
+		if len(key.Scopes) == 0 {
+			return fmt.Errorf("%w: %s - scopes are required", ErrAPIKeysInvalid, key.Name)
+		}
+		for _, scope := range key.Scopes {
+			if !slices.Contains(knownScopes, scope) {
+				return fmt.Errorf("%w: %s - unknown scope %q", ErrAPIKeysInvalid, key.Name, scope)
+			}
+		}
+	}
+
+	k.keys = keys
+	k.byHash = byHash
+
+	return nil
+}
+
+// символы, которые ломают строку файла ключей
+func nameSeparator(r rune) bool {
+	return r == ' ' || r == '\t' || r == ',' || r == '#'
+}
+
+func validHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for i := 0; i < len(hash); i++ {
+		if !('0' <= hash[i] && hash[i] <= '9' || 'a' <= hash[i] && hash[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ключ из заголовка 'X-API-Key', заголовка нет -> nil, nil
+func (k *APIKeys) Authenticate(r *http.Request) (*auth.Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	i, ex := k.byHash[HashAPIKey(key)]
+	if !ex {
+		return nil, fmt.Errorf("%w: invalid api key", ErrUnauthorized)
+	}
+
+	return &auth.Principal{Subject: k.keys[i].Name, Scopes: slices.Clone(k.keys[i].Scopes)}, nil
+}
+
+func (k *APIKeys) Challenge() string {
+	return `ApiKey header="` + APIKeyHeader + `"`
+}
+
+// новый ключ для 'name', старый перестает работать сразу
+// ключи из файла -> файл переписывается, ошибка записи -> ключ не меняется
+func (k *APIKeys) Rotate(name string) (APIKeyResponse, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	i := slices.IndexFunc(k.keys, func(key APIKey) bool { return key.Name == name })
+	if i < 0 {
+		return APIKeyResponse{}, ErrAPIKeyNotFound
+	}
+
+	secret := k.generate()
+	keys := slices.Clone(k.keys)
+	keys[i].Hash = HashAPIKey(secret)
+
+	if k.path != "" {
+		if err := writeAPIKeys(k.path, keys); err != nil {
+			return APIKeyResponse{}, err
+		}
+	}
+
+	if err := k.set(keys); err != nil {
+		return APIKeyResponse{}, err
+	}
+
+	return APIKeyResponse{Name: name, Key: secret, Scopes: slices.Clone(keys[i].Scopes)}, nil
+}
+
+// атомарная запись ключей в 'path' в формате 'ParseAPIKeys', комментарии не сохраняются
+func writeAPIKeys(path string, keys []APIKey) error {
+	var buf strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s %s %s\n", key.Name, key.Hash, strings.Join(key.Scopes, ","))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(buf.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// замена ключа по имени из пути url
+// нет ошибок -> возвращаем 'APIKeyResponse' с новым ключом
+func RotateAPIKey(keys *APIKeys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, err := keys.Rotate(r.PathValue("name"))
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		logging.FromContext(r.Context()).InfoContext(r.Context(), "transport: RotateAPIKey",
+			"name", response.Name, "by", PrincipalFrom(r.Context()).Subject)
+
+		w.Header().Set("Cache-Control", "no-store")
+		utils.EncodeJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,189 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+)
+
+func Test_ParseAPIKeys(t *testing.T) {
+	hash := HashAPIKey("reader-key")
+
+	testData := []struct {
+		title        string
+		data         string
+		expectedKeys []APIKey
+		expectedErr  error
+	}{
+		{
+			title: `keys with comments`,
+			data: "# name hash scopes\n\n" +
+				"reader " + hash + " quotes:read\n" +
+				"  editor " + HashAPIKey("editor-key") + " quotes:read,quotes:write,quotes:delete  \n",
+			expectedKeys: []APIKey{
+				{Name: "reader", Hash: hash, Scopes: []string{ScopeRead}},
+				{Name: "editor", Hash: HashAPIKey("editor-key"), Scopes: []string{ScopeRead, ScopeWrite, ScopeDelete}},
+			},
+		},
+		{
+			title:       `no scopes`,
+			data:        "reader " + hash + "\n",
+			expectedErr: ErrAPIKeysInvalid,
+		},
+		{
+			title:       `unknown scope`,
+			data:        "reader " + hash + " quotes:read,quotes:all\n",
+			expectedErr: ErrAPIKeysInvalid,
+		},
+		{
+			title:       `plain key instead of hash`,
+			data:        "reader reader-key quotes:read\n",
+			expectedErr: ErrAPIKeysInvalid,
+		},
+		{
+			title:       `upper case hash`,
+			data:        "reader " + strings.ToUpper(hash) + " quotes:read\n",
+			expectedErr: ErrAPIKeysInvalid,
+		},
+		{
+			title:       `duplicate name`,
+			data:        "reader " + hash + " quotes:read\nreader " + HashAPIKey("other") + " quotes:read\n",
+			expectedErr: ErrAPIKeysInvalid,
+		},
+		{
+			title:       `duplicate hash`,
+			data:        "reader " + hash + " quotes:read\nwriter " + hash + " quotes:write\n",
+			expectedErr: ErrAPIKeysInvalid,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			keys, err := ParseAPIKeys(strings.NewReader(test.data))
+			if err == nil {
+				_, err = NewAPIKeys(keys)
+			}
+
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("error {got}:{want} {%v}:{%v};", err, test.expectedErr)
+			}
+			if err == nil && !reflect.DeepEqual(keys, test.expectedKeys) {
+				t.Errorf("keys {got}:{want} {%v}:{%v};", keys, test.expectedKeys)
+			}
+		})
+	}
+}
+
+func Test_APIKeysRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys")
+	data := "# keys\n" +
+		"admin " + HashAPIKey("admin-key") + " keys:rotate\n" +
+		"reader " + HashAPIKey("reader-key") + " quotes:read\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("os.WriteFile error - {%v};", err)
+	}
+
+	keys, err := LoadAPIKeys(path)
+	if err != nil {
+		t.Fatalf("LoadAPIKeys error - {%v};", err)
+	}
+	keys.generate = func() string { return "qb_new-reader-key" }
+
+	authenticate := func(key string) (*auth.Principal, error) {
+		req := httptest.NewRequest(http.MethodGet, `/quotes`, nil)
+		req.Header.Set(APIKeyHeader, key)
+		return keys.Authenticate(req)
+	}
+
+	if principal, err := authenticate("reader-key"); err != nil || principal.Subject != "reader" {
+		t.Fatalf("authenticate before rotate {got}:{want} {%v %v}:{reader <nil>};", principal, err)
+	}
+
+	response, err := keys.Rotate("reader")
+	if err != nil {
+		t.Fatalf("Rotate error - {%v};", err)
+	}
+	expected := APIKeyResponse{Name: "reader", Key: "qb_new-reader-key", Scopes: []string{ScopeRead}}
+	if !reflect.DeepEqual(response, expected) {
+		t.Errorf("Rotate {got}:{want} {%v}:{%v};", response, expected)
+	}
+
+	// старый ключ сразу перестает работать
+	if _, err := authenticate("reader-key"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("old key error {got}:{want} {%v}:{%v};", err, ErrUnauthorized)
+	}
+	if principal, err := authenticate("qb_new-reader-key"); err != nil || principal.Subject != "reader" {
+		t.Errorf("new key {got}:{want} {%v %v}:{reader <nil>};", principal, err)
+	}
+
+	// в файле только хеш нового ключа
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile error - {%v};", err)
+	}
+	expectedFile := "admin " + HashAPIKey("admin-key") + " keys:rotate\n" +
+		"reader " + HashAPIKey("qb_new-reader-key") + " quotes:read\n"
+	if string(saved) != expectedFile {
+		t.Errorf("keys file {got}:{want} {%s}:{%s};", saved, expectedFile)
+	}
+
+	if _, err := keys.Rotate("nobody"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("unknown name error {got}:{want} {%v}:{%v};", err, ErrAPIKeyNotFound)
+	}
+}
+
+func Test_APIKeysReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("os.WriteFile error - {%v};", err)
+		}
+	}
+	write("reader " + HashAPIKey("reader-key") + " quotes:read\n")
+
+	keys, err := LoadAPIKeys(path)
+	if err != nil {
+		t.Fatalf("LoadAPIKeys error - {%v};", err)
+	}
+
+	authenticate := func(key string) (*auth.Principal, error) {
+		req := httptest.NewRequest(http.MethodGet, `/quotes`, nil)
+		req.Header.Set(APIKeyHeader, key)
+		return keys.Authenticate(req)
+	}
+
+	write("writer " + HashAPIKey("writer-key") + " quotes:write\n")
+	if err := keys.Reload(); err != nil {
+		t.Fatalf("Reload error - {%v};", err)
+	}
+	if _, err := authenticate("reader-key"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("removed key error {got}:{want} {%v}:{%v};", err, ErrUnauthorized)
+	}
+	if principal, err := authenticate("writer-key"); err != nil || principal.Subject != "writer" {
+		t.Errorf("added key {got}:{want} {%v %v}:{writer <nil>};", principal, err)
+	}
+
+	// ошибка в файле -> ключи прежние
+	write("writer " + HashAPIKey("writer-key") + " quotes:all\n")
+	if err := keys.Reload(); !errors.Is(err, ErrAPIKeysInvalid) {
+		t.Errorf("Reload invalid file error {got}:{want} {%v}:{%v};", err, ErrAPIKeysInvalid)
+	}
+	if principal, err := authenticate("writer-key"); err != nil || principal.Subject != "writer" {
+		t.Errorf("key after failed reload {got}:{want} {%v %v}:{writer <nil>};", principal, err)
+	}
+
+	inMemory, err := NewAPIKeys(nil)
+	if err != nil {
+		t.Fatalf("NewAPIKeys error - {%v};", err)
+	}
+	if err := inMemory.Reload(); !errors.Is(err, ErrAPIKeysInvalid) {
+		t.Errorf("Reload without file error {got}:{want} {%v}:{%v};", err, ErrAPIKeysInvalid)
+	}
+}
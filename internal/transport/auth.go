@@ -0,0 +1,102 @@
+// аутентификация и права доступа к маршрутам
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+)
+
+// права доступа, задаются для каждого маршрута в 'Transport.Routes'
+const (
+	ScopeRead   = "quotes:read"
+	ScopeWrite  = "quotes:write"
+	ScopeDelete = "quotes:delete"
+
+	// замена ключей API через admin маршрут
+	ScopeKeys = "keys:rotate"
+)
+
+// известные права, другие в ключах - ошибка
+var knownScopes = []string{ScopeRead, ScopeWrite, ScopeDelete, ScopeKeys}
+
+var (
+	// учетных данных нет или они неверные -> 401
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// у клиента нет права маршрута -> 403
+	ErrForbidden = errors.New("forbidden")
+)
+
+// клиент из 'ctx', аутентификации не было -> nil
+func PrincipalFrom(ctx context.Context) *auth.Principal {
+	return auth.FromContext(ctx)
+}
+
+// ключ клиента для его бюджетов и ключей идемпотентности:
+// "key:" + имя ключа API, "jwt:" + 'sub' токена, без аутентификации - "ip:" + адрес из 'ClientIP'
+// вид учетных данных в ключе -> ключ API и токен с одинаковым именем не совпадают
+func clientKey(r *http.Request, trusted []netip.Prefix) string {
+	principal := PrincipalFrom(r.Context())
+	switch {
+	case principal == nil:
+		return "ip:" + ClientIP(r, trusted).String()
+	case principal.Claims != nil:
+		return "jwt:" + principal.Subject
+	default:
+		return "key:" + principal.Subject
+	}
+}
+
+// проверка учетных данных одного вида
+type Authenticator interface {
+	// учетных данных этого вида в запросе нет -> nil, nil
+	// неверные -> ошибка с 'ErrUnauthorized'
+	Authenticate(r *http.Request) (*auth.Principal, error)
+
+	// значение "WWW-Authenticate" для ответа 401
+	Challenge() string
+}
+
+// обертка маршрута с правом 'scope': клиент - от первого 'auths', нашедшего учетные данные
+// нет учетных данных или они неверные -> 401 с "WWW-Authenticate", нет 'scope' -> 403
+// клиент передается в контексте запроса ('auth.NewContext') - его видят сервис и хранилище
+func Authorize(scope string, auths ...Authenticator) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticate(r, auths)
+			if err != nil {
+				for _, authenticator := range auths {
+					w.Header().Add("WWW-Authenticate", authenticator.Challenge())
+				}
+				writeProblem(w, r, err)
+				return
+			}
+
+			if !principal.HasScope(scope) {
+				writeProblem(w, r, fmt.Errorf("%w: scope %s required", ErrForbidden, scope))
+				return
+			}
+
+			next(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+		}
+	}
+}
+
+func authenticate(r *http.Request, auths []Authenticator) (*auth.Principal, error) {
+	for _, authenticator := range auths {
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if principal != nil {
+			return principal, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: credentials required", ErrUnauthorized)
+}
@@ -0,0 +1,175 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+func Test_Authorize(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(t.Context(), quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	keys, err := NewAPIKeys([]APIKey{
+		{Name: "reader", Hash: HashAPIKey("reader-key"), Scopes: []string{ScopeRead}},
+		{Name: "editor", Hash: HashAPIKey("editor-key"), Scopes: []string{ScopeRead, ScopeWrite}},
+		{Name: "cleaner", Hash: HashAPIKey("cleaner-key"), Scopes: []string{ScopeDelete}},
+		{Name: "admin", Hash: HashAPIKey("admin-key"), Scopes: []string{ScopeKeys}},
+	})
+	if err != nil {
+		t.Fatalf("NewAPIKeys error - {%v};", err)
+	}
+	keys.generate = func() string { return "qb_new-reader-key" }
+
+	r := newTestTransport(service.NewService(store, nil), WithAPIKeys(keys))
+
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		key                string
+		body               string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `no key`,
+			method:             http.MethodGet,
+			path:               `/quotes/1`,
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse:   problemBody(ErrUnauthorized, `unauthorized: credentials required`),
+		},
+		{
+			title:              `invalid key`,
+			method:             http.MethodGet,
+			path:               `/quotes/1`,
+			key:                `guess`,
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse:   problemBody(ErrUnauthorized, `unauthorized: invalid api key`),
+		},
+		{
+			title:              `read with read scope`,
+			method:             http.MethodGet,
+			path:               `/quotes/9`,
+			key:                `reader-key`,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			title:              `delete with read scope`,
+			method:             http.MethodDelete,
+			path:               `/quotes/1`,
+			key:                `reader-key`,
+			expectedStatusCode: http.StatusForbidden,
+			expectedResponse:   problemBody(ErrForbidden, `forbidden: scope quotes:delete required`),
+		},
+		{
+			title:              `write scope is not delete`,
+			method:             http.MethodDelete,
+			path:               `/quotes/1`,
+			key:                `editor-key`,
+			expectedStatusCode: http.StatusForbidden,
+			expectedResponse:   problemBody(ErrForbidden, `forbidden: scope quotes:delete required`),
+		},
+		{
+			title:              `delete with delete scope`,
+			method:             http.MethodDelete,
+			path:               `/quotes/1`,
+			key:                `cleaner-key`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			title:              `no key before body limit`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			body:               `{"author":"Seneca","quote":"` + strings.Repeat("a", 1<<10) + `"}`,
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse:   problemBody(ErrUnauthorized, `unauthorized: credentials required`),
+		},
+		{
+			title:              `write with write scope`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			key:                `editor-key`,
+			body:               `{"author":"Seneca","quote":"While we teach, we learn"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			title:              `rotate without keys scope`,
+			method:             http.MethodPost,
+			path:               `/admin/keys/reader/rotate`,
+			key:                `editor-key`,
+			expectedStatusCode: http.StatusForbidden,
+			expectedResponse:   problemBody(ErrForbidden, `forbidden: scope keys:rotate required`),
+		},
+		{
+			title:              `rotate unknown key`,
+			method:             http.MethodPost,
+			path:               `/admin/keys/nobody/rotate`,
+			key:                `admin-key`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse:   problemBody(ErrAPIKeyNotFound, `api key not found`),
+		},
+		{
+			title:              `rotate`,
+			method:             http.MethodPost,
+			path:               `/admin/keys/reader/rotate`,
+			key:                `admin-key`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"name\":\"reader\",\"key\":\"qb_new-reader-key\",\"scopes\":[\"quotes:read\"]}\n",
+		},
+		{
+			title:              `old key after rotate`,
+			method:             http.MethodGet,
+			path:               `/quotes/2`,
+			key:                `reader-key`,
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse:   problemBody(ErrUnauthorized, `unauthorized: invalid api key`),
+		},
+		{
+			title:              `new key after rotate`,
+			method:             http.MethodGet,
+			path:               `/quotes/9`,
+			key:                `qb_new-reader-key`,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if test.key != "" {
+				req.Header.Set(APIKeyHeader, test.key)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}, body - {%s};", w.Code, test.expectedStatusCode, w.Body.String())
+			}
+
+			if test.expectedResponse != "" && w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+
+			challenge := w.Header().Get("WWW-Authenticate")
+			if w.Code == http.StatusUnauthorized && challenge != `ApiKey header="X-API-Key"` {
+				t.Errorf("WWW-Authenticate {got}:{want} {%s}:{ApiKey header=\"X-API-Key\"};", challenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// аутентификация по JWT из заголовка "Authorization: Bearer"
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+)
+
+// токены, подписанные ключами 'verifier'
+// 'sub' токена - клиент, права - из 'scope' через пробел
+type BearerTokens struct {
+	verifier *auth.JWTVerifier
+}
+
+func NewBearerTokens(verifier *auth.JWTVerifier) *BearerTokens {
+	return &BearerTokens{verifier: verifier}
+}
+
+// токен из "Authorization: Bearer <token>", другой схемы нет -> nil, nil
+func (b *BearerTokens) Authenticate(r *http.Request) (*auth.Principal, error) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return nil, nil
+	}
+
+	claims, err := b.verifier.Verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+
+	return &auth.Principal{Subject: claims.Subject, Scopes: claims.Scopes, Claims: claims}, nil
+}
+
+func (b *BearerTokens) Challenge() string {
+	return `Bearer realm="quotebook"`
+}
@@ -0,0 +1,158 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+// ключ HS256 для тестов, 32 байта
+var testJWTKey = []byte("0123456789abcdef0123456789abcdef")
+
+// JWT HS256 с полями 'claims'
+func testToken(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("json.Marshal error - {%v};", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, testJWTKey)
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func Test_BearerTokens(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	verifier, err := auth.NewJWTVerifier(auth.WithHMACKey(testJWTKey), auth.WithAudience("quotebook"),
+		auth.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("auth.NewJWTVerifier error - {%v};", err)
+	}
+
+	r := newTestTransport(service.NewService(store, nil), WithBearerTokens(verifier))
+
+	writer := testToken(t, map[string]any{
+		"sub": "gateway-user", "aud": "quotebook", "scope": "quotes:read quotes:write", "exp": now.Add(time.Hour).Unix(),
+	})
+	reader := testToken(t, map[string]any{
+		"sub": "gateway-reader", "aud": "quotebook", "scope": "quotes:read", "exp": now.Add(time.Hour).Unix(),
+	})
+	expired := testToken(t, map[string]any{
+		"sub": "gateway-user", "aud": "quotebook", "scope": "quotes:write", "exp": now.Add(-time.Hour).Unix(),
+	})
+
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		authorization      string
+		body               string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `creator is token subject`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			authorization:      "Bearer " + writer,
+			body:               `{"author":"Seneca","quote":"While we teach, we learn"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedResponse:   "{\"id\":\"1\",\"author\":\"Seneca\",\"quote\":\"While we teach, we learn\",\"created_by\":\"gateway-user\"}\n",
+		},
+		{
+			title:              `creator in batch`,
+			method:             http.MethodPost,
+			path:               `/quotes/batch`,
+			authorization:      "bearer " + writer,
+			body:               `{"quotes":[{"author":"Epictetus","quote":"Wealth consists in having few wants"}]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			title:              `read scope`,
+			method:             http.MethodGet,
+			path:               `/quotes/2`,
+			authorization:      "Bearer " + reader,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"id\":\"2\",\"author\":\"Epictetus\",\"quote\":\"Wealth consists in having few wants\",\"created_by\":\"gateway-user\"}\n",
+		},
+		{
+			title:              `read scope only`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			authorization:      "Bearer " + reader,
+			body:               `{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity"}`,
+			expectedStatusCode: http.StatusForbidden,
+			expectedResponse:   problemBody(ErrForbidden, `forbidden: scope quotes:write required`),
+		},
+		{
+			title:              `expired token`,
+			method:             http.MethodGet,
+			path:               `/quotes/1`,
+			authorization:      "Bearer " + expired,
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse:   problemBody(ErrUnauthorized, `unauthorized: token expired`),
+		},
+		{
+			title:              `tampered token`,
+			method:             http.MethodGet,
+			path:               `/quotes/1`,
+			authorization:      "Bearer " + reader[:len(reader)-2] + "AA",
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse:   problemBody(ErrUnauthorized, `unauthorized: invalid token: signature mismatch`),
+		},
+		{
+			title:              `other scheme`,
+			method:             http.MethodGet,
+			path:               `/quotes/1`,
+			authorization:      "Basic dXNlcjpwYXNz",
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse:   problemBody(ErrUnauthorized, `unauthorized: credentials required`),
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", test.authorization)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}, body - {%s};", w.Code, test.expectedStatusCode, w.Body.String())
+			}
+
+			if test.expectedResponse != "" && w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+
+			challenge := w.Header().Get("WWW-Authenticate")
+			if w.Code == http.StatusUnauthorized && challenge != `Bearer realm="quotebook"` {
+				t.Errorf("WWW-Authenticate {got}:{want} {%s}:{Bearer realm=\"quotebook\"};", challenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// ETag по ревизии хранилища и условные запросы
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+)
+
+// сильный ETag цитаты по ее ревизии: тело ответа для ревизии одно и то же байт в байт,
+// поэтому его можно сравнивать в 'If-Match'
+func quoteETag(revision uint64) string {
+	return `"` + strconv.FormatUint(revision, 10) + `"`
+}
+
+// слабый ETag списка по ревизии хранилища: ответ меняется только вместе с ревизией,
+// но одинаковость байт не обещаем - только для 'If-None-Match'
+func listETag(revision uint64) string {
+	return `W/` + quoteETag(revision)
+}
+
+// ставим ETag 'tag', при совпадении с 'If-None-Match' отвечаем 304 без тела
+// true -> ответ уже записан
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	// слабое сравнение - префикс 'W/' не учитывается
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// ревизия цитаты из 'If-Match' для 'db.Provider', нет заголовка или '*' -> 0 (без проверки)
+// принимаем один сильный ETag ('quoteETag'), сравнение сильное (RFC 9110) - слабый не совпадает
+// ETag не из этого сервиса совпасть не может -> 'db.ErrDBRevisionMismatch'
+func ifMatch(r *http.Request) (uint64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	// слабый 'W/"..."' не проходит проверку кавычек
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, db.ErrDBRevisionMismatch
+	}
+
+	revision, err := strconv.ParseUint(header[1:len(header)-1], 10, 64)
+	if err != nil || revision == 0 {
+		return 0, db.ErrDBRevisionMismatch
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,211 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+func Test_ConditionalRequests(t *testing.T) {
+	const napoleon = "{\"id\":\"2\",\"author\":\"napoleon bonaparte\"," +
+		"\"quote\":\"my dictionary does not contain the word 'impossible'\"}\n"
+
+	// запросы выполняются по порядку на одном хранилище,
+	// ревизии цитат из 'quotesData' - 1, 2, 3
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		header             string
+		value              string
+		body               string
+		expectedStatusCode int
+		expectedETag       string
+		expectedResponse   string
+	}{
+		{
+			title:              `get by id`,
+			method:             http.MethodGet,
+			path:               `/quotes/2`,
+			expectedStatusCode: http.StatusOK,
+			expectedETag:       `"2"`,
+			expectedResponse:   napoleon,
+		},
+		{
+			title:              `get by id not modified`,
+			method:             http.MethodGet,
+			path:               `/quotes/2`,
+			header:             `If-None-Match`,
+			value:              `W/"2"`,
+			expectedStatusCode: http.StatusNotModified,
+			expectedETag:       `"2"`,
+		},
+		{
+			title:              `get list not modified, one of tags`,
+			method:             http.MethodGet,
+			path:               `/quotes?limit=1`,
+			header:             `If-None-Match`,
+			value:              `"1", "3"`,
+			expectedStatusCode: http.StatusNotModified,
+			expectedETag:       `W/"3"`,
+		},
+		{
+			title:              `get by author`,
+			method:             http.MethodGet,
+			path:               `/quotes?author=Napoleon%20Bonaparte`,
+			expectedStatusCode: http.StatusOK,
+			expectedETag:       `W/"3"`,
+			expectedResponse:   "[" + strings.TrimSuffix(napoleon, "\n") + "]\n",
+		},
+		{
+			title:              `get by author not modified`,
+			method:             http.MethodGet,
+			path:               `/quotes?author=Napoleon%20Bonaparte`,
+			header:             `If-None-Match`,
+			value:              `W/"3"`,
+			expectedStatusCode: http.StatusNotModified,
+			expectedETag:       `W/"3"`,
+		},
+		{
+			title:              `patch with old revision`,
+			method:             http.MethodPatch,
+			path:               `/quotes/2`,
+			header:             `If-Match`,
+			value:              `"1"`,
+			body:               `{"source":"Letters"}`,
+			expectedStatusCode: http.StatusPreconditionFailed,
+			expectedResponse:   problemBody(db.ErrDBRevisionMismatch, `quote revision mismatch`),
+		},
+		{
+			title:              `patch with weak etag`,
+			method:             http.MethodPatch,
+			path:               `/quotes/2`,
+			header:             `If-Match`,
+			value:              `W/"2"`,
+			body:               `{"source":"Letters"}`,
+			expectedStatusCode: http.StatusPreconditionFailed,
+			expectedResponse:   problemBody(db.ErrDBRevisionMismatch, `quote revision mismatch`),
+		},
+		{
+			title:              `patch with current revision`,
+			method:             http.MethodPatch,
+			path:               `/quotes/2`,
+			header:             `If-Match`,
+			value:              `"2"`,
+			body:               `{"source":"Letters"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedETag:       `"4"`,
+			expectedResponse: "{\"id\":\"2\",\"author\":\"napoleon bonaparte\"," +
+				"\"quote\":\"my dictionary does not contain the word 'impossible'\",\"source\":\"Letters\"}\n",
+		},
+		{
+			title:              `get list after change`,
+			method:             http.MethodGet,
+			path:               `/quotes?limit=1&after_id=1`,
+			header:             `If-None-Match`,
+			value:              `W/"3"`,
+			expectedStatusCode: http.StatusOK,
+			expectedETag:       `W/"4"`,
+			expectedResponse: "{\"quotes\":[{\"id\":\"2\",\"author\":\"napoleon bonaparte\"," +
+				"\"quote\":\"my dictionary does not contain the word 'impossible'\",\"source\":\"Letters\"}]," +
+				"\"next_cursor\":\"2\",\"total\":3}\n",
+		},
+		{
+			title:              `get by author after change`,
+			method:             http.MethodGet,
+			path:               `/quotes?author=Napoleon%20Bonaparte`,
+			header:             `If-None-Match`,
+			value:              `W/"3"`,
+			expectedStatusCode: http.StatusOK,
+			expectedETag:       `W/"4"`,
+			expectedResponse: "[{\"id\":\"2\",\"author\":\"napoleon bonaparte\"," +
+				"\"quote\":\"my dictionary does not contain the word 'impossible'\",\"source\":\"Letters\"}]\n",
+		},
+		{
+			title:              `delete with foreign etag`,
+			method:             http.MethodDelete,
+			path:               `/quotes/2`,
+			header:             `If-Match`,
+			value:              `"abc"`,
+			expectedStatusCode: http.StatusPreconditionFailed,
+			expectedResponse:   problemBody(db.ErrDBRevisionMismatch, `quote revision mismatch`),
+		},
+		{
+			title:              `delete with old revision`,
+			method:             http.MethodDelete,
+			path:               `/quotes/2`,
+			header:             `If-Match`,
+			value:              `"2"`,
+			expectedStatusCode: http.StatusPreconditionFailed,
+			expectedResponse:   problemBody(db.ErrDBRevisionMismatch, `quote revision mismatch`),
+		},
+		{
+			title:              `delete with current revision`,
+			method:             http.MethodDelete,
+			path:               `/quotes/2`,
+			header:             `If-Match`,
+			value:              `"4"`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{}\n",
+		},
+		{
+			title:              `delete any revision`,
+			method:             http.MethodDelete,
+			path:               `/quotes/3`,
+			header:             `If-Match`,
+			value:              `*`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{}\n",
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(context.TODO(), quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			if test.body != "" {
+				req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+			}
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if tag := w.Header().Get("ETag"); tag != test.expectedETag {
+				t.Errorf("ETag not equal {got}:{want} {%s}:{%s}", tag, test.expectedETag)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
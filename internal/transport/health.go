@@ -0,0 +1,177 @@
+// пробы живости и готовности, данные сборки
+package transport
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// запрос пришел до готовности или во время остановки
+var ErrNotReady = errors.New("service is not ready")
+
+// пути проб, без admin порта они на общем порту и доступны до готовности
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+	VersionPath = "/version"
+	MetricsPath = "/metrics"
+
+	// начало пути замены ключей API
+	APIKeysPath = "/admin/keys"
+)
+
+// состояние сервиса для проб
+type health struct {
+	// хранилище загружено и маршруты добавлены, сервис не останавливается
+	ready atomic.Bool
+
+	// размер хранилища для '/version', nil -> хранилище не загружено
+	stats atomic.Pointer[func() db.Stats]
+
+	started time.Time
+	now     func() time.Time
+}
+
+func newHealth(now func() time.Time) *health {
+	return &health{started: now(), now: now}
+}
+
+// ответ '/healthz' и '/readyz'
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+// ответ '/version'
+// 'Store' нет, пока хранилище не загружено
+type VersionResponse struct {
+	Version       string         `json:"version"`
+	Revision      string         `json:"revision,omitempty"`
+	RevisionTime  string         `json:"revision_time,omitempty"`
+	Modified      bool           `json:"modified,omitempty"`
+	GoVersion     string         `json:"go_version"`
+	StartedAt     string         `json:"started_at"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	Store         *StoreResponse `json:"store,omitempty"`
+}
+
+// размер хранилища в '/version'
+type StoreResponse struct {
+	Quotes  int `json:"quotes"`
+	Authors int `json:"authors"`
+}
+
+// сервис готов принимать запросы, 'stats' - размер хранилища для '/version'
+func (r Transport) Ready(stats func() db.Stats) {
+	r.health.stats.Store(&stats)
+	r.health.ready.Store(true)
+}
+
+// сервис останавливается, '/readyz' -> 503, новые запросы к API -> 503
+func (r Transport) NotReady() {
+	r.health.ready.Store(false)
+}
+
+// пробы и метрики - на admin порту, если он задан, иначе на общем
+func (r Transport) probes() {
+	mux := r.ServeMux
+	if r.admin != nil {
+		mux = r.admin
+	}
+
+	// частые запросы проб и сбора метрик - в лог только на уровне debug
+	r.handleOn(mux, "GET "+HealthzPath, slog.LevelDebug, Healthz())
+	r.handleOn(mux, "GET "+ReadyzPath, slog.LevelDebug, Readyz(r.health))
+	r.handleOn(mux, "GET "+VersionPath, slog.LevelDebug, Version(r.health))
+	r.handleOn(mux, "GET "+MetricsPath, slog.LevelDebug, r.metrics.Handler())
+}
+
+// замена ключей API - рядом с пробами, только с правом 'ScopeKeys'
+func (r Transport) adminRoutes() {
+	if r.keys == nil {
+		return
+	}
+
+	mux := r.ServeMux
+	if r.admin != nil {
+		mux = r.admin
+	}
+
+	r.handleOn(mux, "POST "+APIKeysPath+"/{name}/rotate", slog.LevelInfo, RotateAPIKey(r.keys),
+		LimitAuthFailures(r.authLimit, r.trusted, Authorize(ScopeKeys, r.keys)))
+}
+
+// до готовности на общий порт проходят только пробы (без admin порта), остальное -> 503
+func (r Transport) gate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.health.ready.Load() || (r.admin == nil && probePath(req.URL.Path)) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		w.Header().Set("Retry-After", "1")
+		Chain(func(w http.ResponseWriter, req *http.Request) {
+			writeProblem(w, req, ErrNotReady)
+		}, RequestIDs(r.newRequestID, r.log))(w, req)
+	})
+}
+
+func probePath(path string) bool {
+	return path == HealthzPath || path == ReadyzPath || path == VersionPath || path == MetricsPath
+}
+
+// процесс жив - всегда 200
+func Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		utils.EncodeJSON(w, http.StatusOK, StatusResponse{Status: "ok"})
+	}
+}
+
+// готов -> 200, хранилище загружается или сервис останавливается -> 503
+func Readyz(h *health) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if !h.ready.Load() {
+			utils.EncodeJSON(w, http.StatusServiceUnavailable, StatusResponse{Status: "not ready"})
+			return
+		}
+		utils.EncodeJSON(w, http.StatusOK, StatusResponse{Status: "ready"})
+	}
+}
+
+// версия модуля и ревизия VCS из 'debug.ReadBuildInfo', время работы и размер хранилища
+func Version(h *health) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		response := VersionResponse{
+			StartedAt:     h.started.UTC().Format(time.RFC3339),
+			UptimeSeconds: int64(h.now().Sub(h.started) / time.Second),
+		}
+
+		if info, ok := debug.ReadBuildInfo(); ok {
+			response.Version = info.Main.Version
+			response.GoVersion = info.GoVersion
+			for _, setting := range info.Settings {
+				switch setting.Key {
+				case "vcs.revision":
+					response.Revision = setting.Value
+				case "vcs.time":
+					response.RevisionTime = setting.Value
+				case "vcs.modified":
+					response.Modified = setting.Value == "true"
+				}
+			}
+		}
+
+		if stats := h.stats.Load(); stats != nil && *stats != nil {
+			size := (*stats)()
+			response.Store = &StoreResponse{Quotes: size.Quotes, Authors: size.Authors}
+		}
+
+		utils.EncodeJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,192 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+func Test_Health(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+	usecase := service.NewService(store, nil)
+
+	// маршруты API еще не добавлены, хранилище "загружается"
+	r := NewTransport(&config.Config{ServerHost: "not host", ServerPort: "not port"},
+		WithRequestIDGenerator(func() string { return testRequestID }))
+
+	// запросы выполняются по порядку через 'Server.Handler' (с проверкой готовности)
+	testData := []struct {
+		title              string
+		before             func()
+		path               string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `healthz while loading`,
+			path:               HealthzPath,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"status\":\"ok\"}\n",
+		},
+		{
+			title:              `readyz while loading`,
+			path:               ReadyzPath,
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedResponse:   "{\"status\":\"not ready\"}\n",
+		},
+		{
+			title:              `api while loading`,
+			path:               `/quotes`,
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedResponse:   problemBody(ErrNotReady, ``),
+		},
+		{
+			title: `readyz after Ready`,
+			before: func() {
+				r.Routes(usecase)
+				r.Ready(store.Stats)
+			},
+			path:               ReadyzPath,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"status\":\"ready\"}\n",
+		},
+		{
+			title:              `api after Ready`,
+			path:               `/quotes`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse:   problemBody(db.ErrDBEmpty, `quote list is empty`),
+		},
+		{
+			title:              `readyz while stopping`,
+			before:             r.NotReady,
+			path:               ReadyzPath,
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedResponse:   "{\"status\":\"not ready\"}\n",
+		},
+		{
+			title:              `api while stopping`,
+			path:               `/quotes`,
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedResponse:   problemBody(ErrNotReady, ``),
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			if test.before != nil {
+				test.before()
+			}
+
+			w := httptest.NewRecorder()
+			r.Server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
+func Test_Version(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(t.Context(), quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	r := newTestTransport(service.NewService(store, nil))
+
+	started := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.health.started = started
+	r.health.now = func() time.Time { return started.Add(90 * time.Second) }
+
+	version := func() VersionResponse {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, VersionPath, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, http.StatusOK)
+		}
+
+		var response VersionResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("json.Decode error - {%v};", err)
+		}
+		return response
+	}
+
+	// до 'Ready' размера хранилища нет
+	if response := version(); response.Store != nil {
+		t.Errorf("store before Ready {got}:{want} {%v}:{nil};", response.Store)
+	}
+
+	r.Ready(store.Stats)
+	response := version()
+
+	if response.GoVersion == "" || response.StartedAt != "2025-01-01T12:00:00Z" || response.UptimeSeconds != 90 {
+		t.Errorf("version {got}:{want} {%s %s %d}:{go* 2025-01-01T12:00:00Z 90};",
+			response.GoVersion, response.StartedAt, response.UptimeSeconds)
+	}
+
+	if response.Store == nil || *response.Store != (StoreResponse{Quotes: 3, Authors: 3}) {
+		t.Errorf("store {got}:{want} {%v}:{{3 3}};", response.Store)
+	}
+}
+
+func Test_AdminPort(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	r := NewTransport(&config.Config{ServerHost: "not host", ServerPort: "8080", AdminPort: "9090"},
+		WithRequestIDGenerator(func() string { return testRequestID }))
+	r.Routes(service.NewService(store, nil))
+	r.Ready(store.Stats)
+
+	if r.Admin == nil || r.Admin.Addr != "not host:9090" {
+		t.Fatalf("admin server {got}:{want} {%v}:{not host:9090};", r.Admin)
+	}
+
+	testData := []struct {
+		title              string
+		handler            http.Handler
+		path               string
+		expectedStatusCode int
+	}{
+		{`healthz on admin`, r.Admin.Handler, HealthzPath, http.StatusOK},
+		{`readyz on admin`, r.Admin.Handler, ReadyzPath, http.StatusOK},
+		{`metrics on admin`, r.Admin.Handler, MetricsPath, http.StatusOK},
+		{`no api on admin`, r.Admin.Handler, `/quotes`, http.StatusNotFound},
+		{`no healthz on public`, r.Server.Handler, HealthzPath, http.StatusNotFound},
+		{`no metrics on public`, r.Server.Handler, MetricsPath, http.StatusNotFound},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			test.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+		})
+	}
+}
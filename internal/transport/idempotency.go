@@ -0,0 +1,307 @@
+// ключи идемпотентности для повторов запросов
+package transport
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// заголовок запроса с ключом
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// заголовок ответа, повторенного по ключу
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// наибольшая длина ключа
+const MaxIdempotencyKeyLength = 255
+
+// время хранения ответа, если не задано в 'config.Config'
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// наибольшее число ключей в памяти, если не задано в 'config.Config'
+const DefaultIdempotencyMaxKeys = 100000
+
+var (
+	// ключ пустой, длиннее 'MaxIdempotencyKeyLength' или содержит не печатные символы ASCII
+	ErrIdempotencyInvalidKey = errors.New("invalid idempotency key")
+
+	// ключ уже использован для запроса с другими данными
+	ErrIdempotencyMismatch = errors.New("idempotency key reused with different request")
+
+	// запрос с этим ключом еще выполняется
+	ErrIdempotencyInProgress = errors.New("request with idempotency key is in progress")
+)
+
+// сохраненный ответ для повтора
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// хранилище ключей идемпотентности
+// 'key' - ключ клиента вместе с 'clientKey', 'fingerprint' - отпечаток запроса (метод, путь, тело)
+type IdempotencyStore interface {
+	// ключа нет -> резервируем его за 'fingerprint' и возвращаем nil,
+	// ответ по ключу сохранен -> возвращаем его,
+	// другой 'fingerprint' -> 'ErrIdempotencyMismatch', ответа еще нет -> 'ErrIdempotencyInProgress'
+	Reserve(key, fingerprint string) (*StoredResponse, error)
+
+	// сохраняем ответ для зарезервированного ключа
+	Save(key string, response StoredResponse)
+
+	// снимаем резерв - ответ не сохраняется, запрос можно повторить
+	Release(key string)
+}
+
+// запись ключа в памяти, 'response' == nil -> запрос выполняется
+type idempotencyEntry struct {
+	fingerprint string
+	response    *StoredResponse
+	expires     time.Time
+
+	// место в 'memoryIdempotencyStore.order'
+	elem *list.Element
+}
+
+// хранилище ключей в памяти
+// просроченные записи удаляются при 'Reserve' не чаще раза в 'sweepInterval'
+// ключей больше 'maxKeys' -> вытесняется самый давно зарезервированный
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+
+	// ключи в порядке резервирования
+	order *list.List
+
+	ttl           time.Duration
+	maxKeys       int
+	sweepInterval time.Duration
+	nextSweep     time.Time
+
+	// источник времени, для тестов
+	now func() time.Time
+}
+
+// конструктор для memoryIdempotencyStore
+// 'ttl' <= 0 -> 'DefaultIdempotencyTTL', 'maxKeys' <= 0 -> 'DefaultIdempotencyMaxKeys'
+func NewMemoryIdempotencyStore(ttl time.Duration, maxKeys int) *memoryIdempotencyStore {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	if maxKeys <= 0 {
+		maxKeys = DefaultIdempotencyMaxKeys
+	}
+
+	return &memoryIdempotencyStore{
+		entries:       make(map[string]*idempotencyEntry),
+		order:         list.New(),
+		ttl:           ttl,
+		maxKeys:       maxKeys,
+		sweepInterval: min(ttl, time.Minute),
+		now:           time.Now,
+	}
+}
+
+func (m *memoryIdempotencyStore) Reserve(key, fingerprint string) (*StoredResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	entry, ex := m.entries[key]
+	if !ex || !now.Before(entry.expires) {
+		if ex {
+			m.delete(key)
+		}
+		for len(m.entries) >= m.maxKeys {
+			m.delete(m.order.Front().Value.(string))
+		}
+		m.entries[key] = &idempotencyEntry{
+			fingerprint: fingerprint,
+			expires:     now.Add(m.ttl),
+			elem:        m.order.PushBack(key),
+		}
+		return nil, nil
+	}
+
+	if entry.fingerprint != fingerprint {
+		return nil, ErrIdempotencyMismatch
+	}
+
+	if entry.response == nil {
+		return nil, ErrIdempotencyInProgress
+	}
+
+	return entry.response, nil
+}
+
+func (m *memoryIdempotencyStore) Save(key string, response StoredResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ex := m.entries[key]; ex {
+		entry.response = &response
+		entry.expires = m.now().Add(m.ttl)
+	}
+}
+
+func (m *memoryIdempotencyStore) Release(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.delete(key)
+}
+
+// удаляем запись 'key', вызывается под 'mu'
+func (m *memoryIdempotencyStore) delete(key string) {
+	if entry, ex := m.entries[key]; ex {
+		m.order.Remove(entry.elem)
+		delete(m.entries, key)
+	}
+}
+
+// удаляем просроченные записи, вызывается под 'mu'
+func (m *memoryIdempotencyStore) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+
+	for key, entry := range m.entries {
+		if !now.Before(entry.expires) {
+			m.delete(key)
+		}
+	}
+
+	m.nextSweep = now.Add(m.sweepInterval)
+}
+
+// обертка для обработчика с заголовком 'Idempotency-Key'
+// первый ответ (кроме 5xx) сохраняется и повторяется для того же ключа того же клиента,
+// тот же ключ с другим запросом -> 422, запрос с ключом еще выполняется -> 409
+// ключи хранятся отдельно для каждого клиента ('clientKey'): ключа API, токена или адреса
+// без заголовка запрос выполняется как обычно
+func Idempotent(keys IdempotencyStore, trusted []netip.Prefix, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		if !validIdempotencyKey(key) {
+			writeProblem(w, r, ErrIdempotencyInvalidKey)
+			return
+		}
+
+		// тело нужно для отпечатка и для обработчика
+		var body []byte
+		if r.Body != nil {
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeProblem(w, r, err)
+				return
+			}
+			body = data
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		// в ключе нет '\n' - разные пары клиент/ключ дают разные строки
+		key = clientKey(r, trusted) + "\n" + key
+
+		stored, err := keys.Reserve(key, fingerprint(r, body))
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		if stored != nil {
+			// 'X-Request-ID' остается от текущего запроса
+			for name, values := range stored.Header {
+				if name != http.CanonicalHeaderKey(RequestIDHeader) {
+					w.Header()[name] = values
+				}
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+
+		// паника в обработчике -> резерв снимается, запрос можно повторить
+		saved := false
+		defer func() {
+			if !saved {
+				keys.Release(key)
+			}
+		}()
+
+		next(rec, r)
+
+		if rec.status < http.StatusInternalServerError {
+			keys.Save(key, StoredResponse{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()})
+			saved = true
+		}
+	}
+}
+
+// ключ - печатные символы ASCII, от 1 до 'MaxIdempotencyKeyLength'
+func validIdempotencyKey(key string) bool {
+	if len(key) > MaxIdempotencyKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sha256 метода, пути и тела запроса
+func fingerprint(r *http.Request, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	sum.Write(body)
+
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// пишет ответ клиенту и сохраняет его копию
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+		rr.header = rr.ResponseWriter.Header().Clone()
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	if rr.status == 0 {
+		rr.WriteHeader(http.StatusOK)
+	}
+	rr.body.Write(data)
+
+	return rr.ResponseWriter.Write(data)
+}
@@ -0,0 +1,351 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+func Test_memoryIdempotencyStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := NewMemoryIdempotencyStore(time.Hour, 0)
+	store.now = func() time.Time { return now }
+
+	if stored, err := store.Reserve("key", "a"); stored != nil || err != nil {
+		t.Fatalf("first Reserve {got}:{want} {%v, %v}:{nil, nil};", stored, err)
+	}
+
+	if _, err := store.Reserve("key", "a"); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Errorf("Reserve in progress {got}:{want} {%v}:{%v};", err, ErrIdempotencyInProgress)
+	}
+
+	store.Save("key", StoredResponse{Status: http.StatusCreated, Body: []byte("body")})
+
+	stored, err := store.Reserve("key", "a")
+	if err != nil || stored == nil || stored.Status != http.StatusCreated || string(stored.Body) != "body" {
+		t.Errorf("Reserve saved {got}:{want} {%v, %v}:{201 body, nil};", stored, err)
+	}
+
+	if _, err := store.Reserve("key", "b"); !errors.Is(err, ErrIdempotencyMismatch) {
+		t.Errorf("Reserve other fingerprint {got}:{want} {%v}:{%v};", err, ErrIdempotencyMismatch)
+	}
+
+	// после 'ttl' ключ свободен, в том числе для другого запроса
+	now = now.Add(time.Hour)
+	if stored, err := store.Reserve("key", "b"); stored != nil || err != nil {
+		t.Errorf("Reserve expired {got}:{want} {%v, %v}:{nil, nil};", stored, err)
+	}
+
+	store.Release("key")
+	if stored, err := store.Reserve("key", "c"); stored != nil || err != nil {
+		t.Errorf("Reserve released {got}:{want} {%v, %v}:{nil, nil};", stored, err)
+	}
+
+	// просроченные записи удаляются при следующем 'Reserve'
+	store.Reserve("other", "a")
+	now = now.Add(2 * time.Hour)
+	store.Reserve("new", "a")
+	if len(store.entries) != 1 {
+		t.Errorf("entries after sweep {got}:{want} {%d}:{1};", len(store.entries))
+	}
+
+	// ключей больше 'maxKeys' -> вытесняется самый старый
+	bounded := NewMemoryIdempotencyStore(time.Hour, 2)
+	bounded.Reserve("first", "a")
+	bounded.Save("first", StoredResponse{Status: http.StatusCreated})
+	bounded.Reserve("second", "a")
+	bounded.Reserve("third", "a")
+	if len(bounded.entries) != 2 || bounded.order.Len() != 2 {
+		t.Errorf("entries over limit {got}:{want} {%d %d}:{2 2};", len(bounded.entries), bounded.order.Len())
+	}
+	if stored, err := bounded.Reserve("first", "b"); stored != nil || err != nil {
+		t.Errorf("Reserve evicted {got}:{want} {%v, %v}:{nil, nil};", stored, err)
+	}
+	if _, err := bounded.Reserve("third", "a"); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Errorf("Reserve kept {got}:{want} {%v}:{%v};", err, ErrIdempotencyInProgress)
+	}
+}
+
+func Test_Idempotent_SaveOneQuote(t *testing.T) {
+	const (
+		quote      = `{"author":"William James","quote":"The greatest weapon against stress is our ability to choose one thought over another"}`
+		otherQuote = `{"author":"Steve Jobs","quote":"Stay hungry, stay foolish"}`
+		created    = "{\"id\":\"1\",\"author\":\"William James\"," +
+			"\"quote\":\"The greatest weapon against stress is our ability to choose one thought over another\"}\n"
+	)
+
+	// запросы выполняются по порядку на одном хранилище
+	testData := []struct {
+		title              string
+		key                string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedResponse   string
+		expectedLocation   string
+		expectedReplayed   string
+	}{
+		{
+			title:              `first request`,
+			key:                `key-1`,
+			datasForRequest:    quote,
+			expectedStatusCode: http.StatusCreated,
+			expectedResponse:   created,
+			expectedLocation:   `/quotes/1`,
+		},
+		{
+			title:              `retry with same key -> same response`,
+			key:                `key-1`,
+			datasForRequest:    quote,
+			expectedStatusCode: http.StatusCreated,
+			expectedResponse:   created,
+			expectedLocation:   `/quotes/1`,
+			expectedReplayed:   `true`,
+		},
+		{
+			title:              `same key with other payload`,
+			key:                `key-1`,
+			datasForRequest:    otherQuote,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedResponse:   problemBody(ErrIdempotencyMismatch, `idempotency key reused with different request`),
+		},
+		{
+			title:              `new key with same payload -> duplicate`,
+			key:                `key-2`,
+			datasForRequest:    quote,
+			expectedStatusCode: http.StatusConflict,
+			expectedResponse:   problemBody(db.ErrDBAlreadyExists, `quote already exists`),
+		},
+		{
+			title:              `error response is replayed too`,
+			key:                `key-2`,
+			datasForRequest:    quote,
+			expectedStatusCode: http.StatusConflict,
+			expectedResponse:   problemBody(db.ErrDBAlreadyExists, `quote already exists`),
+			expectedReplayed:   `true`,
+		},
+		{
+			title:              `without key`,
+			datasForRequest:    quote,
+			expectedStatusCode: http.StatusConflict,
+			expectedResponse:   problemBody(db.ErrDBAlreadyExists, `quote already exists`),
+		},
+		{
+			title:              `invalid key`,
+			key:                strings.Repeat("k", MaxIdempotencyKeyLength+1),
+			datasForRequest:    otherQuote,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(ErrIdempotencyInvalidKey, `invalid idempotency key`),
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase, WithIdempotencyStore(NewMemoryIdempotencyStore(time.Hour, 0)))
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, `/quotes`, bytes.NewBufferString(test.datasForRequest))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+			if test.key != "" {
+				req.Header.Set(IdempotencyKeyHeader, test.key)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if location := w.Header().Get("Location"); location != test.expectedLocation {
+				t.Errorf("Location not equal {got}:{want} {%s}:{%s}", location, test.expectedLocation)
+			}
+
+			if replayed := w.Header().Get(IdempotentReplayedHeader); replayed != test.expectedReplayed {
+				t.Errorf("%s not equal {got}:{want} {%s}:{%s}", IdempotentReplayedHeader, replayed, test.expectedReplayed)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
+func Test_Idempotent_Subjects(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	keys, err := NewAPIKeys([]APIKey{
+		{Name: "alice", Hash: HashAPIKey("alice-key"), Scopes: []string{ScopeWrite}},
+		{Name: "bob", Hash: HashAPIKey("bob-key"), Scopes: []string{ScopeWrite}},
+	})
+	if err != nil {
+		t.Fatalf("NewAPIKeys error - {%v};", err)
+	}
+
+	now := time.Now()
+	verifier, err := auth.NewJWTVerifier(auth.WithHMACKey(testJWTKey), auth.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("auth.NewJWTVerifier error - {%v};", err)
+	}
+	// 'sub' токена совпадает с именем ключа API
+	token := testToken(t, map[string]any{"sub": "alice", "scope": "quotes:write", "exp": now.Add(time.Hour).Unix()})
+
+	r := newTestTransport(service.NewService(store, nil), WithAPIKeys(keys), WithBearerTokens(verifier))
+
+	// запросы выполняются по порядку с одним ключом идемпотентности
+	testData := []struct {
+		title              string
+		apiKey             string
+		token              string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedLocation   string
+		expectedReplayed   string
+	}{
+		{
+			title:              `first client`,
+			apiKey:             `alice-key`,
+			datasForRequest:    `{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedLocation:   `/quotes/1`,
+		},
+		{
+			title:              `other client with same key`,
+			apiKey:             `bob-key`,
+			datasForRequest:    `{"author":"Epictetus","quote":"First say to yourself what you would be"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedLocation:   `/quotes/2`,
+		},
+		{
+			title:              `token with same subject as api key`,
+			token:              token,
+			datasForRequest:    `{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity"}`,
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			title:              `first client retry`,
+			apiKey:             `alice-key`,
+			datasForRequest:    `{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedLocation:   `/quotes/1`,
+			expectedReplayed:   `true`,
+		},
+	}
+
+	for i, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			// у каждого запроса свой ID, в повторе он не подменяется сохраненным
+			requestID := fmt.Sprintf("request-%d", i)
+
+			req := httptest.NewRequest(http.MethodPost, `/quotes`, strings.NewReader(test.datasForRequest))
+			req.Header.Set(RequestIDHeader, requestID)
+			req.Header.Set("Content-Type", "application/json")
+			if test.apiKey != "" {
+				req.Header.Set(APIKeyHeader, test.apiKey)
+			}
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			req.Header.Set(IdempotencyKeyHeader, `shared-key`)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}, body - {%s};", w.Code, test.expectedStatusCode, w.Body.String())
+			}
+
+			if location := w.Header().Get("Location"); location != test.expectedLocation {
+				t.Errorf("Location not equal {got}:{want} {%s}:{%s}", location, test.expectedLocation)
+			}
+
+			if replayed := w.Header().Get(IdempotentReplayedHeader); replayed != test.expectedReplayed {
+				t.Errorf("%s not equal {got}:{want} {%s}:{%s}", IdempotentReplayedHeader, replayed, test.expectedReplayed)
+			}
+
+			if id := w.Header().Get(RequestIDHeader); id != requestID {
+				t.Errorf("%s not equal {got}:{want} {%s}:{%s}", RequestIDHeader, id, requestID)
+			}
+		})
+	}
+}
+
+func Test_Idempotent_Addresses(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	// без аутентификации клиент - адрес
+	r := newTestTransport(service.NewService(store, nil))
+
+	// запросы выполняются по порядку с одним ключом идемпотентности
+	testData := []struct {
+		title              string
+		remoteAddr         string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedReplayed   string
+	}{
+		{
+			title:              `first address`,
+			remoteAddr:         `198.51.100.7:4312`,
+			datasForRequest:    `{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			title:              `other address does not get stored response`,
+			remoteAddr:         `198.51.100.8:4312`,
+			datasForRequest:    `{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity"}`,
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			title:              `first address retry`,
+			remoteAddr:         `198.51.100.7:5000`,
+			datasForRequest:    `{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedReplayed:   `true`,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, `/quotes`, strings.NewReader(test.datasForRequest))
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(IdempotencyKeyHeader, `shared-key`)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}, body - {%s};", w.Code, test.expectedStatusCode, w.Body.String())
+			}
+
+			if replayed := w.Header().Get(IdempotentReplayedHeader); replayed != test.expectedReplayed {
+				t.Errorf("%s not equal {got}:{want} {%s}:{%s}", IdempotentReplayedHeader, replayed, test.expectedReplayed)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// метрики HTTP запросов
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/metrics"
+)
+
+// количество и время запросов по маршруту и статусу
+type httpMetrics struct {
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+}
+
+func newHTTPMetrics(reg *metrics.Registry) *httpMetrics {
+	return &httpMetrics{
+		requests: reg.NewCounterVec("quotebook_http_requests_total",
+			"Number of HTTP requests by route and status.", "route", "status"),
+		duration: reg.NewHistogramVec("quotebook_http_request_duration_seconds",
+			"Duration of HTTP requests by route and status.", metrics.DefBuckets, "route", "status"),
+	}
+}
+
+// обертка для обработчика маршрута 'route' (шаблон 'ServeMux')
+func (m *httpMetrics) middleware(route string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+
+			next(sw, r)
+
+			status := strconv.Itoa(sw.statusCode())
+			m.requests.Inc(route, status)
+			m.duration.Observe(time.Since(start).Seconds(), route, status)
+		}
+	}
+}
+
+// запоминает статус и размер ответа, остальное передает в 'http.ResponseWriter'
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(data []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(data)
+	sw.written += int64(n)
+	return n, err
+}
+
+// выгрузка цитат отправляет ответ частями
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// для 'http.ResponseController'
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// обработчик ничего не записал -> 200
+func (sw *statusWriter) statusCode() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
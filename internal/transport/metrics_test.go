@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/metrics"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+func Test_Metrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	store, err := newTestStore(db.WithObserver(metrics.NewStoreMetrics(reg)))
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+	metrics.NewStoreStats(reg,
+		func() int { return store.Stats().Quotes },
+		func() int { return store.Stats().Authors })
+
+	usecase := service.NewService(store, nil)
+	srv := httptest.NewServer(newTestTransport(usecase, WithMetrics(reg)))
+	defer srv.Close()
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, `/quotes`, `{"author":"Seneca","quote":"While we teach, we learn"}`},
+		{http.MethodGet, `/quotes/1`, ``},
+		{http.MethodGet, `/quotes/9`, ``},
+		{http.MethodGet, `/quotes/nine`, ``},
+	}
+
+	for _, request := range requests {
+		req, err := http.NewRequest(request.method, srv.URL+request.path, strings.NewReader(request.body))
+		if err != nil {
+			t.Fatalf("http.NewRequest error - {%v};", err)
+		}
+		if request.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("http.Client.Do error - {%v};", err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(srv.URL + `/metrics`)
+	if err != nil {
+		t.Fatalf("http.Get error - {%v};", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status not equal {got}:{want} {%d}:{%d}", resp.StatusCode, http.StatusOK)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != metrics.ContentType {
+		t.Errorf("Content-Type not equal {got}:{want} {%s}:{%s}", contentType, metrics.ContentType)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("io.ReadAll error - {%v};", err)
+	}
+
+	expectedLines := []string{
+		`# TYPE quotebook_http_requests_total counter`,
+		`quotebook_http_requests_total{route="POST /quotes",status="201"} 1`,
+		`quotebook_http_requests_total{route="GET /quotes/{id}",status="200"} 1`,
+		`quotebook_http_requests_total{route="GET /quotes/{id}",status="400"} 1`,
+		`quotebook_http_requests_total{route="GET /quotes/{id}",status="404"} 1`,
+		`# TYPE quotebook_http_request_duration_seconds histogram`,
+		`quotebook_http_request_duration_seconds_count{route="POST /quotes",status="201"} 1`,
+		`quotebook_http_request_duration_seconds_bucket{route="GET /quotes/{id}",status="404",le="+Inf"} 1`,
+		`quotebook_store_operation_duration_seconds_count{operation="NewQuote"} 1`,
+		`quotebook_store_operation_duration_seconds_count{operation="QuoteByID"} 2`,
+		`quotebook_store_lock_wait_seconds_count 1`,
+		`quotebook_quotes 1`,
+		`quotebook_authors 1`,
+	}
+
+	lines := strings.Split(string(body), "\n")
+	for _, expected := range expectedLines {
+		found := false
+		for _, line := range lines {
+			if line == expected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("metrics has no line {%s}, body - {%s};", expected, body)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// цепочка обработчиков вокруг маршрутов
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
+)
+
+// тело запроса больше 'MaxBodySize'
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// обертка для обработчика маршрута
+type Middleware func(next http.HandlerFunc) http.HandlerFunc
+
+// 'handler' в обертках 'mws', первая обертка - внешняя
+func Chain(handler http.HandlerFunc, mws ...Middleware) http.HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](handler)
+	}
+	return handler
+}
+
+// паника в обработчике -> запись с трассой стека в лог и 'Problem' с 500,
+// ответ уже начат -> соединение обрывается
+func Recoverer() Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// сервер сам обрывает соединение без записи в лог
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				logging.FromContext(r.Context()).ErrorContext(r.Context(), "transport: Recoverer",
+					"panic", v, "stack", string(debug.Stack()))
+
+				if sw.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				// паника уже в логе со стеком
+				respondProblem(sw, r, internalProblem, fmt.Errorf("panic: %v", v))
+			}()
+
+			next(sw, r)
+		}
+	}
+}
+
+// запись уровня 'level' о запросе после ответа: маршрут, метод, путь, статус, размер ответа и время
+// текст ошибки 5xx пишет 'writeProblem', паники - 'Recoverer'
+func AccessLog(route string, level slog.Level) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+
+			// при панике без 'Recoverer' записи нет
+			next(sw, r)
+
+			logging.FromContext(r.Context()).Log(r.Context(), level, "transport: access",
+				slog.String("route", route),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.statusCode()),
+				slog.Int64("bytes", sw.written),
+				slog.Duration("duration", time.Since(start)))
+		}
+	}
+}
+
+// тело запроса не больше 'limit' байт, 'limit' <= 0 -> без ограничения
+// "Content-Length" больше 'limit' -> сразу 413,
+// иначе чтение после 'limit' байт возвращает 'ErrBodyTooLarge'
+func MaxBodySize(limit int64) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next(w, r)
+				return
+			}
+
+			if r.ContentLength > limit {
+				writeProblem(w, r, fmt.Errorf("%w: limit %d bytes", ErrBodyTooLarge, limit))
+				return
+			}
+
+			r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit), limit: limit}
+			next(w, r)
+		}
+	}
+}
+
+// тело запроса из 'http.MaxBytesReader', '*http.MaxBytesError' -> 'ErrBodyTooLarge'
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = fmt.Errorf("%w: limit %d bytes", ErrBodyTooLarge, b.limit)
+	}
+
+	return n, err
+}
@@ -0,0 +1,268 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+// обертка записывает свое имя до и после обработчика
+func recordMiddleware(calls *[]string, name string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name)
+			next(w, r)
+			*calls = append(*calls, "/"+name)
+		}
+	}
+}
+
+func Test_Chain(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	calls := []string{}
+	r := newTestTransport(service.NewService(store, nil),
+		WithMiddleware(recordMiddleware(&calls, "first"), recordMiddleware(&calls, "second")))
+
+	r.handle("GET /chain", func(w http.ResponseWriter, _ *http.Request) {
+		calls = append(calls, "handler")
+		w.WriteHeader(http.StatusNoContent)
+	}, recordMiddleware(&calls, "route"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, `/chain`, nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, http.StatusNoContent)
+	}
+
+	// обертки из 'WithMiddleware' снаружи оберток маршрута
+	expected := []string{"first", "second", "route", "handler", "/route", "/second", "/first"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("calls {got}:{want} {%v}:{%v};", calls, expected)
+	}
+
+	// ID запроса в контексте уже есть
+	if id := w.Header().Get(RequestIDHeader); id != testRequestID {
+		t.Errorf("request id {got}:{want} {%s}:{%s};", id, testRequestID)
+	}
+}
+
+func Test_Recoverer(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	buf := &bytes.Buffer{}
+	logger, err := logging.New(buf, slog.LevelInfo, logging.FormatJSON)
+	if err != nil {
+		t.Fatalf("logging.New error - {%v};", err)
+	}
+
+	r := newTestTransport(service.NewService(store, nil), WithLogger(logger))
+	r.handle("GET /panic", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+	r.handle("GET /panic/late", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	})
+
+	t.Run(`panic before response`, func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, `/panic`, nil))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, http.StatusInternalServerError)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != ProblemContentType {
+			t.Errorf("Content-Type not equal {got}:{want} {%s}:{%s}", contentType, ProblemContentType)
+		}
+		if expected := problemBody(errors.New("panic: boom"), ``); w.Body.String() != expected {
+			t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), expected)
+		}
+
+		// паника в логе один раз, лог доступа видит 500
+		log := buf.String()
+		if !strings.Contains(log, `"msg":"transport: Recoverer","panic":"boom"`) {
+			t.Errorf("log has no panic record - {%s};", log)
+		}
+		if strings.Contains(log, `transport: writeProblem`) {
+			t.Errorf("log has writeProblem record - {%s};", log)
+		}
+		if !strings.Contains(log, `"route":"GET /panic","method":"GET","path":"/panic","status":500`) {
+			t.Errorf("log has no access record - {%s};", log)
+		}
+	})
+
+	t.Run(`panic after response started`, func(t *testing.T) {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("recover {got}:{want} {%v}:{%v};", v, http.ErrAbortHandler)
+			}
+		}()
+
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, `/panic/late`, nil))
+	})
+}
+
+func Test_AccessLog(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	buf := &bytes.Buffer{}
+	logger, err := logging.New(buf, slog.LevelInfo, logging.FormatJSON)
+	if err != nil {
+		t.Fatalf("logging.New error - {%v};", err)
+	}
+
+	r := newTestTransport(service.NewService(store, nil), WithLogger(logger))
+	r.Ready(store.Stats)
+
+	// проба пишется на уровне debug - записи нет
+	r.Server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, HealthzPath, nil))
+
+	w := httptest.NewRecorder()
+	r.Server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, `/quotes/9`, nil))
+
+	var record struct {
+		Msg       string  `json:"msg"`
+		RequestID string  `json:"request_id"`
+		Route     string  `json:"route"`
+		Method    string  `json:"method"`
+		Path      string  `json:"path"`
+		Status    int     `json:"status"`
+		Bytes     int     `json:"bytes"`
+		Duration  float64 `json:"duration"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("json.Unmarshal error - {%v}, log - {%s};", err, buf.String())
+	}
+
+	if record.Msg != "transport: access" || record.RequestID != testRequestID ||
+		record.Route != "GET /quotes/{id}" || record.Method != http.MethodGet || record.Path != "/quotes/9" ||
+		record.Status != http.StatusNotFound || record.Bytes != w.Body.Len() || record.Duration <= 0 {
+		t.Errorf("access record {got}:{want} {%+v}:{GET /quotes/9 404 %d bytes};", record, w.Body.Len())
+	}
+}
+
+// скрывает длину тела - 'http.NewRequest' не задает "Content-Length"
+type unknownLength struct {
+	io.Reader
+}
+
+func Test_MaxBodySize(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	r := NewTransport(&config.Config{ServerHost: "not host", ServerPort: "not port", MaxBodySize: 64, MaxImportSize: 128},
+		WithRequestIDGenerator(func() string { return testRequestID }))
+	r.Routes(service.NewService(store, nil))
+
+	long := `{"author":"Seneca","quote":"` + strings.Repeat("a", 64) + `"}`
+	lines := `{"author":"Seneca","quote":"While we teach, we learn"}` + "\n" +
+		`{"author":"Epictetus","quote":"Wealth consists in having few wants"}` + "\n"
+
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		body               io.Reader
+		header             map[string]string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `body in limit`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			body:               strings.NewReader(`{"author":"Seneca","quote":"While we teach, we learn"}`),
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			title:              `content length over limit`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			body:               strings.NewReader(long),
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedResponse:   problemBody(ErrBodyTooLarge, `request body too large: limit 64 bytes`),
+		},
+		{
+			title:              `unknown length over limit`,
+			method:             http.MethodPut,
+			path:               `/quotes/1`,
+			body:               unknownLength{strings.NewReader(long)},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedResponse:   problemBody(ErrBodyTooLarge, `invalid data: request body too large: limit 64 bytes`),
+		},
+		{
+			title:              `idempotent over limit`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			body:               unknownLength{strings.NewReader(long)},
+			header:             map[string]string{IdempotencyKeyHeader: "key-1"},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedResponse:   problemBody(ErrBodyTooLarge, `request body too large: limit 64 bytes`),
+		},
+		{
+			title:              `import has own limit`,
+			method:             http.MethodPost,
+			path:               `/quotes:import`,
+			body:               unknownLength{strings.NewReader(lines)},
+			header:             map[string]string{"Content-Type": "application/x-ndjson"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			title:              `import over limit`,
+			method:             http.MethodPost,
+			path:               `/quotes:import`,
+			body:               unknownLength{strings.NewReader(lines + lines)},
+			header:             map[string]string{"Content-Type": "application/x-ndjson"},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedResponse:   problemBody(ErrBodyTooLarge, `invalid data: request body too large: limit 128 bytes`),
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, test.body)
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for key, value := range test.header {
+				req.Header.Set(key, value)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}, body - {%s};", w.Code, test.expectedStatusCode, w.Body.String())
+			}
+
+			if test.expectedResponse != "" && w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// ответы об ошибках в формате RFC 7807 (application/problem+json)
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+// "Content-Type" ответа об ошибке
+const ProblemContentType = "application/problem+json"
+
+// начало 'Problem.Type', в конце - 'Problem.Code'
+const ProblemTypePrefix = "urn:quotebook:problem:"
+
+// ответ об ошибке
+// 'Code' - постоянный код для программ, 'Title' - описание кода, 'Detail' - текст ошибки
+// 'Detail' для 5xx не передается - текст внутренней ошибки только в логе
+// 'Errors' - ошибки полей тела запроса из 'service.ValidationError'
+type Problem struct {
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Status    int                  `json:"status"`
+	Detail    string               `json:"detail,omitempty"`
+	Code      string               `json:"code"`
+	RequestID string               `json:"request_id,omitempty"`
+	Errors    []service.FieldError `json:"errors,omitempty"`
+}
+
+// статус, код и заголовок для ошибки
+type problemKind struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+// перевод ошибок слоев в ответ, первая подходящая по 'errors.Is'
+// 'ErrBodyTooLarge' раньше 'service.ErrServiceInvalidData' - сервис оборачивает ошибку чтения тела
+var problemKinds = []problemKind{
+	{db.ErrDBNotFound, http.StatusNotFound, "quote_not_found", "Quote not found"},
+	{db.ErrDBEmpty, http.StatusNotFound, "quote_list_empty", "Quote list is empty"},
+	{db.ErrDBAlreadyExists, http.StatusConflict, "quote_already_exists", "Quote already exists"},
+	{db.ErrDBRevisionMismatch, http.StatusPreconditionFailed, "revision_mismatch", "Quote revision mismatch"},
+	{db.ErrDBBatchAborted, http.StatusUnprocessableEntity, "batch_aborted", "Batch aborted"},
+	{db.ErrDBInvalidQuery, http.StatusBadRequest, "invalid_search_query", "Invalid search query"},
+	{db.ErrDBWAL, http.StatusInternalServerError, "storage_error", "Storage error"},
+	{ErrBodyTooLarge, http.StatusRequestEntityTooLarge, "request_too_large", "Request body too large"},
+	{service.ErrServiceInvalidData, http.StatusBadRequest, "invalid_data", "Invalid request data"},
+	{utils.ErrUtilsInvalidMedia, http.StatusUnsupportedMediaType, "unsupported_media_type", "Unsupported media type"},
+	{utils.ErrUtilsEmptyBody, http.StatusBadRequest, "empty_body", "Request body is empty"},
+	{ErrIdempotencyInvalidKey, http.StatusBadRequest, "invalid_idempotency_key", "Invalid idempotency key"},
+	{ErrIdempotencyMismatch, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency key reused"},
+	{ErrIdempotencyInProgress, http.StatusConflict, "idempotency_key_in_progress", "Idempotency key in progress"},
+	{ErrUnauthorized, http.StatusUnauthorized, "unauthorized", "Unauthorized"},
+	{ErrForbidden, http.StatusForbidden, "forbidden", "Forbidden"},
+	{ErrRateLimited, http.StatusTooManyRequests, "rate_limited", "Too many requests"},
+	{ErrAPIKeyNotFound, http.StatusNotFound, "api_key_not_found", "API key not found"},
+	{ErrNotReady, http.StatusServiceUnavailable, "service_unavailable", "Service unavailable"},
+}
+
+// неизвестная ошибка
+var internalProblem = problemKind{status: http.StatusInternalServerError, code: "internal_error", title: "Internal server error"}
+
+// вид ошибки из 'problemKinds', нет подходящего -> 'internalProblem'
+func problemOf(err error) problemKind {
+	for _, kind := range problemKinds {
+		if errors.Is(err, kind.err) {
+			return kind
+		}
+	}
+
+	return internalProblem
+}
+
+// пишем 'Problem' для 'err' со статусом из 'problemKinds', текст внутренней ошибки - в лог
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	kind := problemOf(err)
+
+	if kind.status == http.StatusInternalServerError {
+		logging.FromContext(r.Context()).ErrorContext(r.Context(), "transport: writeProblem", "status", kind.status, "error", err)
+	}
+
+	respondProblem(w, r, kind, err)
+}
+
+// пишем 'Problem' вида 'kind' без записи в лог
+func respondProblem(w http.ResponseWriter, r *http.Request, kind problemKind, err error) {
+	problem := Problem{
+		Type:      ProblemTypePrefix + kind.code,
+		Title:     kind.title,
+		Status:    kind.status,
+		Code:      kind.code,
+		RequestID: RequestID(r.Context()),
+	}
+
+	if kind.status < http.StatusInternalServerError {
+		problem.Detail = err.Error()
+	}
+
+	var invalid *service.ValidationError
+	if errors.As(err, &invalid) {
+		problem.Errors = invalid.Fields
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(kind.status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		logging.FromContext(r.Context()).ErrorContext(r.Context(), "transport: writeProblem json.Encode", "error", err)
+	}
+}
@@ -0,0 +1,172 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
+)
+
+func Test_problemOf(t *testing.T) {
+	// коды - часть API, меняются только вместе с клиентами
+	testData := []struct {
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{db.ErrDBNotFound, http.StatusNotFound, "quote_not_found"},
+		{db.ErrDBEmpty, http.StatusNotFound, "quote_list_empty"},
+		{db.ErrDBAlreadyExists, http.StatusConflict, "quote_already_exists"},
+		{db.ErrDBRevisionMismatch, http.StatusPreconditionFailed, "revision_mismatch"},
+		{db.ErrDBBatchAborted, http.StatusUnprocessableEntity, "batch_aborted"},
+		{db.ErrDBInvalidQuery, http.StatusBadRequest, "invalid_search_query"},
+		{fmt.Errorf("%w: disk full", db.ErrDBWAL), http.StatusInternalServerError, "storage_error"},
+		{service.ErrServiceInvalidData, http.StatusBadRequest, "invalid_data"},
+		{&service.ValidationError{}, http.StatusBadRequest, "invalid_data"},
+		{utils.ErrUtilsInvalidMedia, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{utils.ErrUtilsEmptyBody, http.StatusBadRequest, "empty_body"},
+		{ErrIdempotencyInvalidKey, http.StatusBadRequest, "invalid_idempotency_key"},
+		{ErrIdempotencyMismatch, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+		{ErrIdempotencyInProgress, http.StatusConflict, "idempotency_key_in_progress"},
+		{errors.New("something else"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, test := range testData {
+		t.Run(test.expectedCode, func(t *testing.T) {
+			kind := problemOf(test.err)
+			if kind.status != test.expectedStatus || kind.code != test.expectedCode {
+				t.Errorf("problemOf {got}:{want} {%d %s}:{%d %s};", kind.status, kind.code, test.expectedStatus, test.expectedCode)
+			}
+		})
+	}
+}
+
+func Test_Problem(t *testing.T) {
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		contentType        string
+		requestID          string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedRequestID  string
+		expectedResponse   string
+	}{
+		{
+			title:              `not found with client request id`,
+			method:             http.MethodGet,
+			path:               `/quotes/1`,
+			requestID:          `client-id-1`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedRequestID:  `client-id-1`,
+			expectedResponse: `{"type":"urn:quotebook:problem:quote_not_found","title":"Quote not found","status":404,` +
+				`"detail":"quote not found","code":"quote_not_found","request_id":"client-id-1"}` + "\n",
+		},
+		{
+			title:              `validation errors with generated request id`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			contentType:        `application/json`,
+			requestID:          `bad id`,
+			datasForRequest:    `{"author":" ","quote":" ","year":3000}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedRequestID:  testRequestID,
+			expectedResponse: `{"type":"urn:quotebook:problem:invalid_data","title":"Invalid request data","status":400,` +
+				`"detail":"invalid data: author - must not be empty; quote - must not be empty; ` +
+				`year - must be between -3000 and the current year, except 0","code":"invalid_data","request_id":"test-request-id",` +
+				`"errors":[{"field":"author","message":"must not be empty"},{"field":"quote","message":"must not be empty"},` +
+				`{"field":"year","message":"must be between -3000 and the current year, except 0"}]}` + "\n",
+		},
+		{
+			title:              `wrong field type`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			contentType:        `application/json`,
+			datasForRequest:    `{"author":"Unknown","quote":"Typed","year":"1900"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedRequestID:  testRequestID,
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: year - invalid type string, expected int`,
+				service.FieldError{Field: "year", Message: "invalid type string, expected int"}),
+		},
+		{
+			title:              `unsupported media type`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			contentType:        `text/plain`,
+			datasForRequest:    `{"author":"Unknown","quote":"Plain"}`,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+			expectedRequestID:  testRequestID,
+			expectedResponse:   problemBody(utils.ErrUtilsInvalidMedia, utils.ErrUtilsInvalidMedia.Error()),
+		},
+		{
+			title:              `empty body`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			contentType:        `application/json`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedRequestID:  testRequestID,
+			expectedResponse:   problemBody(utils.ErrUtilsEmptyBody, utils.ErrUtilsEmptyBody.Error()),
+		},
+		{
+			title:              `too long client request id`,
+			method:             http.MethodGet,
+			path:               `/authors`,
+			requestID:          strings.Repeat("a", MaxRequestIDLength+1),
+			expectedStatusCode: http.StatusNotFound,
+			expectedRequestID:  testRequestID,
+			expectedResponse:   problemBody(db.ErrDBEmpty, `quote list is empty`),
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, bytes.NewBufferString(test.datasForRequest))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			if test.requestID != "" {
+				req.Header.Set(RequestIDHeader, test.requestID)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if id := w.Header().Get(RequestIDHeader); id != test.expectedRequestID {
+				t.Errorf("%s not equal {got}:{want} {%s}:{%s}", RequestIDHeader, id, test.expectedRequestID)
+			}
+
+			if w.Code >= http.StatusBadRequest {
+				if contentType := w.Header().Get("Content-Type"); contentType != ProblemContentType {
+					t.Errorf("Content-Type not equal {got}:{want} {%s}:{%s}", contentType, ProblemContentType)
+				}
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
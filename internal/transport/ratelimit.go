@@ -0,0 +1,263 @@
+// ограничение частоты запросов клиента (token bucket)
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// запросов клиента больше бюджета -> 429
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// заголовки бюджета клиента (draft-ietf-httpapi-ratelimit-headers)
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+)
+
+// токены корзины на момент 'last'
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// бюджет запросов на клиента: 'rate' запросов в секунду, не больше 'burst' подряд
+// наполнившаяся корзина удаляется - она не отличается от новой,
+// поэтому в памяти только клиенты последних 'burst'/'rate' секунд
+// бюджет меняется на ходу через 'SetLimit'
+type RateLimiter struct {
+	mu sync.Mutex
+
+	// 'rate' == 0 -> без ограничения
+	rate  float64
+	burst int
+
+	buckets map[string]*tokenBucket
+
+	sweepInterval time.Duration
+	nextSweep     time.Time
+
+	// источник времени, для тестов
+	now func() time.Time
+}
+
+// конструктор RateLimiter, 'rate' <= 0 -> без ограничения, 'burst' < 1 -> 1
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	l := &RateLimiter{buckets: make(map[string]*tokenBucket), now: time.Now}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+// новый бюджет, токены корзин сверх нового 'burst' отбрасываются
+// 'rate' <= 0 -> без ограничения, корзины удаляются
+func (l *RateLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rate <= 0 {
+		l.rate, l.burst = 0, 0
+		clear(l.buckets)
+		return
+	}
+
+	l.rate, l.burst = rate, max(burst, 1)
+
+	// время наполнения пустой корзины, но не чаще раза в секунду и не реже раза в минуту
+	fill := time.Duration(float64(l.burst) / rate * float64(time.Second))
+	l.sweepInterval = min(max(fill, time.Second), time.Minute)
+	l.nextSweep = time.Time{}
+}
+
+// решение по запросу клиента
+// 'Limit' == 0 -> ограничения нет
+// 'RetryAfter' - через сколько появится токен, если запрос отклонен
+// 'Reset' - через сколько корзина наполнится полностью
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// забираем токен из корзины клиента 'key'
+func (l *RateLimiter) Allow(key string) RateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate == 0 {
+		return RateLimitResult{Allowed: true}
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ex := l.buckets[key]
+	if !ex {
+		bucket = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = l.tokens(bucket, now)
+	bucket.last = now
+
+	result := RateLimitResult{Limit: l.burst}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - bucket.tokens)
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = l.duration(float64(l.burst) - bucket.tokens)
+
+	return result
+}
+
+// возвращаем токен, взятый 'Allow', корзина не больше 'burst'
+// корзины нет (удалена или бюджет сменился) -> ничего не делаем
+func (l *RateLimiter) Refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if bucket, ex := l.buckets[key]; ex && l.rate != 0 {
+		bucket.tokens = min(float64(l.burst), bucket.tokens+1)
+	}
+}
+
+// токены корзины на момент 'now'
+func (l *RateLimiter) tokens(bucket *tokenBucket, now time.Time) float64 {
+	return min(float64(l.burst), bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+}
+
+// время, за которое наберется 'tokens' токенов
+func (l *RateLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// удаляем наполнившиеся корзины, вызывается под 'mu'
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+
+	for key, bucket := range l.buckets {
+		if l.tokens(bucket, now) >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.nextSweep = now.Add(l.sweepInterval)
+}
+
+// обертка с бюджетом 'limiter' на клиента, бюджет без ограничения -> без заголовков
+// клиент - 'clientKey': вид учетных данных и субъект (после 'Authorize'), без аутентификации - адрес из 'ClientIP'
+// заголовки 'RateLimit-*' в каждом ответе, бюджет исчерпан -> 429 с "Retry-After"
+func RateLimit(limiter *RateLimiter, trusted []netip.Prefix) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			result := limiter.Allow(clientKey(r, trusted))
+			if result.Limit == 0 {
+				next(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Set(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+			header.Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+			header.Set(RateLimitResetHeader, seconds(result.Reset))
+
+			if !result.Allowed {
+				header.Set("Retry-After", seconds(result.RetryAfter))
+				writeProblem(w, r, fmt.Errorf("%w: retry after %s seconds", ErrRateLimited, seconds(result.RetryAfter)))
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
+
+// бюджет неудачных аутентификаций на адрес клиента ('ClientIP') перед 'authorize'
+// попытка забирает токен, прошедшая 'authorize' его возвращает - расходуются только 401 и 403
+// бюджет исчерпан -> 429 с "Retry-After", учетные данные не проверяются
+// так подбор ключа ограничен до того, как клиент известен и попадет под свой 'RateLimit'
+func LimitAuthFailures(limiter *RateLimiter, trusted []netip.Prefix, authorize Middleware) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		passed := func(key string) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				limiter.Refund(key)
+				next(w, r)
+			}
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + ClientIP(r, trusted).String()
+
+			result := limiter.Allow(key)
+			if result.Limit != 0 && !result.Allowed {
+				w.Header().Set("Retry-After", seconds(result.RetryAfter))
+				writeProblem(w, r, fmt.Errorf("%w: too many failed authentications, retry after %s seconds", ErrRateLimited, seconds(result.RetryAfter)))
+				return
+			}
+
+			authorize(passed(key))(w, r)
+		}
+	}
+}
+
+// целые секунды с округлением вверх
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// адрес клиента: 'RemoteAddr', а если запрос пришел от доверенного прокси из 'trusted' -
+// первый справа адрес "X-Forwarded-For" не из 'trusted'
+// адреса левее недоверенного клиент мог подставить сам, поэтому не используются
+func ClientIP(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	addr = addr.Unmap()
+
+	if !isTrusted(addr, trusted) {
+		return addr
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			// цепочку испортил кто-то до прокси - дальше ей не верим
+			return addr
+		}
+		addr = hop.Unmap()
+		if !isTrusted(addr, trusted) {
+			return addr
+		}
+	}
+
+	return addr
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,435 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+func Test_RateLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, 2)
+	limiter.now = func() time.Time { return now }
+
+	testData := []struct {
+		title          string
+		advance        time.Duration
+		key            string
+		expectedResult RateLimitResult
+	}{
+		{
+			title:          `first request`,
+			key:            `ip:192.0.2.1`,
+			expectedResult: RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: 500 * time.Millisecond},
+		},
+		{
+			title:          `burst`,
+			key:            `ip:192.0.2.1`,
+			expectedResult: RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: time.Second},
+		},
+		{
+			title:          `over budget`,
+			key:            `ip:192.0.2.1`,
+			expectedResult: RateLimitResult{Limit: 2, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: time.Second},
+		},
+		{
+			title:          `other client`,
+			key:            `ip:192.0.2.2`,
+			expectedResult: RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: 500 * time.Millisecond},
+		},
+		{
+			title:          `refill`,
+			advance:        500 * time.Millisecond,
+			key:            `ip:192.0.2.1`,
+			expectedResult: RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: time.Second},
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			now = now.Add(test.advance)
+
+			if got := limiter.Allow(test.key); got != test.expectedResult {
+				t.Errorf("result {got}:{want} {%+v}:{%+v};", got, test.expectedResult)
+			}
+		})
+	}
+
+	// обе корзины наполнились -> удаляются при следующем запросе
+	now = now.Add(2 * time.Second)
+	limiter.Allow(`ip:192.0.2.3`)
+	if len(limiter.buckets) != 1 {
+		t.Errorf("buckets after sweep {got}:{want} {%d}:{1};", len(limiter.buckets))
+	}
+
+	// бюджет меняется на ходу: без ограничения и снова с ним
+	limiter.SetLimit(0, 0)
+	if got := limiter.Allow(`ip:192.0.2.3`); got != (RateLimitResult{Allowed: true}) || len(limiter.buckets) != 0 {
+		t.Errorf("result without limit {got}:{want} {%+v %d}:{%+v 0};", got, len(limiter.buckets), RateLimitResult{Allowed: true})
+	}
+
+	limiter.SetLimit(1, 1)
+	limiter.Allow(`ip:192.0.2.3`)
+	expected := RateLimitResult{Limit: 1, RetryAfter: time.Second, Reset: time.Second}
+	if got := limiter.Allow(`ip:192.0.2.3`); got != expected {
+		t.Errorf("result after SetLimit {got}:{want} {%+v}:{%+v};", got, expected)
+	}
+
+	// возвращенный токен снова доступен, но не сверх 'burst'
+	limiter.Refund(`ip:192.0.2.3`)
+	limiter.Refund(`ip:192.0.2.3`)
+	expected = RateLimitResult{Allowed: true, Limit: 1, Remaining: 0, Reset: time.Second}
+	if got := limiter.Allow(`ip:192.0.2.3`); got != expected {
+		t.Errorf("result after Refund {got}:{want} {%+v}:{%+v};", got, expected)
+	}
+}
+
+func Test_ClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.0.2.10/32")}
+
+	testData := []struct {
+		title        string
+		remoteAddr   string
+		forwarded    []string
+		expectedAddr string
+	}{
+		{
+			title:        `direct client`,
+			remoteAddr:   `198.51.100.7:4312`,
+			expectedAddr: `198.51.100.7`,
+		},
+		{
+			title:        `forwarded by untrusted client`,
+			remoteAddr:   `198.51.100.7:4312`,
+			forwarded:    []string{`203.0.113.1`},
+			expectedAddr: `198.51.100.7`,
+		},
+		{
+			title:        `trusted proxy`,
+			remoteAddr:   `10.0.0.5:4312`,
+			forwarded:    []string{`203.0.113.1`},
+			expectedAddr: `203.0.113.1`,
+		},
+		{
+			title:        `spoofed left part`,
+			remoteAddr:   `10.0.0.5:4312`,
+			forwarded:    []string{`1.1.1.1, 203.0.113.1`, `192.0.2.10`},
+			expectedAddr: `203.0.113.1`,
+		},
+		{
+			title:        `broken chain`,
+			remoteAddr:   `10.0.0.5:4312`,
+			forwarded:    []string{`203.0.113.1, unknown`},
+			expectedAddr: `10.0.0.5`,
+		},
+		{
+			title:        `trusted proxy without header`,
+			remoteAddr:   `10.0.0.5:4312`,
+			expectedAddr: `10.0.0.5`,
+		},
+		{
+			title:        `ipv4 mapped ipv6`,
+			remoteAddr:   `[::ffff:10.0.0.5]:4312`,
+			forwarded:    []string{`2001:db8::1`},
+			expectedAddr: `2001:db8::1`,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, `/quotes`, nil)
+			req.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := ClientIP(req, trusted).String(); got != test.expectedAddr {
+				t.Errorf("client ip {got}:{want} {%s}:{%s};", got, test.expectedAddr)
+			}
+		})
+	}
+}
+
+func Test_RateLimit(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	r := NewTransport(&config.Config{
+		ServerHost:          "not host",
+		ServerPort:          "not port",
+		RateLimitRead:       1,
+		RateLimitReadBurst:  2,
+		RateLimitWrite:      1,
+		RateLimitWriteBurst: 1,
+	}, WithRequestIDGenerator(func() string { return testRequestID }))
+	r.Routes(service.NewService(store, nil))
+
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		remoteAddr         string
+		body               string
+		expectedStatusCode int
+		expectedRemaining  string
+		expectedResponse   string
+	}{
+		{
+			title:              `write`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			remoteAddr:         `198.51.100.7:4312`,
+			body:               `{"author":"Seneca","quote":"While we teach, we learn"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedRemaining:  "0",
+		},
+		{
+			title:              `write over budget`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			remoteAddr:         `198.51.100.7:4312`,
+			body:               `{"author":"Epictetus","quote":"Wealth consists in having few wants"}`,
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedRemaining:  "0",
+			expectedResponse:   problemBody(ErrRateLimited, `rate limit exceeded: retry after 1 seconds`),
+		},
+		{
+			title:              `delete shares write budget`,
+			method:             http.MethodDelete,
+			path:               `/quotes/1`,
+			remoteAddr:         `198.51.100.7:4312`,
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedRemaining:  "0",
+		},
+		{
+			title:              `read budget is separate`,
+			method:             http.MethodGet,
+			path:               `/quotes/1`,
+			remoteAddr:         `198.51.100.7:4312`,
+			expectedStatusCode: http.StatusOK,
+			expectedRemaining:  "1",
+		},
+		{
+			title:              `other client`,
+			method:             http.MethodDelete,
+			path:               `/quotes/1`,
+			remoteAddr:         `198.51.100.8:4312`,
+			expectedStatusCode: http.StatusOK,
+			expectedRemaining:  "0",
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}, body - {%s};", w.Code, test.expectedStatusCode, w.Body.String())
+			}
+
+			if test.expectedResponse != "" && w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+
+			if remaining := w.Header().Get(RateLimitRemainingHeader); remaining != test.expectedRemaining {
+				t.Errorf("%s {got}:{want} {%s}:{%s};", RateLimitRemainingHeader, remaining, test.expectedRemaining)
+			}
+
+			retryAfter := w.Header().Get("Retry-After")
+			if w.Code == http.StatusTooManyRequests && retryAfter != "1" {
+				t.Errorf("Retry-After {got}:{want} {%s}:{1};", retryAfter)
+			}
+			if w.Code != http.StatusTooManyRequests && retryAfter != "" {
+				t.Errorf("Retry-After {got}:{want} {%s}:{};", retryAfter)
+			}
+		})
+	}
+}
+
+func Test_RateLimit_Subjects(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	keys, err := NewAPIKeys([]APIKey{{Name: "alice", Hash: HashAPIKey("alice-key"), Scopes: []string{ScopeWrite}}})
+	if err != nil {
+		t.Fatalf("NewAPIKeys error - {%v};", err)
+	}
+
+	now := time.Now()
+	verifier, err := auth.NewJWTVerifier(auth.WithHMACKey(testJWTKey), auth.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("auth.NewJWTVerifier error - {%v};", err)
+	}
+	// 'sub' токена совпадает с именем ключа API
+	token := testToken(t, map[string]any{"sub": "alice", "scope": "quotes:write", "exp": now.Add(time.Hour).Unix()})
+
+	r := NewTransport(&config.Config{
+		ServerHost:          "not host",
+		ServerPort:          "not port",
+		RateLimitWrite:      1,
+		RateLimitWriteBurst: 1,
+	}, WithRequestIDGenerator(func() string { return testRequestID }), WithAPIKeys(keys), WithBearerTokens(verifier))
+	r.Routes(service.NewService(store, nil))
+
+	// запросы выполняются по порядку с одного адреса
+	testData := []struct {
+		title              string
+		apiKey             string
+		token              string
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			title:              `api key`,
+			apiKey:             `alice-key`,
+			body:               `{"author":"Seneca","quote":"While we teach, we learn"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			title:              `api key over budget`,
+			apiKey:             `alice-key`,
+			body:               `{"author":"Epictetus","quote":"Wealth consists in having few wants"}`,
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			title:              `token with same subject has own budget`,
+			token:              token,
+			body:               `{"author":"Epictetus","quote":"Wealth consists in having few wants"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, `/quotes`, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+			if test.apiKey != "" {
+				req.Header.Set(APIKeyHeader, test.apiKey)
+			}
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}, body - {%s};", w.Code, test.expectedStatusCode, w.Body.String())
+			}
+		})
+	}
+}
+
+func Test_LimitAuthFailures(t *testing.T) {
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	keys, err := NewAPIKeys([]APIKey{
+		{Name: "reader", Hash: HashAPIKey("reader-key"), Scopes: []string{ScopeRead}},
+	})
+	if err != nil {
+		t.Fatalf("NewAPIKeys error - {%v};", err)
+	}
+
+	r := NewTransport(&config.Config{
+		ServerHost:         "not host",
+		ServerPort:         "not port",
+		RateLimitAuth:      1,
+		RateLimitAuthBurst: 2,
+	}, WithRequestIDGenerator(func() string { return testRequestID }), WithAPIKeys(keys))
+	r.Routes(service.NewService(store, nil))
+
+	// время стоит - бюджет не восстанавливается
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.authLimit.now = func() time.Time { return now }
+
+	// запросы выполняются по порядку
+	testData := []struct {
+		title              string
+		method             string
+		remoteAddr         string
+		key                string
+		expectedStatusCode int
+	}{
+		{
+			title:              `wrong key`,
+			method:             http.MethodGet,
+			remoteAddr:         `198.51.100.7:4312`,
+			key:                `guess-1`,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			title:              `valid key does not spend budget`,
+			method:             http.MethodGet,
+			remoteAddr:         `198.51.100.7:4312`,
+			key:                `reader-key`,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			title:              `missing scope spends budget`,
+			method:             http.MethodDelete,
+			remoteAddr:         `198.51.100.7:4312`,
+			key:                `reader-key`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			title:              `budget exhausted`,
+			method:             http.MethodGet,
+			remoteAddr:         `198.51.100.7:4312`,
+			key:                `guess-2`,
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			title:              `valid key from exhausted address`,
+			method:             http.MethodGet,
+			remoteAddr:         `198.51.100.7:4312`,
+			key:                `reader-key`,
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			title:              `other address`,
+			method:             http.MethodGet,
+			remoteAddr:         `198.51.100.8:4312`,
+			key:                `guess-3`,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, `/quotes/1`, nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set(APIKeyHeader, test.key)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}, body - {%s};", w.Code, test.expectedStatusCode, w.Body.String())
+			}
+
+			retryAfter := w.Header().Get("Retry-After")
+			if w.Code == http.StatusTooManyRequests && retryAfter != "1" {
+				t.Errorf("Retry-After {got}:{want} {%s}:{1};", retryAfter)
+			}
+		})
+	}
+}
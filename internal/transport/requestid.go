@@ -0,0 +1,62 @@
+// ID запроса для связи ответа с логами
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
+)
+
+// заголовок запроса и ответа с ID запроса
+const RequestIDHeader = "X-Request-ID"
+
+// наибольшая длина ID от клиента
+const MaxRequestIDLength = 128
+
+// ID запроса из 'ctx', нет -> пустая строка
+func RequestID(ctx context.Context) string {
+	return logging.RequestID(ctx)
+}
+
+// случайный ID из 16 байт в hex
+func newRequestID() string {
+	var buf [16]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+// обертка для обработчика: ID из заголовка 'X-Request-ID' или новый от 'generate',
+// ID кладем в заголовок ответа, ID и 'logger' - в контекст запроса
+func RequestIDs(generate func() string, logger *slog.Logger) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = generate()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			ctx := logging.NewContext(logging.WithRequestID(r.Context(), id), logger)
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// ID от клиента - печатные символы ASCII без пробелов, от 1 до 'MaxRequestIDLength'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= 0x20 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
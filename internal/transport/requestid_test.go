@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
+)
+
+func Test_RequestIDInLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger, err := logging.New(buf, slog.LevelDebug, logging.FormatJSON)
+	if err != nil {
+		t.Fatalf("logging.New error - {%v};", err)
+	}
+
+	store, err := newTestStore(db.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+	usecase := service.NewService(store, logger)
+	r := newTestTransport(usecase, WithLogger(logger))
+
+	req, err := http.NewRequest(http.MethodDelete, `/quotes/1`, nil)
+	if err != nil {
+		t.Fatalf("http.NewRequest error - {%v};", err)
+	}
+	req.Header.Set(RequestIDHeader, "req-1")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, http.StatusNotFound)
+	}
+
+	// все записи запроса - из транспорта и сервиса - с его ID
+	expected := []string{"service: DeleteQuote", "transport: access"}
+	got := []string{}
+
+	scan := bufio.NewScanner(buf)
+	for scan.Scan() {
+		var record struct {
+			Msg       string `json:"msg"`
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(scan.Bytes(), &record); err != nil {
+			t.Fatalf("json.Unmarshal error - {%v};", err)
+		}
+		if record.RequestID != "req-1" {
+			t.Errorf("%s request_id {got}:{want} {%s}:{req-1};", record.Msg, record.RequestID)
+		}
+		got = append(got, record.Msg)
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("records {got}:{want} {%v}:{%v};", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("record %d {got}:{want} {%s}:{%s};", i, got[i], expected[i])
+		}
+	}
+}
@@ -3,59 +3,144 @@ package transport
 
 import (
 	"errors"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/logging"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
 	"github.com/Ekvo/go-map-rwmu-mux/pkg/utils"
 )
 
 // добавление новой цитаты
-// все хорошо -> возвращаем struct{}{}
+// все хорошо -> возвращаем сохраненную 'QuoteResponse', "Location" с путем к цитате и "ETag"
 func SaveOneQuote(usecase service.AddQuote) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("transport: SaveOneQuote member - {%s}, path - {%s};", r.Method, r.URL.Path)
-
 		deserialize := service.NewQuoteDeserializer()
 		if err := deserialize.Decode(r); err != nil {
-			utils.EncodeJSON(w, http.StatusBadRequest, utils.NewCommonError(err))
+			writeProblem(w, r, err)
 			return
 		}
 
-		if err := usecase.CreateQuote(r.Context(), deserialize.Model()); err != nil {
-			status := 0
-			if errors.Is(err, db.ErrDBAlreadyExists) {
-				status = http.StatusConflict
-			} else {
-				status = http.StatusInternalServerError
-			}
-			utils.EncodeJSON(w, status, utils.NewCommonError(err))
+		quoteResponse, err := usecase.CreateQuote(r.Context(), deserialize.Model())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", "/quotes/"+quoteResponse.ID)
+		w.Header().Set("ETag", quoteETag(quoteResponse.Revision))
+		utils.EncodeJSON(w, http.StatusCreated, quoteResponse)
+	}
+}
+
+// получение цитаты по id из пути url
+// нет ошибок -> возвращаем 'QuoteResponse' и "ETag" по ревизии цитаты,
+// "If-None-Match" совпал -> 304 без тела
+func RetrieveQuote(usecase service.FindQuote) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		quoteResponse, err := usecase.ReadQuote(r.Context(), id)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		if notModified(w, r, quoteETag(quoteResponse.Revision)) {
+			return
+		}
+
+		utils.EncodeJSON(w, http.StatusOK, quoteResponse)
+	}
+}
+
+// создание пакета цитат из тела {"quotes":[...]}, параметр url 'atomic=true' - все или ничего
+// нет ошибок -> возвращаем 'BatchResponse' со статусом каждой цитаты,
+// пакет "все или ничего" отменен -> 422 и 'BatchResponse'
+func SaveListOfQuote(usecase service.BatchQuote) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deserialize := service.NewBatchDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		batchResponse, err := usecase.CreateQuotes(r.Context(), deserialize.Items(), deserialize.Atomic)
+		batchReply(w, r, batchResponse, err, http.StatusCreated)
+	}
+}
+
+// удаление пакета цитат по параметрам url 'ids=1,2,3' и 'atomic=true' - все или ничего
+// нет ошибок -> возвращаем 'BatchResponse' со статусом каждого ID,
+// пакет "все или ничего" отменен -> 422 и 'BatchResponse'
+func ExpelListOfQuote(usecase service.BatchQuote) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deserialize := service.NewIDsDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
 			return
 		}
 
-		utils.EncodeJSON(w, http.StatusCreated, struct{}{})
+		batchResponse, err := usecase.DeleteQuotes(r.Context(), deserialize.IDs, deserialize.Atomic)
+		batchReply(w, r, batchResponse, err, http.StatusOK)
+	}
+}
+
+// общая часть пакетных запросов
+// статус и код элемента по ошибке, как в 'writeProblem', 'success' - для примененного элемента
+func batchReply(
+	w http.ResponseWriter,
+	r *http.Request,
+	batchResponse *service.BatchResponse,
+	err error,
+	success int) {
+	if err != nil && !errors.Is(err, db.ErrDBBatchAborted) {
+		writeProblem(w, r, err)
+		return
+	}
+
+	for i := range batchResponse.Results {
+		result := &batchResponse.Results[i]
+		switch {
+		case result.Err == nil:
+			result.Status = success
+		case errors.Is(result.Err, db.ErrDBBatchAborted):
+			// элемент верный, но не применен из-за других элементов
+			result.Status = http.StatusFailedDependency
+			result.Code = problemOf(result.Err).code
+		default:
+			kind := problemOf(result.Err)
+			result.Status, result.Code = kind.status, kind.code
+		}
+	}
+
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusUnprocessableEntity
 	}
+
+	utils.EncodeJSON(w, status, batchResponse)
 }
 
 // ищем случайную цитату
-// нет ошибок -> возвращаем 'QuoteResponse'
+// нет ошибок -> возвращаем 'QuoteResponse' и "ETag" по ревизии цитаты,
+// "If-None-Match" совпал -> 304 без тела
 func RetrieveRandomQuote(usecase service.FindRandomQuote) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("transport: RetrieveRandomQuote member - {%s}, path - {%s};", r.Method, r.URL.Path)
-
 		quoteResponse, err := usecase.ReadRandomQuote(r.Context())
 		if err != nil {
-			status := 0
-			if errors.Is(err, db.ErrDBEmpty) {
-				status = http.StatusNotFound
-			} else {
-				status = http.StatusInternalServerError
-			}
+			writeProblem(w, r, err)
+			return
+		}
 
-			utils.EncodeJSON(w, status, utils.NewCommonError(err))
+		if notModified(w, r, quoteETag(quoteResponse.Revision)) {
 			return
 		}
 
@@ -69,87 +154,399 @@ func RetrieveRandomQuote(usecase service.FindRandomQuote) http.HandlerFunc {
 // запускае логику 'ReadQuoteListByAuthor'
 //
 // 2. нет параметра из url "author"
-// запускае логику 'ReadQuoteList'
+// запускае логику 'ReadQuoteList' со страницей из 'limit', 'after_id', 'order',
+// фильтром по метаданным и тегам 'tag', 'tag_mode'
 //
-// нет ошибок -> возвращаем '[]QuoteResponse'
+// нет ошибок -> возвращаем '[]QuoteResponse' по автору или 'QuotePageResponse' и "ETag" по ревизии хранилища,
+// "If-None-Match" совпал -> 304 без тела
 func RetrieveListOfQuote(usecase service.FindList) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("transport: RetrieveListOfQuote member - {%s}, path - {%s};", r.Method, r.URL.Path)
-
 		ctx := r.Context()
 		param := r.URL.Query()
 
 		if _, ex := param["author"]; ex {
 			author := strings.TrimSpace(param.Get("author"))
 			if author == "" {
-				utils.EncodeJSON(w, http.StatusBadRequest, utils.NewCommonError(service.ErrServiceInvalidData))
+				writeProblem(w, r, service.ErrServiceInvalidData)
 				return
 			}
 
 			quotesResponse, err := usecase.ReadQuoteListByAuthor(ctx, author)
 			if err != nil {
-				status := 0
-				if errors.Is(err, db.ErrDBNotFound) {
-					status = http.StatusNotFound
-				} else {
-					status = http.StatusInternalServerError
-				}
-
-				utils.EncodeJSON(w, status, utils.NewCommonError(err))
+				writeProblem(w, r, err)
+				return
+			}
+
+			if notModified(w, r, listETag(quotesResponse.Revision)) {
 				return
 			}
 
-			utils.EncodeJSON(w, http.StatusOK, quotesResponse)
+			utils.EncodeJSON(w, http.StatusOK, quotesResponse.Quotes)
 			return
 		}
 
-		quotesResponse, err := usecase.ReadQuoteList(ctx)
+		deserialize := service.NewPageDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		pageResponse, err := usecase.ReadQuoteList(ctx, deserialize.Query())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		if notModified(w, r, listETag(pageResponse.Revision)) {
+			return
+		}
+
+		utils.EncodeJSON(w, http.StatusOK, pageResponse)
+	}
+}
+
+// полнотекстовый поиск по параметру url "q"
+// слова через пробел или 'AND' - все должны быть в цитате, 'OR' - любая из групп,
+// фраза - в двойных кавычках
+// нет ошибок -> возвращаем 'SearchResponse'
+func SearchListOfQuote(usecase service.SearchQuote) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		searchResponse, err := usecase.SearchQuotes(r.Context(), r.URL.Query().Get("q"))
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		utils.EncodeJSON(w, http.StatusOK, searchResponse)
+	}
+}
+
+// загрузка цитат из тела запроса: JSON Lines, CSV или массив JSON
+// параметры url: 'format', 'atomic=true' - все или ничего
+// нет ошибок -> возвращаем 'ImportResponse' с результатом каждой записи,
+// импорт "все или ничего" отменен -> 422 и 'ImportResponse'
+func ImportListOfQuote(usecase service.BulkQuote) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deserialize := service.NewBulkDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		if r.Body == nil {
+			writeProblem(w, r, utils.ErrUtilsEmptyBody)
+			return
+		}
+
+		importResponse, err := usecase.ImportQuotes(r.Context(), deserialize.Format, r.Body, deserialize.Atomic)
 		if err != nil {
-			status := 0
-			if errors.Is(err, db.ErrDBEmpty) {
-				status = http.StatusNotFound
-			} else {
-				status = http.StatusInternalServerError
+			// импорт отменен -> результат каждой записи, остальные ошибки -> 'Problem'
+			if errors.Is(err, db.ErrDBBatchAborted) {
+				utils.EncodeJSON(w, http.StatusUnprocessableEntity, importResponse)
+				return
 			}
+			writeProblem(w, r, err)
+			return
+		}
 
-			utils.EncodeJSON(w, status, utils.NewCommonError(err))
+		utils.EncodeJSON(w, http.StatusOK, importResponse)
+	}
+}
+
+// выгрузка всех цитат потоком, параметр url 'format'
+// ответ начинается до чтения цитат -> ошибка во время выгрузки пишется в лог и обрывает соединение,
+// чтобы клиент не принял часть выгрузки за целую
+// срок записи 'writeTimeout' (0 -> без срока) отсчитывается заново для каждой части
+func ExportListOfQuote(usecase service.BulkQuote, writeTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deserialize := service.NewBulkDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
 			return
 		}
 
-		utils.EncodeJSON(w, http.StatusOK, quotesResponse)
+		cw := newChunkWriter(w, writeTimeout)
+
+		w.Header().Set("Content-Type", deserialize.Format.ContentType())
+		w.WriteHeader(http.StatusOK)
+
+		if err := usecase.ExportQuotes(r.Context(), deserialize.Format, cw); err != nil {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "transport: ExportListOfQuote", "error", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// ответ выгрузки: после каждой части ('Flush') срок записи продлевается на 'timeout'
+// иначе 'WriteTimeout' сервера обрывает большую выгрузку
+type chunkWriter struct {
+	http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func newChunkWriter(w http.ResponseWriter, timeout time.Duration) *chunkWriter {
+	cw := &chunkWriter{ResponseWriter: w, rc: http.NewResponseController(w), timeout: timeout}
+	cw.extend()
+	return cw
+}
+
+func (cw *chunkWriter) Flush() {
+	cw.rc.Flush()
+	cw.extend()
+}
+
+// без срока или 'http.ErrNotSupported' (не сервер 'net/http') -> срок не меняется
+func (cw *chunkWriter) extend() {
+	if cw.timeout > 0 {
+		cw.rc.SetWriteDeadline(time.Now().Add(cw.timeout))
 	}
 }
 
 // удаление цитаты по id полученого из пути url
+// "If-Match" не совпал с ETag цитаты -> 412
 func ExpelQuote(usecase service.RemoveQuote) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("transport: ExpelQuote member - {%s}, path - {%s};", r.Method, r.URL.Path)
+		id, err := pathID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
 
-		idStr := r.PathValue("id")
-		id, err := strconv.ParseUint(idStr, 10, 64)
+		revision, err := ifMatch(r)
 		if err != nil {
-			log.Printf("transport: ExpelQuote index - {%s} not numeric;", idStr)
-			utils.EncodeJSON(w, http.StatusBadRequest, utils.NewCommonError(service.ErrServiceInvalidData))
+			writeProblem(w, r, err)
 			return
 		}
-		if id == 0 {
-			log.Print("transport: ExpelQuote index is zero")
-			utils.EncodeJSON(w, http.StatusBadRequest, utils.NewCommonError(service.ErrServiceInvalidData))
+
+		if err := usecase.DeleteQuote(r.Context(), id, revision); err != nil {
+			writeProblem(w, r, err)
 			return
 		}
 
-		if err := usecase.DeleteQuote(r.Context(), uint(id)); err != nil {
-			status := 0
-			if errors.Is(err, db.ErrDBNotFound) {
-				status = http.StatusNotFound
-			} else {
-				status = http.StatusInternalServerError
-			}
+		utils.EncodeJSON(w, http.StatusOK, struct{}{})
+	}
+}
 
-			utils.EncodeJSON(w, status, utils.NewCommonError(err))
+// полная замена цитаты по id из пути url
+// нет ошибок -> возвращаем измененную 'QuoteResponse'
+func ReplaceQuote(usecase service.ChangeQuote) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeProblem(w, r, err)
 			return
 		}
 
-		utils.EncodeJSON(w, http.StatusOK, struct{}{})
+		deserialize := service.NewQuoteDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		updateQuote(w, r, usecase, id, deserialize.Patch())
+	}
+}
+
+// частичное изменение цитаты по id из пути url
+// нет ошибок -> возвращаем измененную 'QuoteResponse'
+func AmendQuote(usecase service.ChangeQuote) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		deserialize := service.NewQuotePatchDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		updateQuote(w, r, usecase, id, deserialize.Patch())
+	}
+}
+
+// получение всех тегов с количеством цитат
+// нет ошибок -> возвращаем '[]TagResponse'
+func RetrieveListOfTag(usecase service.FindTagList) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tagsResponse, err := usecase.ReadTagList(r.Context())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		utils.EncodeJSON(w, http.StatusOK, tagsResponse)
 	}
 }
+
+// добавление тегов к цитате по id из пути url
+// нет ошибок -> возвращаем измененную 'QuoteResponse'
+func AttachQuoteTags(usecase service.ChangeTags) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		deserialize := service.NewTagsDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		quoteResponse, err := usecase.AttachTags(r.Context(), id, deserialize.Tags)
+		changeTags(w, r, quoteResponse, err)
+	}
+}
+
+// удаление тега {tag} у цитаты по id из пути url
+// нет ошибок -> возвращаем измененную 'QuoteResponse'
+func DetachQuoteTag(usecase service.ChangeTags) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		tag := strings.TrimSpace(r.PathValue("tag"))
+		if tag == "" {
+			writeProblem(w, r, service.ErrServiceInvalidData)
+			return
+		}
+
+		quoteResponse, err := usecase.DetachTags(r.Context(), id, []string{tag})
+		changeTags(w, r, quoteResponse, err)
+	}
+}
+
+// получение страницы авторов с количеством цитат
+// параметры url: 'limit', 'after', 'prefix' - начало имени для автодополнения
+// нет ошибок -> возвращаем 'AuthorPageResponse'
+func RetrieveListOfAuthor(usecase service.FindAuthor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deserialize := service.NewAuthorPageDeserializer()
+		if err := deserialize.Decode(r); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		pageResponse, err := usecase.ReadAuthorList(r.Context(), deserialize.Query())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		utils.EncodeJSON(w, http.StatusOK, pageResponse)
+	}
+}
+
+// список цитат автора {name} из пути url
+// нет ошибок -> возвращаем '[]QuoteResponse' и "ETag" по ревизии хранилища, "If-None-Match" совпал -> 304 без тела
+func RetrieveQuotesOfAuthor(usecase service.FindListQuoteByAuthor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		author, err := pathAuthor(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		quotesResponse, err := usecase.ReadQuoteListByAuthor(r.Context(), author)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		if notModified(w, r, listETag(quotesResponse.Revision)) {
+			return
+		}
+
+		utils.EncodeJSON(w, http.StatusOK, quotesResponse.Quotes)
+	}
+}
+
+// случайная цитата автора {name} из пути url
+// нет ошибок -> возвращаем 'QuoteResponse'
+func RetrieveRandomQuoteOfAuthor(usecase service.FindAuthor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		author, err := pathAuthor(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		quoteResponse, err := usecase.ReadRandomQuoteByAuthor(r.Context(), author)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		utils.EncodeJSON(w, http.StatusOK, quoteResponse)
+	}
+}
+
+// общая часть добавления и удаления тегов, возвращаем "ETag" новой ревизии цитаты
+func changeTags(w http.ResponseWriter, r *http.Request, quoteResponse *service.QuoteResponse, err error) {
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", quoteETag(quoteResponse.Revision))
+	utils.EncodeJSON(w, http.StatusOK, quoteResponse)
+}
+
+// общая часть PUT и PATCH
+// "If-Match" не совпал с ETag цитаты -> 412, иначе возвращаем "ETag" новой ревизии
+func updateQuote(
+	w http.ResponseWriter,
+	r *http.Request,
+	usecase service.ChangeQuote,
+	id uint,
+	patch db.QuotePatch) {
+	revision, err := ifMatch(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	quoteResponse, err := usecase.UpdateQuote(r.Context(), id, revision, patch)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", quoteETag(quoteResponse.Revision))
+	utils.EncodeJSON(w, http.StatusOK, quoteResponse)
+}
+
+// получение имени автора из пути url, имя не пустое
+func pathAuthor(r *http.Request) (string, error) {
+	author := strings.TrimSpace(r.PathValue("name"))
+	if author == "" {
+		logging.FromContext(r.Context()).DebugContext(r.Context(), "transport: pathAuthor name is empty")
+		return "", service.ErrServiceInvalidData
+	}
+
+	return author, nil
+}
+
+// получение ID цитаты из пути url, ID - положительное число
+func pathID(r *http.Request) (uint, error) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		logging.FromContext(r.Context()).DebugContext(r.Context(), "transport: pathID index not numeric", "id", idStr)
+		return 0, service.ErrServiceInvalidData
+	}
+	if id == 0 {
+		logging.FromContext(r.Context()).DebugContext(r.Context(), "transport: pathID index is zero")
+		return 0, service.ErrServiceInvalidData
+	}
+
+	return uint(id), nil
+}
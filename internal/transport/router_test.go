@@ -3,10 +3,14 @@ package transport
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/db"
@@ -31,43 +35,93 @@ var quotesData = []model.Quote{
 	},
 }
 
+// хранилище для тестов
+// время создания и изменения нулевое -> не попадает в ответ
+func newTestStore(opts ...db.Option) (db.Store, error) {
+	opts = append([]db.Option{db.WithClock(func() time.Time { return time.Time{} })}, opts...)
+	return db.NewProvider(opts...)
+}
+
+// ID запроса в тестах
+const testRequestID = "test-request-id"
+
+// транспорт для тестов с маршрутами и постоянным ID запроса
+func newTestTransport(usecase service.ServiceQuote, opts ...Option) Transport {
+	opts = append([]Option{WithRequestIDGenerator(func() string { return testRequestID })}, opts...)
+
+	r := NewTransport(&config.Config{ServerHost: "not host", ServerPort: "not port"}, opts...)
+	r.Routes(usecase)
+
+	return r
+}
+
+// ожидаемое тело 'Problem' для ошибки 'err' с текстом 'detail'
+func problemBody(err error, detail string, fields ...service.FieldError) string {
+	kind := problemOf(err)
+
+	data, _ := json.Marshal(Problem{
+		Type:      ProblemTypePrefix + kind.code,
+		Title:     kind.title,
+		Status:    kind.status,
+		Detail:    detail,
+		Code:      kind.code,
+		RequestID: testRequestID,
+		Errors:    fields,
+	})
+
+	return string(data) + "\n"
+}
+
 func Test_SaveOneQuote(t *testing.T) {
 	testData := []struct {
 		title              string
 		datasForRequest    string
 		expectedStatusCode int
 		expectedResponse   string
+		expectedLocation   string
 	}{
 		{
 			title:              `valid add quote`,
 			datasForRequest:    `{"author":"William James","quote":"The greatest weapon against stress is our ability to choose one thought over another"}`,
 			expectedStatusCode: http.StatusCreated,
-			expectedResponse:   "{}\n",
+			expectedResponse: "{\"id\":\"1\",\"author\":\"William James\"," +
+				"\"quote\":\"The greatest weapon against stress is our ability to choose one thought over another\"}\n",
+			expectedLocation: `/quotes/1`,
 		},
 		{
 			title:              `invalid add quote (already exist)`,
 			datasForRequest:    `{"author":"William James","quote":"The greatest weapon against stress is our ability to choose one thought over another"}`,
 			expectedStatusCode: http.StatusConflict,
-			expectedResponse:   "{\"error\":\"quote already exists\"}\n",
+			expectedResponse:   problemBody(db.ErrDBAlreadyExists, `quote already exists`),
+		},
+		{
+			title:              `invalid add quote (exists in other case and quotes)`,
+			datasForRequest:    `{"author":"william JAMES","quote":"the GREATEST weapon against stress is our ability to choose one thought over another"}`,
+			expectedStatusCode: http.StatusConflict,
+			expectedResponse:   problemBody(db.ErrDBAlreadyExists, `quote already exists`),
 		},
 		{
 			title:              `wrong quote, field "quote"  is empty`,
 			datasForRequest:    `{"author":"William James","quote":"   "}`,
 			expectedStatusCode: http.StatusBadRequest,
-			expectedResponse:   "{\"error\":\"invalid data\"}\n",
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: quote - must not be empty`,
+				service.FieldError{Field: "quote", Message: "must not be empty"}),
 		},
 		{
 			title:              `dangerous) quote, alien field`,
 			datasForRequest:    `{"author":"Pradator","quote":"Wins","Alien":"Not this time"}`,
 			expectedStatusCode: http.StatusBadRequest,
-			expectedResponse:   "{\"error\":\"json: unknown field \\\"Alien\\\"\"}\n",
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: Alien - unknown field`,
+				service.FieldError{Field: "Alien", Message: "unknown field"}),
 		},
 	}
 
-	store := db.NewProvider()
-	usecase := service.NewService(store)
-	r := NewTransport(&config.Config{"not host", "not port"})
-	r.Routes(usecase)
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
 
 	for _, test := range testData {
 		t.Run(test.title, func(t *testing.T) {
@@ -85,6 +139,10 @@ func Test_SaveOneQuote(t *testing.T) {
 				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
 			}
 
+			if location := w.Header().Get("Location"); location != test.expectedLocation {
+				t.Errorf("Location not equal {got}:{want} {%s}:{%s}", location, test.expectedLocation)
+			}
+
 			if w.Body != nil {
 				if w.Body.String() != test.expectedResponse {
 					t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
@@ -106,12 +164,14 @@ func Test_RetrieveRandomQuote(t *testing.T) {
 		{
 			title:              `work with empty base`,
 			expectedStatusCode: http.StatusNotFound,
-			expectedResponse:   "{\"error\":\"quote list is empty\"}\n",
+			expectedResponse:   problemBody(db.ErrDBEmpty, `quote list is empty`),
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodGet, `/quotes/random`, nil)
 				if err != nil {
@@ -129,19 +189,21 @@ func Test_RetrieveRandomQuote(t *testing.T) {
 			expectedStatusCode: http.StatusOK,
 			expectedResponse:   "{\"id\":\"1\",\"author\":\"steve jobs\",\"quote\":\"your time is limited, so don’t waste it living someone else’s life\"}\n",
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
 
 				// добавляем запись для поиска
-				if err := store.NewQuote(
+				if _, err := store.NewQuote(
 					context.TODO(),
 					quotesData[2],
 				); err != nil {
 					return nil, err
 				}
 
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodGet, `/quotes/random`, nil)
 				if err != nil {
@@ -189,12 +251,14 @@ func Test_RetrieveListOfQuote(t *testing.T) {
 		{
 			title:              `work with empty base`,
 			expectedStatusCode: http.StatusNotFound,
-			expectedResponse:   `{"error":"quote list is empty"}`,
+			expectedResponse:   problemBody(db.ErrDBEmpty, `quote list is empty`),
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodGet, `/quotes`, nil)
 				if err != nil {
@@ -210,12 +274,14 @@ func Test_RetrieveListOfQuote(t *testing.T) {
 		{
 			title:              `work with empty base, url - contain author`,
 			expectedStatusCode: http.StatusNotFound,
-			expectedResponse:   `{"error":"quote not found"}`,
+			expectedResponse:   problemBody(db.ErrDBNotFound, `quote not found`),
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodGet, `/quotes?author=Alex`, nil)
 				if err != nil {
@@ -231,22 +297,24 @@ func Test_RetrieveListOfQuote(t *testing.T) {
 		{
 			title:              `valid request find list quote`,
 			expectedStatusCode: http.StatusOK,
-			expectedResponse: `[{"id":"1","author":"william james","quote":"the greatest weapon against stress is our ability to choose one thought over another"},
+			expectedResponse: `{"quotes":[{"id":"1","author":"william james","quote":"the greatest weapon against stress is our ability to choose one thought over another"},
 {"id":"2","author":"napoleon bonaparte","quote":"my dictionary does not contain the word 'impossible'"},
-{"id":"3","author":"steve jobs","quote":"your time is limited, so don’t waste it living someone else’s life"}]`,
+{"id":"3","author":"steve jobs","quote":"your time is limited, so don’t waste it living someone else’s life"}],"total":3}`,
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
 
 				// заполняем базу
 				for _, quote := range quotesData {
-					if err := store.NewQuote(context.TODO(), quote); err != nil {
+					if _, err := store.NewQuote(context.TODO(), quote); err != nil {
 						return nil, err
 					}
 				}
 
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodGet, `/quotes`, nil)
 				if err != nil {
@@ -265,17 +333,19 @@ func Test_RetrieveListOfQuote(t *testing.T) {
 			expectedResponse: `[{"id":"1","author":"william james","quote":"the greatest weapon against stress is our ability to choose one thought over another"}]
 `,
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
 
 				for _, quote := range quotesData {
-					if err := store.NewQuote(context.TODO(), quote); err != nil {
+					if _, err := store.NewQuote(context.TODO(), quote); err != nil {
 						return nil, err
 					}
 				}
 
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodGet, `/quotes?author=William James`, nil)
 				if err != nil {
@@ -326,6 +396,168 @@ func Test_RetrieveListOfQuote(t *testing.T) {
 	}
 }
 
+func Test_RetrieveListOfQuote_Page(t *testing.T) {
+	testData := []struct {
+		title              string
+		query              string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `first page`,
+			query:              `?limit=2`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: `{"quotes":[{"id":"1","author":"william james","quote":"the greatest weapon against stress is our ability to choose one thought over another"},
+{"id":"2","author":"napoleon bonaparte","quote":"my dictionary does not contain the word 'impossible'"}],"next_cursor":"2","total":3}`,
+		},
+		{
+			title:              `last page by cursor`,
+			query:              `?limit=2&after_id=2`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: `{"quotes":[{"id":"3","author":"steve jobs","quote":"your time is limited, so don’t waste it living someone else’s life"}],
+"total":3}`,
+		},
+		{
+			title:              `descending order`,
+			query:              `?limit=1&order=desc`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: `{"quotes":[{"id":"3","author":"steve jobs","quote":"your time is limited, so don’t waste it living someone else’s life"}],
+"next_cursor":"3","total":3}`,
+		},
+		{
+			title:              `descending order by cursor`,
+			query:              `?limit=5&order=desc&after_id=3`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: `{"quotes":[{"id":"2","author":"napoleon bonaparte","quote":"my dictionary does not contain the word 'impossible'"},
+{"id":"1","author":"william james","quote":"the greatest weapon against stress is our ability to choose one thought over another"}],"total":3}`,
+		},
+		{
+			title:              `page after the end`,
+			query:              `?after_id=10`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   `{"quotes":[],"total":3}`,
+		},
+		{
+			title:              `wrong limit`,
+			query:              `?limit=0`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+		{
+			title:              `wrong order`,
+			query:              `?order=random`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(context.TODO(), quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, `/quotes`+test.query, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			got := w.Body.String()
+			want := strings.Replace(test.expectedResponse, "\n", "", -1) + "\n"
+			if got != want {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", got, want)
+			}
+		})
+	}
+}
+
+func Test_SearchListOfQuote(t *testing.T) {
+	testData := []struct {
+		title              string
+		query              string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `empty query`,
+			query:              `?q=%20`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+		{
+			title:              `query without words`,
+			query:              `?q=OR`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(db.ErrDBInvalidQuery, `invalid search query`),
+		},
+		{
+			title:              `nothing found`,
+			query:              `?q=happiness`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"query\":\"happiness\",\"total\":0,\"results\":[]}\n",
+		},
+		{
+			title:              `phrase found`,
+			query:              `?q=%22waste+it%22`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"query\":\"\\\"waste it\\\"\",\"total\":1,\"results\":[{\"id\":\"3\",\"author\":\"steve jobs\"," +
+				"\"quote\":\"your time is limited, so don’t waste it living someone else’s life\",\"score\":1.8364462609581267," +
+				"\"matches\":[{\"start\":31,\"end\":39}]}]}\n",
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(context.TODO(), quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, `/quotes/search`+test.query, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
 func Test_ExpelQuote(t *testing.T) {
 	testData := []struct {
 		title              string
@@ -336,12 +568,14 @@ func Test_ExpelQuote(t *testing.T) {
 		{
 			title:              `wrong delete, base is empty`,
 			expectedStatusCode: http.StatusNotFound,
-			expectedResponse:   "{\"error\":\"quote not found\"}\n",
+			expectedResponse:   problemBody(db.ErrDBNotFound, `quote not found`),
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodDelete, `/quotes/1`, nil)
 				if err != nil {
@@ -357,12 +591,14 @@ func Test_ExpelQuote(t *testing.T) {
 		{
 			title:              `wrong delete, id not numeric`,
 			expectedStatusCode: http.StatusBadRequest,
-			expectedResponse:   "{\"error\":\"invalid data\"}\n",
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodDelete, `/quotes/{what}`, nil)
 				if err != nil {
@@ -380,17 +616,19 @@ func Test_ExpelQuote(t *testing.T) {
 			expectedStatusCode: http.StatusOK,
 			expectedResponse:   "{}\n",
 			testLogic: func() (*httptest.ResponseRecorder, error) {
-				store := db.NewProvider()
+				store, err := newTestStore()
+				if err != nil {
+					return nil, err
+				}
 
 				for _, quote := range quotesData {
-					if err := store.NewQuote(context.TODO(), quote); err != nil {
+					if _, err := store.NewQuote(context.TODO(), quote); err != nil {
 						return nil, err
 					}
 				}
 
-				usecase := service.NewService(store)
-				r := NewTransport(&config.Config{"not host", "not port"})
-				r.Routes(usecase)
+				usecase := service.NewService(store, nil)
+				r := newTestTransport(usecase)
 
 				req, err := http.NewRequest(http.MethodDelete, `/quotes/1`, nil)
 				if err != nil {
@@ -429,3 +667,858 @@ func Test_ExpelQuote(t *testing.T) {
 		})
 	}
 }
+
+func Test_ReplaceQuote_AmendQuote(t *testing.T) {
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `put - replace author and quote`,
+			method:             http.MethodPut,
+			path:               `/quotes/1`,
+			datasForRequest:    `{"author":"William James","quote":"Act as if what you do makes a difference"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"id\":\"1\",\"author\":\"William James\",\"quote\":\"Act as if what you do makes a difference\"}\n",
+		},
+		{
+			title:              `put - missing field`,
+			method:             http.MethodPut,
+			path:               `/quotes/1`,
+			datasForRequest:    `{"author":"William James"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: quote - must not be empty`,
+				service.FieldError{Field: "quote", Message: "must not be empty"}),
+		},
+		{
+			title:              `patch - change only author`,
+			method:             http.MethodPatch,
+			path:               `/quotes/2`,
+			datasForRequest:    `{"author":"Napoleon"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"id\":\"2\",\"author\":\"Napoleon\",\"quote\":\"my dictionary does not contain the word 'impossible'\"}\n",
+		},
+		{
+			title:              `patch - quote collides with existing`,
+			method:             http.MethodPatch,
+			path:               `/quotes/2`,
+			datasForRequest:    `{"quote":"Your time is limited, so don’t waste it living someone else’s life"}`,
+			expectedStatusCode: http.StatusConflict,
+			expectedResponse:   problemBody(db.ErrDBAlreadyExists, `quote already exists`),
+		},
+		{
+			title:              `patch - empty object`,
+			method:             http.MethodPatch,
+			path:               `/quotes/2`,
+			datasForRequest:    `{}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data: at least one field is required`),
+		},
+		{
+			title:              `patch - quote not found`,
+			method:             http.MethodPatch,
+			path:               `/quotes/10`,
+			datasForRequest:    `{"author":"Napoleon"}`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse:   problemBody(db.ErrDBNotFound, `quote not found`),
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(context.TODO(), quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, bytes.NewBuffer([]byte(test.datasForRequest)))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
+func Test_QuoteMetadata(t *testing.T) {
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:  `create with metadata`,
+			method: http.MethodPost,
+			path:   `/quotes`,
+			datasForRequest: `{"author":"Ralph Waldo Emerson","quote":"To be great is to be misunderstood",` +
+				`"source":"Self-Reliance","year":1841,"source_url":"https://example.com/self-reliance","language":"EN-us"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedResponse: "{\"id\":\"1\",\"author\":\"Ralph Waldo Emerson\",\"quote\":\"To be great is to be misunderstood\"," +
+				"\"source\":\"Self-Reliance\",\"year\":1841,\"source_url\":\"https://example.com/self-reliance\",\"language\":\"en-US\"," +
+				"\"created_at\":\"2025-01-01T12:00:00Z\",\"updated_at\":\"2025-01-01T12:00:00Z\"}\n",
+		},
+		{
+			title:              `create without metadata`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			datasForRequest:    `{"author":"Ralph Waldo Emerson","quote":"Nothing great was ever achieved without enthusiasm"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedResponse: "{\"id\":\"2\",\"author\":\"Ralph Waldo Emerson\",\"quote\":\"Nothing great was ever achieved without enthusiasm\"," +
+				"\"created_at\":\"2025-01-01T12:00:00Z\",\"updated_at\":\"2025-01-01T12:00:00Z\"}\n",
+		},
+		{
+			title:              `wrong year`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			datasForRequest:    `{"author":"Unknown","quote":"From the future","year":3000}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: year - must be between -3000 and the current year, except 0`,
+				service.FieldError{Field: "year", Message: "must be between -3000 and the current year, except 0"}),
+		},
+		{
+			title:              `wrong source url`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			datasForRequest:    `{"author":"Unknown","quote":"Relative","source_url":"/books/1"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: source_url - must be an absolute http(s) url`,
+				service.FieldError{Field: "source_url", Message: "must be an absolute http(s) url"}),
+		},
+		{
+			title:              `wrong language`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			datasForRequest:    `{"author":"Unknown","quote":"Klingon","language":"not a tag"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: language - must be a BCP-47 language tag`,
+				service.FieldError{Field: "language", Message: "must be a BCP-47 language tag"}),
+		},
+		{
+			title:              `filter by language and year`,
+			method:             http.MethodGet,
+			path:               `/quotes?language=en-US&year_from=1800&year_to=1900`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"quotes\":[{\"id\":\"1\",\"author\":\"Ralph Waldo Emerson\",\"quote\":\"To be great is to be misunderstood\"," +
+				"\"source\":\"Self-Reliance\",\"year\":1841,\"source_url\":\"https://example.com/self-reliance\",\"language\":\"en-US\"," +
+				"\"created_at\":\"2025-01-01T12:00:00Z\",\"updated_at\":\"2025-01-01T12:00:00Z\"}],\"total\":1}\n",
+		},
+		{
+			title:              `patch clears year and sets source`,
+			method:             http.MethodPatch,
+			path:               `/quotes/2`,
+			datasForRequest:    `{"source":"Circles","year":0}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"id\":\"2\",\"author\":\"Ralph Waldo Emerson\",\"quote\":\"Nothing great was ever achieved without enthusiasm\"," +
+				"\"source\":\"Circles\",\"created_at\":\"2025-01-01T12:00:00Z\",\"updated_at\":\"2025-01-01T12:00:00Z\"}\n",
+		},
+		{
+			title:              `wrong filter`,
+			method:             http.MethodGet,
+			path:               `/quotes?year_from=1900&year_to=1800`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+	}
+
+	store, err := newTestStore(db.WithClock(func() time.Time {
+		return time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	}))
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, bytes.NewBuffer([]byte(test.datasForRequest)))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
+func Test_QuoteTags(t *testing.T) {
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `create with tags`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			datasForRequest:    `{"author":"Mark Twain","quote":"Get your facts first","tags":["Humor","wisdom","humor"]}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedResponse:   "{\"id\":\"1\",\"author\":\"Mark Twain\",\"quote\":\"Get your facts first\",\"tags\":[\"humor\",\"wisdom\"]}\n",
+		},
+		{
+			title:              `create without tags`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			datasForRequest:    `{"author":"Peter Drucker","quote":"Management is doing things right"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedResponse:   "{\"id\":\"2\",\"author\":\"Peter Drucker\",\"quote\":\"Management is doing things right\"}\n",
+		},
+		{
+			title:              `empty tag`,
+			method:             http.MethodPost,
+			path:               `/quotes`,
+			datasForRequest:    `{"author":"Unknown","quote":"Empty tag","tags":[" "]}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: tags - at most 20 tags, each from 1 to 50 characters`,
+				service.FieldError{Field: "tags", Message: "at most 20 tags, each from 1 to 50 characters"}),
+		},
+		{
+			title:              `attach tags`,
+			method:             http.MethodPost,
+			path:               `/quotes/2/tags`,
+			datasForRequest:    `{"tags":["Leadership","wisdom"]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"id\":\"2\",\"author\":\"Peter Drucker\",\"quote\":\"Management is doing things right\"," +
+				"\"tags\":[\"leadership\",\"wisdom\"]}\n",
+		},
+		{
+			title:              `attach without tags`,
+			method:             http.MethodPost,
+			path:               `/quotes/2/tags`,
+			datasForRequest:    `{"tags":[]}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: problemBody(service.ErrServiceInvalidData, `invalid data: tags - must not be empty`,
+				service.FieldError{Field: "tags", Message: "must not be empty"}),
+		},
+		{
+			title:              `attach to missing quote`,
+			method:             http.MethodPost,
+			path:               `/quotes/9/tags`,
+			datasForRequest:    `{"tags":["humor"]}`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse:   problemBody(db.ErrDBNotFound, `quote not found`),
+		},
+		{
+			title:              `tag list`,
+			method:             http.MethodGet,
+			path:               `/tags`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "[{\"tag\":\"wisdom\",\"count\":2},{\"tag\":\"humor\",\"count\":1}," +
+				"{\"tag\":\"leadership\",\"count\":1}]\n",
+		},
+		{
+			title:              `filter all tags`,
+			method:             http.MethodGet,
+			path:               `/quotes?tag=wisdom&tag=humor`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"quotes\":[{\"id\":\"1\",\"author\":\"Mark Twain\",\"quote\":\"Get your facts first\"," +
+				"\"tags\":[\"humor\",\"wisdom\"]}],\"total\":1}\n",
+		},
+		{
+			title:              `filter any tag`,
+			method:             http.MethodGet,
+			path:               `/quotes?tag=humor&tag=leadership&tag_mode=any&order=desc&limit=1`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"quotes\":[{\"id\":\"2\",\"author\":\"Peter Drucker\",\"quote\":\"Management is doing things right\"," +
+				"\"tags\":[\"leadership\",\"wisdom\"]}],\"next_cursor\":\"2\",\"total\":2}\n",
+		},
+		{
+			title:              `wrong tag mode`,
+			method:             http.MethodGet,
+			path:               `/quotes?tag=humor&tag_mode=none`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+		{
+			title:              `detach tag`,
+			method:             http.MethodDelete,
+			path:               `/quotes/1/tags/Humor`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"id\":\"1\",\"author\":\"Mark Twain\",\"quote\":\"Get your facts first\"," +
+				"\"tags\":[\"wisdom\"]}\n",
+		},
+		{
+			title:              `patch clears tags`,
+			method:             http.MethodPatch,
+			path:               `/quotes/2`,
+			datasForRequest:    `{"tags":[]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"id\":\"2\",\"author\":\"Peter Drucker\",\"quote\":\"Management is doing things right\"}\n",
+		},
+		{
+			title:              `tag list after changes`,
+			method:             http.MethodGet,
+			path:               `/tags`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "[{\"tag\":\"wisdom\",\"count\":1}]\n",
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, bytes.NewBuffer([]byte(test.datasForRequest)))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
+func Test_Authors(t *testing.T) {
+	testData := []struct {
+		title              string
+		path               string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `first page`,
+			path:               `/authors?limit=2`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"authors\":[{\"name\":\"napoleon bonaparte\",\"count\":1},{\"name\":\"steve jobs\",\"count\":2}]," +
+				"\"next_cursor\":\"steve jobs\",\"total\":3}\n",
+		},
+		{
+			title:              `next page`,
+			path:               `/authors?limit=2&after=steve%20jobs`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"authors\":[{\"name\":\"william james\",\"count\":1}],\"total\":3}\n",
+		},
+		{
+			title:              `prefix`,
+			path:               `/authors?prefix=St`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   "{\"authors\":[{\"name\":\"steve jobs\",\"count\":2}],\"total\":1}\n",
+		},
+		{
+			title:              `wrong limit`,
+			path:               `/authors?limit=0`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+		{
+			title:              `quotes of author`,
+			path:               `/authors/Napoleon%20Bonaparte/quotes`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "[{\"id\":\"2\",\"author\":\"napoleon bonaparte\"," +
+				"\"quote\":\"my dictionary does not contain the word 'impossible'\"}]\n",
+		},
+		{
+			title:              `quotes of unknown author`,
+			path:               `/authors/nobody/quotes`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse:   problemBody(db.ErrDBNotFound, `quote not found`),
+		},
+		{
+			title:              `random quote of author`,
+			path:               `/authors/william%20james/random`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"id\":\"1\",\"author\":\"william james\"," +
+				"\"quote\":\"the greatest weapon against stress is our ability to choose one thought over another\"}\n",
+		},
+		{
+			title:              `random quote of unknown author`,
+			path:               `/authors/nobody/random`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse:   problemBody(db.ErrDBNotFound, `quote not found`),
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	ctx := context.Background()
+	for _, quote := range append(quotesData, model.Quote{Author: `Steve Jobs`, Body: `stay hungry, stay foolish`}) {
+		if _, err := store.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.path, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
+func Test_ImportListOfQuote(t *testing.T) {
+	testData := []struct {
+		title              string
+		path               string
+		contentType        string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:       `jsonl best-effort`,
+			path:        `/quotes:import`,
+			contentType: `application/x-ndjson`,
+			datasForRequest: `{"author":"Seneca","quote":"While we teach, we learn","tags":["wisdom"]}` + "\n\n" +
+				`{"author":"Steve Jobs","quote":"Your time is limited, so don’t waste it living someone else’s life"}` + "\n" +
+				`{"author":"","quote":"no author"}` + "\n" +
+				`{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity","id":"7"}` + "\n",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"atomic\":false,\"created\":2,\"duplicate\":1,\"invalid\":1,\"results\":[" +
+				"{\"line\":1,\"status\":\"created\",\"id\":\"4\"}," +
+				"{\"line\":3,\"status\":\"duplicate\",\"error\":\"quote already exists\"}," +
+				"{\"line\":4,\"status\":\"invalid\",\"error\":\"invalid data: author - must not be empty\"}," +
+				"{\"line\":5,\"status\":\"created\",\"id\":\"5\"}]}\n",
+		},
+		{
+			title:       `csv atomic aborted`,
+			path:        `/quotes:import?format=csv&atomic=true`,
+			contentType: `text/csv`,
+			datasForRequest: "author,quote,year,tags\n" +
+				"Marcus Aurelius,The happiness of your life depends upon the quality of your thoughts,170,stoicism|life\n" +
+				"Seneca,\"while we TEACH,  we learn\",,\n",
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedResponse: "{\"atomic\":true,\"created\":0,\"duplicate\":1,\"invalid\":0,\"results\":[" +
+				"{\"line\":2,\"status\":\"skipped\"}," +
+				"{\"line\":3,\"status\":\"duplicate\",\"error\":\"quote already exists\"}]}\n",
+		},
+		{
+			title:       `csv atomic`,
+			path:        `/quotes:import?atomic=true`,
+			contentType: `text/csv`,
+			datasForRequest: "author,quote,year,tags\n" +
+				"Marcus Aurelius,The happiness of your life depends upon the quality of your thoughts,170,stoicism|life\n",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"atomic\":true,\"created\":1,\"duplicate\":0,\"invalid\":0,\"results\":[" +
+				"{\"line\":2,\"status\":\"created\",\"id\":\"6\"}]}\n",
+		},
+		{
+			title:              `json array with wrong element`,
+			path:               `/quotes:import?format=json`,
+			contentType:        `application/json`,
+			datasForRequest:    `[{"author":"Epictetus","quote":"No man is free who is not master of himself"},{"author":1}]`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"atomic\":false,\"created\":1,\"duplicate\":0,\"invalid\":1,\"results\":[" +
+				"{\"line\":1,\"status\":\"created\",\"id\":\"7\"}," +
+				"{\"line\":2,\"status\":\"invalid\"," +
+				"\"error\":\"invalid data: author - invalid type number, expected string\"}]}\n",
+		},
+		{
+			title:              `broken json array`,
+			path:               `/quotes:import?format=json`,
+			datasForRequest:    `[{"author":"Epictetus"`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data: unexpected EOF`),
+		},
+		{
+			title:              `unknown csv column`,
+			path:               `/quotes:import?format=csv`,
+			datasForRequest:    "author,quote,rating\n",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data: unknown csv column - "rating"`),
+		},
+		{
+			title:              `unknown format`,
+			path:               `/quotes:import?format=xml`,
+			datasForRequest:    `<quotes/>`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	ctx := context.Background()
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, test.path, strings.NewReader(test.datasForRequest))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			req.Header.Set("Content-Type", test.contentType)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
+func Test_ExportListOfQuote(t *testing.T) {
+	testData := []struct {
+		title               string
+		path                string
+		expectedContentType string
+		expectedResponse    string
+	}{
+		{
+			title:               `jsonl by default`,
+			path:                `/quotes:export`,
+			expectedContentType: `application/x-ndjson; charset=UTF-8`,
+			expectedResponse: "{\"id\":\"1\",\"author\":\"Seneca\",\"quote\":\"While we teach, we learn\",\"year\":-4,\"tags\":[\"wisdom\"]}\n" +
+				"{\"id\":\"2\",\"author\":\"Epictetus\",\"quote\":\"No man is free, who is not master of himself\"}\n",
+		},
+		{
+			title:               `csv`,
+			path:                `/quotes:export?format=csv`,
+			expectedContentType: `text/csv; charset=UTF-8`,
+			expectedResponse: "id,author,quote,source,year,source_url,language,tags,created_at,updated_at\n" +
+				"1,Seneca,\"While we teach, we learn\",,-4,,,wisdom,,\n" +
+				"2,Epictetus,\"No man is free, who is not master of himself\",,,,,,,\n",
+		},
+		{
+			title:               `json array`,
+			path:                `/quotes:export?format=json`,
+			expectedContentType: `application/json; charset=UTF-8`,
+			expectedResponse: "[{\"id\":\"1\",\"author\":\"Seneca\",\"quote\":\"While we teach, we learn\",\"year\":-4,\"tags\":[\"wisdom\"]}," +
+				"{\"id\":\"2\",\"author\":\"Epictetus\",\"quote\":\"No man is free, who is not master of himself\"}]\n",
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	ctx := context.Background()
+	for _, quote := range []model.Quote{
+		{Author: `Seneca`, Body: `While we teach, we learn`, Year: -4, Tags: []string{`Wisdom`}},
+		{Author: `Epictetus`, Body: `No man is free, who is not master of himself`},
+	} {
+		if _, err := store.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.path, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, http.StatusOK)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != test.expectedContentType {
+				t.Errorf("Content-Type not equal {got}:{want} {%s}:{%s}", got, test.expectedContentType)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+
+			// выгрузка загружается обратно в пустое хранилище без отказов
+			empty, err := newTestStore()
+			if err != nil {
+				t.Fatalf("db.NewProvider error - {%v};", err)
+			}
+			importResponse, err := service.NewService(empty, nil).ImportQuotes(ctx,
+				service.BulkFormat(strings.TrimPrefix(test.path, `/quotes:export?format=`)),
+				strings.NewReader(w.Body.String()), true)
+			if err != nil || importResponse.Created != 2 {
+				t.Errorf("round trip import error - {%v}, response - {%+v}", err, importResponse)
+			}
+		})
+	}
+}
+
+// выгрузка с ошибкой после начала ответа
+type failingExport struct {
+	service.BulkQuote
+}
+
+func (failingExport) ExportQuotes(_ context.Context, _ service.BulkFormat, w io.Writer) error {
+	io.WriteString(w, "{\"id\":\"1\"}\n")
+	return errors.New("disk failure")
+}
+
+func Test_ExportListOfQuote_Abort(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, `/quotes:export`, nil)
+	w := httptest.NewRecorder()
+
+	defer func() {
+		// ответ уже начат -> соединение обрывается, клиент не принимает часть за целую выгрузку
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("panic not equal {got}:{want} {%v}:{%v};", rec, http.ErrAbortHandler)
+		}
+	}()
+
+	ExportListOfQuote(failingExport{}, time.Minute)(w, req)
+}
+
+func Test_Batch(t *testing.T) {
+	testData := []struct {
+		title              string
+		method             string
+		path               string
+		datasForRequest    string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:  `create partial`,
+			method: http.MethodPost,
+			path:   `/quotes/batch`,
+			datasForRequest: `{"quotes":[{"author":"Seneca","quote":"While we teach, we learn"},` +
+				`{"author":"Steve Jobs","quote":"YOUR TIME is limited, so don’t waste it living someone else’s life"},` +
+				`{"author":"Seneca"},{"author":"Seneca","quote":"Luck is what happens when preparation meets opportunity"}]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"atomic\":false,\"succeeded\":2,\"failed\":2,\"results\":[" +
+				"{\"index\":0,\"status\":201,\"id\":\"4\"}," +
+				"{\"index\":1,\"status\":409,\"code\":\"quote_already_exists\",\"error\":\"quote already exists\"}," +
+				"{\"index\":2,\"status\":400,\"code\":\"invalid_data\",\"error\":\"invalid data: quote - must not be empty\"}," +
+				"{\"index\":3,\"status\":201,\"id\":\"5\"}]}\n",
+		},
+		{
+			title:  `create atomic aborted`,
+			method: http.MethodPost,
+			path:   `/quotes/batch?atomic=true`,
+			datasForRequest: `{"quotes":[{"author":"Epictetus","quote":"No man is free who is not master of himself"},` +
+				`{"author":"Seneca","quote":"while we teach, we learn"}]}`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedResponse: "{\"atomic\":true,\"succeeded\":0,\"failed\":2,\"results\":[" +
+				"{\"index\":0,\"status\":424,\"code\":\"batch_aborted\",\"error\":\"batch aborted\"}," +
+				"{\"index\":1,\"status\":409,\"code\":\"quote_already_exists\",\"error\":\"quote already exists\"}]}\n",
+		},
+		{
+			title:              `create empty batch`,
+			method:             http.MethodPost,
+			path:               `/quotes/batch`,
+			datasForRequest:    `{"quotes":[]}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+		{
+			title:              `delete atomic aborted`,
+			method:             http.MethodDelete,
+			path:               `/quotes?ids=1,9&atomic=true`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedResponse: "{\"atomic\":true,\"succeeded\":0,\"failed\":2,\"results\":[" +
+				"{\"index\":0,\"status\":424,\"id\":\"1\",\"code\":\"batch_aborted\",\"error\":\"batch aborted\"}," +
+				"{\"index\":1,\"status\":404,\"id\":\"9\",\"code\":\"quote_not_found\",\"error\":\"quote not found\"}]}\n",
+		},
+		{
+			title:              `delete partial`,
+			method:             http.MethodDelete,
+			path:               `/quotes?ids=1,9,5`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"atomic\":false,\"succeeded\":2,\"failed\":1,\"results\":[" +
+				"{\"index\":0,\"status\":200,\"id\":\"1\"}," +
+				"{\"index\":1,\"status\":404,\"id\":\"9\",\"code\":\"quote_not_found\",\"error\":\"quote not found\"}," +
+				"{\"index\":2,\"status\":200,\"id\":\"5\"}]}\n",
+		},
+		{
+			title:              `delete wrong ids`,
+			method:             http.MethodDelete,
+			path:               `/quotes?ids=1,a`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	ctx := context.Background()
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(ctx, quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.path, bytes.NewBuffer([]byte(test.datasForRequest)))
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
+
+func Test_RetrieveQuote(t *testing.T) {
+	testData := []struct {
+		title              string
+		path               string
+		expectedStatusCode int
+		expectedResponse   string
+	}{
+		{
+			title:              `exists`,
+			path:               `/quotes/2`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: "{\"id\":\"2\",\"author\":\"napoleon bonaparte\"," +
+				"\"quote\":\"my dictionary does not contain the word 'impossible'\"}\n",
+		},
+		{
+			title:              `not found`,
+			path:               `/quotes/9`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedResponse:   problemBody(db.ErrDBNotFound, `quote not found`),
+		},
+		{
+			title:              `wrong id`,
+			path:               `/quotes/0`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse:   problemBody(service.ErrServiceInvalidData, `invalid data`),
+		},
+	}
+
+	store, err := newTestStore()
+	if err != nil {
+		t.Fatalf("db.NewProvider error - {%v};", err)
+	}
+
+	for _, quote := range quotesData {
+		if _, err := store.NewQuote(context.TODO(), quote); err != nil {
+			t.Fatalf("store.NewQuote error - {%v};", err)
+		}
+	}
+
+	usecase := service.NewService(store, nil)
+	r := newTestTransport(usecase)
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.path, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest error - {%v};", err)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatusCode {
+				t.Errorf("status not equal {got}:{want} {%d}:{%d}", w.Code, test.expectedStatusCode)
+			}
+
+			if w.Body.String() != test.expectedResponse {
+				t.Errorf("invalid response body {got}:{want} {%s}:{%s};", w.Body.String(), test.expectedResponse)
+			}
+		})
+	}
+}
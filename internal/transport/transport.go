@@ -2,7 +2,15 @@
 package transport
 
 import (
+	"crypto/tls"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/Ekvo/go-map-rwmu-mux/internal/auth"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/config"
+	"github.com/Ekvo/go-map-rwmu-mux/internal/metrics"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/server"
 	"github.com/Ekvo/go-map-rwmu-mux/internal/service"
 
@@ -10,24 +18,221 @@ import (
 )
 
 // оболочка для сервера, и 'ServeMux'
+// 'Admin' - сервер проб и метрик, nil -> 'cfg.AdminPort' не задан, пробы на общем сервере
 type Transport struct {
 	*http.ServeMux
 	server.Srv
+
+	Admin *server.Srv
+	admin *http.ServeMux
+
+	// готовность для '/readyz' и время старта для '/version'
+	health *health
+
+	// ответы по 'Idempotency-Key' для POST /quotes
+	idempotency IdempotencyStore
+
+	// новый ID запроса, если клиент не передал 'X-Request-ID'
+	newRequestID func() string
+
+	// лог обработчиков, передается в контексте запроса
+	log *slog.Logger
+
+	// метрики для 'GET /metrics'
+	metrics *metrics.Registry
+	http    *httpMetrics
+
+	// обертки из 'WithMiddleware' для всех маршрутов API
+	middleware []Middleware
+
+	// наибольший размер тела запроса и тела импорта
+	maxBody   int64
+	maxImport int64
+	// срок записи части выгрузки
+	writeTimeout time.Duration
+
+	// проверка учетных данных, пусто -> маршруты API без аутентификации
+	auth []Authenticator
+	// ключи API для замены через admin маршрут
+	keys *APIKeys
+
+	// бюджеты клиента на чтение и на изменение, меняются через 'SetRateLimits'
+	readLimit  *RateLimiter
+	writeLimit *RateLimiter
+	// бюджет неудачных аутентификаций на адрес
+	authLimit *RateLimiter
+	// прокси, которым верим "X-Forwarded-For"
+	trusted []netip.Prefix
+
+	// TLS основного сервера, nil -> без TLS
+	tls *tls.Config
+}
+
+// настройка Transport
+type Option func(*Transport)
+
+// свое хранилище ключей идемпотентности вместо хранилища в памяти
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(r *Transport) {
+		r.idempotency = store
+	}
+}
+
+// свой источник ID запросов вместо случайного
+func WithRequestIDGenerator(generate func() string) Option {
+	return func(r *Transport) {
+		r.newRequestID = generate
+	}
+}
+
+// лог обработчиков вместо 'slog.Default()'
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Transport) {
+		r.log = logger
+	}
+}
+
+// общий набор метрик с другими слоями вместо своего
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(r *Transport) {
+		r.metrics = reg
+	}
+}
+
+// свои обертки для всех маршрутов API, внутри общей цепочки и перед обертками маршрута
+func WithMiddleware(mws ...Middleware) Option {
+	return func(r *Transport) {
+		r.middleware = append(r.middleware, mws...)
+	}
+}
+
+// аутентификация по ключам API из заголовка 'X-API-Key' и маршрут замены ключей
+func WithAPIKeys(keys *APIKeys) Option {
+	return func(r *Transport) {
+		r.keys = keys
+		r.auth = append(r.auth, keys)
+	}
+}
+
+// аутентификация по JWT из "Authorization: Bearer" с проверкой 'verifier'
+func WithBearerTokens(verifier *auth.JWTVerifier) Option {
+	return func(r *Transport) {
+		r.auth = append(r.auth, NewBearerTokens(verifier))
+	}
+}
+
+// основной сервер с TLS 'tlsConfig' (из 'server.NewTLSConfig'), admin сервер остается без TLS
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(r *Transport) {
+		r.tls = tlsConfig
+	}
 }
 
 // конструктор Transport
-func NewTransport(cfg *config.Config) Transport {
-	mux := http.NewServeMux()
-	return Transport{
-		ServeMux: mux,
-		Srv:      server.InitSRV(cfg, mux),
+// по умолчанию ключи идемпотентности хранятся в памяти 'cfg.IdempotencyTTL', не больше 'cfg.IdempotencyMaxKeys'
+// сразу добавляет пробы и метрики, маршруты API - 'Routes',
+// до 'Ready' запросы к API получают 503
+func NewTransport(cfg *config.Config, opts ...Option) Transport {
+	r := Transport{
+		ServeMux:     http.NewServeMux(),
+		newRequestID: newRequestID,
+		log:          slog.Default(),
+		health:       newHealth(time.Now),
+		maxBody:      cfg.MaxBodySize,
+		maxImport:    cfg.MaxImportSize,
+		writeTimeout: cfg.WriteTimeout,
+		readLimit:    NewRateLimiter(cfg.RateLimitRead, cfg.RateLimitReadBurst),
+		writeLimit:   NewRateLimiter(cfg.RateLimitWrite, cfg.RateLimitWriteBurst),
+		authLimit:    NewRateLimiter(cfg.RateLimitAuth, cfg.RateLimitAuthBurst),
+		trusted:      cfg.TrustedProxies,
 	}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	if r.idempotency == nil {
+		r.idempotency = NewMemoryIdempotencyStore(cfg.IdempotencyTTL, cfg.IdempotencyMaxKeys)
+	}
+
+	if r.metrics == nil {
+		r.metrics = metrics.NewRegistry()
+	}
+	r.http = newHTTPMetrics(r.metrics)
+
+	if cfg.AdminPort != "" {
+		r.admin = http.NewServeMux()
+		admin := server.InitAdminSRV(cfg, r.admin)
+		r.Admin = &admin
+	}
+	r.Srv = server.InitSRV(cfg, r.gate(r.ServeMux))
+	r.Srv.TLSConfig = r.tls
+
+	r.probes()
+	r.adminRoutes()
+
+	return r
+}
+
+// новые бюджеты клиентов из 'cfg' без перезапуска, остальные поля 'cfg' не используются
+func (r Transport) SetRateLimits(cfg *config.Config) {
+	r.readLimit.SetLimit(cfg.RateLimitRead, cfg.RateLimitReadBurst)
+	r.writeLimit.SetLimit(cfg.RateLimitWrite, cfg.RateLimitWriteBurst)
+	r.authLimit.SetLimit(cfg.RateLimitAuth, cfg.RateLimitAuthBurst)
+}
+
+// маршрут API: общая цепочка, обертки из 'WithMiddleware', затем обертки маршрута 'mws'
+func (r Transport) handle(pattern string, handler http.HandlerFunc, mws ...Middleware) {
+	chain := append(slices.Clone(r.middleware), mws...)
+	r.handleOn(r.ServeMux, pattern, slog.LevelInfo, handler, chain...)
+}
+
+// общая цепочка маршрута: метрики по 'pattern', ID запроса и лог в контексте,
+// лог доступа на уровне 'level', восстановление после паники, затем 'mws'
+func (r Transport) handleOn(mux *http.ServeMux, pattern string, level slog.Level, handler http.HandlerFunc, mws ...Middleware) {
+	chain := []Middleware{
+		r.http.middleware(pattern),
+		RequestIDs(r.newRequestID, r.log),
+		AccessLog(pattern, level),
+		Recoverer(),
+	}
+	mux.HandleFunc(pattern, Chain(handler, append(chain, mws...)...))
+}
+
+// право 'scope' для маршрута, аутентификация не настроена -> без проверки
+// неудачные попытки с одного адреса ограничены 'cfg.RateLimitAuth'
+func (r Transport) authorize(scope string) Middleware {
+	if len(r.auth) == 0 {
+		return func(next http.HandlerFunc) http.HandlerFunc { return next }
+	}
+	return LimitAuthFailures(r.authLimit, r.trusted, Authorize(scope, r.auth...))
 }
 
 // создание маршрутов
+// право проверяется до чтения тела (неудачные попытки - в бюджете адреса), затем бюджет клиента: чтение - 'cfg.RateLimitRead',
+// изменение и удаление - 'cfg.RateLimitWrite',
+// тело запроса не больше 'cfg.MaxBodySize', у импорта - не больше 'cfg.MaxImportSize'
 func (r Transport) Routes(service service.ServiceQuote) {
-	r.HandleFunc("POST /quotes", SaveOneQuote(service))
-	r.HandleFunc("GET /quotes", RetrieveListOfQuote(service))
-	r.HandleFunc("GET /quotes/random", RetrieveRandomQuote(service))
-	r.HandleFunc("DELETE /quotes/{id}", ExpelQuote(service))
+	read, write, remove := r.authorize(ScopeRead), r.authorize(ScopeWrite), r.authorize(ScopeDelete)
+	reads, writes := RateLimit(r.readLimit, r.trusted), RateLimit(r.writeLimit, r.trusted)
+	body, bulk := MaxBodySize(r.maxBody), MaxBodySize(r.maxImport)
+
+	r.handle("POST /quotes", Idempotent(r.idempotency, r.trusted, SaveOneQuote(service)), write, writes, body)
+	r.handle("POST /quotes/batch", SaveListOfQuote(service), write, writes, body)
+	r.handle("DELETE /quotes", ExpelListOfQuote(service), remove, writes, body)
+	r.handle("GET /quotes", RetrieveListOfQuote(service), read, reads)
+	r.handle("GET /quotes/random", RetrieveRandomQuote(service), read, reads)
+	r.handle("GET /quotes/search", SearchListOfQuote(service), read, reads)
+	r.handle("GET /quotes/{id}", RetrieveQuote(service), read, reads)
+	r.handle("POST /quotes:import", ImportListOfQuote(service), write, writes, bulk)
+	r.handle("GET /quotes:export", ExportListOfQuote(service, r.writeTimeout), read, reads)
+	r.handle("DELETE /quotes/{id}", ExpelQuote(service), remove, writes)
+	r.handle("PUT /quotes/{id}", ReplaceQuote(service), write, writes, body)
+	r.handle("PATCH /quotes/{id}", AmendQuote(service), write, writes, body)
+	r.handle("GET /tags", RetrieveListOfTag(service), read, reads)
+	r.handle("POST /quotes/{id}/tags", AttachQuoteTags(service), write, writes, body)
+	r.handle("DELETE /quotes/{id}/tags/{tag}", DetachQuoteTag(service), write, writes)
+	r.handle("GET /authors", RetrieveListOfAuthor(service), read, reads)
+	r.handle("GET /authors/{name}/quotes", RetrieveQuotesOfAuthor(service), read, reads)
+	r.handle("GET /authors/{name}/random", RetrieveRandomQuoteOfAuthor(service), read, reads)
 }
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// замена типографских кавычек и апострофов на ASCII
+var quoteReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'", "`", "'", "´", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "«", `"`, "»", `"`, "″", `"`,
+)
+
+// NormalizeKey - ключ для сравнения строк без учета оформления:
+// Unicode NFC, типографские кавычки и апострофы -> ASCII,
+// приведение регистра (case folding), пробелы схлопываются в один.
+// Исходная строка не меняется - ключ используется только для индексов.
+func NormalizeKey(s string) string {
+	s = norm.NFC.String(s)
+	s = quoteReplacer.Replace(s)
+	// 'cases.Caser' не потокобезопасен - создаем на каждый вызов
+	s = cases.Fold().String(s)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -3,9 +3,10 @@ package utils
 import (
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"mime"
 	"net/http"
+	"sort"
 )
 
 var (
@@ -39,7 +40,7 @@ func DecodeJSON(req *http.Request, obj any) error {
 	}
 	defer func() {
 		if err := req.Body.Close(); err != nil {
-			log.Printf("utils: DecodeJSON req.Body.Close error - {%v};", err)
+			slog.Warn("utils: DecodeJSON req.Body.Close", "error", err)
 		}
 	}()
 	dec := json.NewDecoder(req.Body)
@@ -54,29 +55,32 @@ func EncodeJSON(w http.ResponseWriter, status int, obj any) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(obj); err != nil {
-		log.Printf("utils: EncodeJSON json.Encode error - {%v};", err)
+		slog.Warn("utils: EncodeJSON json.Encode", "error", err)
 	}
 }
 
 // IndexByValue - бинарный поиск индекса по значению 'target';
 // если найден, возвращает индекс и true, в противном случае - 0 и false.
 func IndexByValue(slice []uint, target uint) (uint, bool) {
-	low := uint(0)
-	high := uint(len(slice) - 1)
+	i := sort.Search(len(slice), func(i int) bool { return slice[i] >= target })
+	if i == len(slice) || slice[i] != target {
+		return 0, false // элемент не найден
+	}
 
-	for low <= high {
-		mid := (low + high) / 2
-		guess := slice[mid]
+	return uint(i), true
+}
 
-		if guess == target {
-			// элемент найден
-			return mid, true
-		} else if guess > target {
-			high = mid - 1
-		} else {
-			low = mid + 1
-		}
+// InsertByValue - вставка 'value' в отсортированный 'slice' с сохранением порядка;
+// значение уже есть -> 'slice' возвращается без изменений.
+func InsertByValue(slice []uint, value uint) []uint {
+	i := sort.Search(len(slice), func(i int) bool { return slice[i] >= value })
+	if i < len(slice) && slice[i] == value {
+		return slice
 	}
 
-	return 0, false // элемент не найден
+	slice = append(slice, 0)
+	copy(slice[i+1:], slice[i:])
+	slice[i] = value
+
+	return slice
 }
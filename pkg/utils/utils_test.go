@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -83,6 +84,7 @@ func Test_IndexByValue(t *testing.T) {
 
 	testData := []struct {
 		title     string
+		nums      []uint
 		num       uint
 		want      uint
 		wantExist bool
@@ -123,11 +125,30 @@ func Test_IndexByValue(t *testing.T) {
 			want:      0,
 			wantExist: false,
 		},
+		{
+			title:     `empty slice`,
+			nums:      []uint{},
+			num:       5,
+			want:      0,
+			wantExist: false,
+		},
+		{
+			title:     `less than first`,
+			nums:      []uint{3, 7},
+			num:       1,
+			want:      0,
+			wantExist: false,
+		},
 	}
 
 	for _, test := range testData {
 		t.Run(test.title, func(t *testing.T) {
-			got, gotExist := IndexByValue(nums, test.num)
+			slice := nums
+			if test.nums != nil {
+				slice = test.nums
+			}
+
+			got, gotExist := IndexByValue(slice, test.num)
 
 			if gotExist != test.wantExist {
 				t.Errorf("IndexByValue: gotExist - {%t} not equal wantExist - {%t};", gotExist, test.wantExist)
@@ -139,3 +160,95 @@ func Test_IndexByValue(t *testing.T) {
 		})
 	}
 }
+
+func Test_InsertByValue(t *testing.T) {
+	testData := []struct {
+		title string
+		nums  []uint
+		num   uint
+		want  []uint
+	}{
+		{
+			title: `insert into empty`,
+			nums:  nil,
+			num:   5,
+			want:  []uint{5},
+		},
+		{
+			title: `insert at start`,
+			nums:  []uint{5, 8},
+			num:   1,
+			want:  []uint{1, 5, 8},
+		},
+		{
+			title: `insert in the middle`,
+			nums:  []uint{5, 8, 19},
+			num:   7,
+			want:  []uint{5, 7, 8, 19},
+		},
+		{
+			title: `insert at end`,
+			nums:  []uint{5, 8},
+			num:   19,
+			want:  []uint{5, 8, 19},
+		},
+		{
+			title: `value already exists`,
+			nums:  []uint{5, 8},
+			num:   8,
+			want:  []uint{5, 8},
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			got := InsertByValue(test.nums, test.num)
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("InsertByValue: got - {%v} not equal want - {%v};", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_NormalizeKey(t *testing.T) {
+	testData := []struct {
+		title string
+		str   string
+		want  string
+	}{
+		{
+			title: `case folding`,
+			str:   `Steve JOBS`,
+			want:  `steve jobs`,
+		},
+		{
+			title: `whitespace collapsing`,
+			str:   "  Steve \t\n Jobs ",
+			want:  `steve jobs`,
+		},
+		{
+			title: `typographic apostrophe and quotes`,
+			str:   `Don’t say “never”`,
+			want:  `don't say "never"`,
+		},
+		{
+			title: `NFC - combining acute accent`,
+			str:   "Cafe\u0301",
+			want:  "caf\u00e9",
+		},
+		{
+			title: `unicode case folding`,
+			str:   `STRASSE Straße Ёлка`,
+			want:  `strasse strasse ёлка`,
+		},
+	}
+
+	for _, test := range testData {
+		t.Run(test.title, func(t *testing.T) {
+			if got := NormalizeKey(test.str); got != test.want {
+				t.Errorf("NormalizeKey: got - {%s} not equal want - {%s};", got, test.want)
+			}
+		})
+	}
+}